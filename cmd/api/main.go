@@ -1,22 +1,35 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/loyalty"
 	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
 	"github.com/dilaragorum/online-ticket-project-go/internal/payment"
+	"github.com/dilaragorum/online-ticket-project-go/internal/role"
+	"github.com/dilaragorum/online-ticket-project-go/internal/session"
 	"github.com/dilaragorum/online-ticket-project-go/internal/ticket"
+	"github.com/dilaragorum/online-ticket-project-go/internal/traveller"
 	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
 	"github.com/dilaragorum/online-ticket-project-go/internal/user"
+	"github.com/dilaragorum/online-ticket-project-go/internal/waitlist"
+	"github.com/dilaragorum/online-ticket-project-go/internal/wallet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/watch"
 	"github.com/dilaragorum/online-ticket-project-go/pkg/database"
 	"github.com/labstack/echo/v4"
 	"github.com/spf13/viper"
 	"log"
+	"time"
 )
 
 func main() {
 	e := echo.New()
-	e.Use(auth.TokenMiddleware)
+	// X-Forwarded-For is only believed when it comes from a proxy on a
+	// private network, so clients cannot pick the IP they are limited by.
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	routes := auth.NewRoutes(e)
 
 	viper.SetConfigFile(".env")
 	if err := viper.ReadInConfig(); err != nil {
@@ -25,7 +38,12 @@ func main() {
 
 	fmt.Println(viper.Get("POSTGRES_DB"))
 
-	jwtSecretKey := viper.GetString("ONLINE_TICKET_GO_JWTKEY")
+	tokens, err := auth.NewJWTFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.NewHandler(routes, tokens)
+	go tokens.Run(context.Background(), viper.GetDuration("ONLINE_TICKET_GO_JWT_ROTATION_INTERVAL"))
 
 	connectionPool, err := database.Setup()
 	if err != nil {
@@ -36,23 +54,77 @@ func main() {
 	notificationRepository := notification.NewNotificationRepository(connectionPool)
 	notificationService := notification.NewService(notificationRepository)
 
+	// FLEET
+	fleetRepo := fleet.NewRepository(connectionPool)
+	fleetService := fleet.NewService(fleetRepo)
+	fleet.NewHandler(routes, fleetService)
+
+	// WATCH
+	watchRepo := watch.NewRepository(connectionPool)
+	watchService := watch.NewService(watchRepo, notificationService)
+	watch.NewHandler(routes, watchService)
+
 	// TRİP
 	tripRepo := trip.NewTripRepository(connectionPool)
-	tripService := trip.NewTripService(tripRepo)
-	trip.Handler(e, tripService)
+	tripService := trip.NewTripService(tripRepo, fleetRepo, notificationService, watchService)
+	trip.Handler(routes, tripService)
 
-	// USER
+	// ROLE
+	roleRepo := role.NewRepository(connectionPool)
+	roleService := role.NewService(roleRepo)
+	if err = roleService.Seed(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	role.NewHandler(routes, roleService)
+
+	// SESSION
 	userRepository := user.NewRepository(connectionPool)
-	userService := user.NewUserService(userRepository)
-	user.NewHandler(e, userService, notificationService, jwtSecretKey)
+	sessionRepo := session.NewRepository(connectionPool)
+	sessionService := session.NewService(sessionRepo, tokens, roleService, user.NewSessionAccounts(userRepository))
+	session.NewHandler(routes, sessionService)
+	e.Use(auth.TokenMiddleware(routes, tokens, sessionService))
+
+	// USER
+	verifier, err := user.NewVerifierFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	userService := user.NewUserService(userRepository, sessionService, roleService, verifier)
+	user.NewHandler(routes, userService, notificationService, sessionService, viper.GetString("ONLINE_TICKET_GO_BASE_URL"))
 
 	//PAYMENT
 	paymentClient := payment.NewClient()
 
+	// WAITLIST
+	waitlistRepo := waitlist.NewRepository(connectionPool)
+	waitlistService := waitlist.NewService(waitlistRepo, tripRepo, notificationService, viper.GetString("ONLINE_TICKET_GO_BASE_URL"))
+	waitlist.NewHandler(routes, waitlistService)
+	go waitlistService.Run(context.Background(), time.Minute)
+
+	// WALLET
+	walletRepo := wallet.NewRepository(connectionPool)
+	walletService := wallet.NewService(walletRepo)
+	wallet.NewHandler(routes, walletService)
+
+	// LOYALTY
+	loyaltyRepo := loyalty.NewRepository(connectionPool)
+	loyaltyService := loyalty.NewService(loyaltyRepo)
+	loyalty.NewHandler(routes, loyaltyService)
+
+	// TRAVELLER
+	travellerRepo := traveller.NewRepository(connectionPool)
+	travellerService := traveller.NewService(travellerRepo)
+	traveller.NewHandler(routes, travellerService)
+
 	// TICKET
+	var emailVerifier ticket.EmailVerifier
+	if viper.GetBool("ONLINE_TICKET_GO_REQUIRE_VERIFIED_EMAIL") {
+		emailVerifier = userService
+	}
 	ticketRepo := ticket.NewTicketRepository(connectionPool)
-	service := ticket.NewService(ticketRepo, notificationService, tripRepo, paymentClient)
-	ticket.NewHandler(e, service)
+	service := ticket.NewService(ticketRepo, notificationService, tripRepo, fleetRepo, paymentClient, waitlistService, walletService, loyaltyService, travellerService, emailVerifier)
+	ticket.NewHandler(routes, service)
+	go service.Run(context.Background(), time.Minute)
 
 	e.Logger.Fatal(e.Start(":8080"))
 }
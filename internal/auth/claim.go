@@ -10,12 +10,19 @@ const (
 	Admin          UserType = "admin"
 	IndividualUser UserType = "individual"
 	CorporateUser  UserType = "corporate"
+	// Staff users are employees whose permissions come from their assigned roles only.
+	Staff UserType = "staff"
 )
 
 type Claims struct {
 	Username string   `json:"username"`
 	UserType UserType `json:"user_type"`
 	UserID   uint
+	// SessionID is the server-side session the token was issued for.
+	SessionID string `json:"sid"`
+	// Roles and Permissions are resolved when the token is issued.
+	Roles       []Role       `json:"roles,omitempty"`
+	Permissions []Permission `json:"perms,omitempty"`
 	jwt.RegisteredClaims
 }
 
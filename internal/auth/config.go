@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultIssuer   = "online-ticket-go"
+	DefaultAudience = "online-ticket-go-api"
+	DefaultKeyID    = "default"
+
+	// DefaultRotationInterval is how often the key directory is reloaded when
+	// ONLINE_TICKET_GO_JWT_ROTATION_INTERVAL is not set.
+	DefaultRotationInterval = 10 * time.Minute
+)
+
+var ErrInvalidKeyConfig = errors.New("previous keys should be written like kid:secret,kid:secret")
+
+// NewJWTFromConfig signs with the PEM keys of ONLINE_TICKET_GO_JWT_KEY_DIR
+// when it is set. Otherwise it signs with ONLINE_TICKET_GO_JWTKEY under the
+// key ID ONLINE_TICKET_GO_JWTKID, and after a rotation the old secrets are
+// kept in ONLINE_TICKET_GO_JWT_PREVIOUS_KEYS, so the tokens they signed stay valid.
+func NewJWTFromConfig() (*JWT, error) {
+	issuer := valueOr(viper.GetString("ONLINE_TICKET_GO_JWT_ISSUER"), DefaultIssuer)
+	audience := valueOr(viper.GetString("ONLINE_TICKET_GO_JWT_AUDIENCE"), DefaultAudience)
+
+	if keyDir := viper.GetString("ONLINE_TICKET_GO_JWT_KEY_DIR"); keyDir != "" {
+		signingKey, verifyKeys, err := LoadKeyDir(keyDir)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens := NewJWT(issuer, audience, signingKey, verifyKeys...)
+		tokens.keyDir = keyDir
+		return tokens, nil
+	}
+
+	secret := viper.GetString("ONLINE_TICKET_GO_JWTKEY")
+	if secret == "" {
+		return nil, ErrNoSigningKey
+	}
+
+	keyID := valueOr(viper.GetString("ONLINE_TICKET_GO_JWTKID"), DefaultKeyID)
+
+	previousKeys, err := parseHMACKeys(viper.GetString("ONLINE_TICKET_GO_JWT_PREVIOUS_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJWT(issuer, audience, NewHMACKey(keyID, []byte(secret)), previousKeys...), nil
+}
+
+func parseHMACKeys(value string) ([]Key, error) {
+	var keys []Key
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		id, secret, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found || id == "" || secret == "" {
+			return nil, ErrInvalidKeyConfig
+		}
+		keys = append(keys, NewHMACVerifyKey(id, []byte(secret)))
+	}
+
+	return keys, nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
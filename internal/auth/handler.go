@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const JWKSPath = "/.well-known/jwks.json"
+
+type handler struct {
+	tokens *JWT
+}
+
+func NewHandler(routes *Routes, tokens *JWT) *handler {
+	h := handler{tokens: tokens}
+
+	routes.GET(JWKSPath, h.JWKS, Public)
+
+	return &h
+}
+
+func (h *handler) JWKS(c echo.Context) error {
+	// Partners may cache the keys for a while, but not longer than a retired key is kept.
+	c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(JWKSMaxAge.Seconds())))
+	return c.JSON(http.StatusOK, h.tokens.JWKS())
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JWK is the public part of a key as published in the JWKS document (RFC 7517).
+type JWK struct {
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	Alg     string `json:"alg"`
+	KeyID   string `json:"kid"`
+	// N and E are the modulus and exponent of RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve and X are the curve and public key of Ed25519 keys.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k Key) JWK() (JWK, bool) {
+	jwk := JWK{Use: "sig", Alg: k.Method.Alg(), KeyID: k.ID}
+
+	switch public := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+// JWKS publishes the public keys, so partners can verify our tokens on their own.
+func (j *JWT) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range j.PublicKeys() {
+		if jwk, ok := key.JWK(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+
+	return jwks
+}
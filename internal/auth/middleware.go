@@ -1,63 +1,85 @@
 package auth
 
 import (
-	"errors"
-	"github.com/golang-jwt/jwt/v4"
+	"context"
 	"github.com/labstack/echo/v4"
-	"github.com/spf13/viper"
+	"github.com/labstack/gommon/log"
 	"net/http"
+	"strings"
 )
 
-var allowList = map[string]bool{
-	"/register": true,
-	"/login":    true,
+const (
+	WarnUnauthorized  = "Please login again"
+	WarnForbidden     = "You have no authority"
+	WarnSystemFailure = "There is something wrong. Please try again later"
+)
+
+// SessionChecker tells whether the session a token was issued for has not
+// been revoked or expired.
+type SessionChecker interface {
+	IsActive(ctx context.Context, sessionID string) (bool, error)
 }
 
-func TokenMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	jwtSecretKey := viper.GetString("ONLINE_TICKET_GO_JWTKEY")
+// TokenMiddleware applies the policy of the route. It accepts the access
+// token of API clients from the Authorization header and of browsers from the
+// token cookie. Every rejected token gets the same 401 answer, so clients
+// cannot tell why it was rejected.
+func TokenMiddleware(routes *Routes, tokens *JWT, sessions SessionChecker) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			policy := routes.Policy(c.Request().Method, c.Path())
+			if policy.IsPublic() {
+				return next(c)
+			}
 
-	return func(c echo.Context) error {
-		if _, ok := allowList[c.Request().RequestURI]; ok {
-			return next(c)
-		}
+			token := tokenOf(c)
+			if token == "" {
+				return unauthorized(c)
+			}
 
-		cookie, err := c.Cookie("token")
-		if err != nil {
-			return c.String(http.StatusBadRequest, err.Error())
-		}
+			claim, err := tokens.Parse(token)
+			if err != nil {
+				log.Debugf("token rejected: %v", err)
+				return unauthorized(c)
+			}
 
-		token := cookie.Value
+			active, err := sessions.IsActive(c.Request().Context(), claim.SessionID)
+			if err != nil {
+				return c.String(http.StatusInternalServerError, WarnSystemFailure)
+			}
+			if !active {
+				return unauthorized(c)
+			}
 
-		claim := Claims{}
-		parsedTokenInfo, err := jwt.ParseWithClaims(token, &claim, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecretKey), nil
-		})
-		if err != nil {
-			if errors.Is(err, jwt.ErrSignatureInvalid) {
-				return c.String(http.StatusUnauthorized, "Please login again")
+			if !policy.Allows(*claim) {
+				return c.String(http.StatusForbidden, WarnForbidden)
 			}
 
-			return c.String(http.StatusUnauthorized, "Please login again")
-		}
+			c.Set("claim", *claim)
 
-		if !parsedTokenInfo.Valid {
-			return c.String(http.StatusForbidden, "Invalid token")
+			return next(c)
 		}
-
-		c.Set("claim", claim)
-
-		return next(c)
 	}
 }
 
-func AdminMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		claim, _ := c.Get("claim").(Claims)
-
-		if claim.IsNotAdmin() {
-			return c.String(http.StatusForbidden, "You have no authority")
+func tokenOf(c echo.Context) string {
+	if header := c.Request().Header.Get(echo.HeaderAuthorization); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return ""
 		}
+		return strings.TrimSpace(token)
+	}
 
-		return next(c)
+	cookie, err := c.Cookie("token")
+	if err != nil {
+		return ""
 	}
+
+	return cookie.Value
+}
+
+func unauthorized(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return c.String(http.StatusUnauthorized, WarnUnauthorized)
 }
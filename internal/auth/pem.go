@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPEM      = errors.New("file does not hold a PEM encoded key")
+	ErrUnsupportedKey  = errors.New("only RSA and Ed25519 keys are supported")
+	ErrNoPrivateKeyPEM = errors.New("key directory does not hold any private key")
+)
+
+// ParseKeyPEM reads an RSA or Ed25519 key. Private keys sign and verify,
+// public keys only verify.
+func ParseKeyPEM(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, ErrInvalidPEM
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return Key{}, ErrUnsupportedKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, k), nil
+	case *rsa.PublicKey:
+		return NewRSAPublicKey(id, k), nil
+	case ed25519.PrivateKey:
+		return NewEdDSAKey(id, k), nil
+	case ed25519.PublicKey:
+		return NewEdDSAPublicKey(id, k), nil
+	default:
+		return Key{}, ErrUnsupportedKey
+	}
+}
+
+// LoadKeyDir reads every <kid>.pem file of the directory. The private key
+// with the greatest kid signs, so naming keys by date, e.g. 2023-06.pem,
+// makes the newest key take over. A key file newer than KeyPublishDelay does
+// not sign yet when an older private key is there, so a restart does not
+// sign with a key partners have not seen. The rest only verify.
+func LoadKeyDir(dir string) (Key, []Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return Key{}, nil, err
+	}
+	sort.Strings(paths)
+
+	var keys []Key
+	signing, published := -1, -1
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return Key{}, nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Key{}, nil, err
+		}
+
+		key, err := ParseKeyPEM(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return Key{}, nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		keys = append(keys, key)
+		if key.CanSign() {
+			signing = len(keys) - 1
+			if time.Since(info.ModTime()) >= KeyPublishDelay {
+				published = signing
+			}
+		}
+	}
+
+	if signing < 0 {
+		return Key{}, nil, ErrNoPrivateKeyPEM
+	}
+	if published >= 0 {
+		signing = published
+	}
+
+	return keys[signing], append(keys[:signing:signing], keys[signing+1:]...), nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+// Role groups the permissions of a job, e.g. the operations team.
+type Role string
+
+const (
+	AdminRole          Role = "admin"
+	OpsRole            Role = "ops"
+	FinanceRole        Role = "finance"
+	SupportRole        Role = "support"
+	DriverRole         Role = "driver"
+	CorporateAdminRole Role = "corporate_admin"
+	// CustomerRole is held by every individual and corporate user.
+	CustomerRole Role = "customer"
+)
+
+// Permission allows an action, written as resource:action.
+type Permission string
+
+const (
+	TripCreate    Permission = "trip:create"
+	TripUpdate    Permission = "trip:update"
+	TripCancel    Permission = "trip:cancel"
+	TripHistory   Permission = "trip:history"
+	StationCreate Permission = "station:create"
+
+	FleetRead   Permission = "fleet:read"
+	FleetManage Permission = "fleet:manage"
+
+	ReportSales      Permission = "report:sales"
+	ReportRevenue    Permission = "report:revenue"
+	ReportAttendance Permission = "report:attendance"
+
+	TicketPurchase Permission = "ticket:purchase"
+	// TicketManage allows changing the own tickets, TicketManageAny the tickets of everyone.
+	TicketManage    Permission = "ticket:manage"
+	TicketManageAny Permission = "ticket:manage-any"
+	TicketReadAny   Permission = "ticket:read-any"
+	TicketRefund    Permission = "ticket:refund"
+	TicketBoard     Permission = "ticket:board"
+
+	WalletCompensate Permission = "wallet:compensate"
+	LoyaltyManage    Permission = "loyalty:manage"
+
+	// AccountManage allows the self-service of the own account, e.g. the wallet or saved travellers.
+	AccountManage Permission = "account:manage"
+	UserManage    Permission = "user:manage"
+	RoleManage    Permission = "role:manage"
+)
+
+var Permissions = []Permission{
+	TripCreate, TripUpdate, TripCancel, TripHistory, StationCreate,
+	FleetRead, FleetManage,
+	ReportSales, ReportRevenue, ReportAttendance,
+	TicketPurchase, TicketManage, TicketManageAny, TicketReadAny, TicketRefund, TicketBoard,
+	WalletCompensate, LoyaltyManage,
+	AccountManage, UserManage, RoleManage,
+}
+
+var customerPermissions = []Permission{TicketPurchase, TicketManage, AccountManage}
+
+// DefaultRolePermissions are what the roles permit until they are changed.
+var DefaultRolePermissions = map[Role][]Permission{
+	AdminRole:          Permissions,
+	OpsRole:            {TripCreate, TripUpdate, TripCancel, TripHistory, StationCreate, FleetRead, FleetManage, ReportSales, ReportAttendance},
+	FinanceRole:        {ReportSales, ReportRevenue, TicketReadAny, WalletCompensate},
+	SupportRole:        {TicketReadAny, TicketManageAny, TicketRefund, WalletCompensate, LoyaltyManage},
+	DriverRole:         {TicketBoard, FleetRead, ReportAttendance},
+	CorporateAdminRole: customerPermissions,
+	CustomerRole:       customerPermissions,
+}
+
+func (p Permission) IsValid() bool {
+	for _, permission := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ImplicitRoles are held by every user of the type without being assigned.
+func ImplicitRoles(userType UserType) []Role {
+	switch userType {
+	case Admin:
+		return []Role{AdminRole}
+	case IndividualUser, CorporateUser:
+		return []Role{CustomerRole}
+	default:
+		return nil
+	}
+}
+
+// Can reports whether the roles of the token permit the action.
+func (c *Claims) Can(permission Permission) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// Policy tells who may call a route.
+type Policy struct {
+	public      bool
+	permissions []Permission
+}
+
+var (
+	// Public routes are open to everyone, e.g. login or trip search.
+	Public = Policy{public: true}
+	// Authenticated routes are open to every logged in user.
+	Authenticated = Policy{}
+)
+
+// RequirePermission opens a route only to logged in users whose roles permit
+// any of the permissions, e.g. changing the own tickets or the tickets of everyone.
+func RequirePermission(permissions ...Permission) Policy {
+	return Policy{permissions: permissions}
+}
+
+func (p Policy) IsPublic() bool {
+	return p.public
+}
+
+func (p Policy) Allows(claims Claims) bool {
+	if p.public || len(p.permissions) == 0 {
+		return true
+	}
+
+	for _, permission := range p.permissions {
+		if claims.Can(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Routes registers the handlers on echo together with their policy, so the
+// policy of a request is found by its method and route pattern.
+type Routes struct {
+	echo     *echo.Echo
+	policies map[string]Policy
+}
+
+func NewRoutes(e *echo.Echo) *Routes {
+	return &Routes{echo: e, policies: make(map[string]Policy)}
+}
+
+func (r *Routes) Add(method, path string, handler echo.HandlerFunc, policy Policy) *echo.Route {
+	r.policies[routeKey(method, path)] = policy
+	return r.echo.Add(method, path, handler)
+}
+
+func (r *Routes) GET(path string, handler echo.HandlerFunc, policy Policy) *echo.Route {
+	return r.Add(http.MethodGet, path, handler, policy)
+}
+
+func (r *Routes) POST(path string, handler echo.HandlerFunc, policy Policy) *echo.Route {
+	return r.Add(http.MethodPost, path, handler, policy)
+}
+
+func (r *Routes) PUT(path string, handler echo.HandlerFunc, policy Policy) *echo.Route {
+	return r.Add(http.MethodPut, path, handler, policy)
+}
+
+func (r *Routes) PATCH(path string, handler echo.HandlerFunc, policy Policy) *echo.Route {
+	return r.Add(http.MethodPatch, path, handler, policy)
+}
+
+func (r *Routes) DELETE(path string, handler echo.HandlerFunc, policy Policy) *echo.Route {
+	return r.Add(http.MethodDelete, path, handler, policy)
+}
+
+// Policy returns the policy of the route. Routes registered without one, or
+// not found at all, need a logged in user.
+func (r *Routes) Policy(method, path string) Policy {
+	if policy, ok := r.policies[routeKey(method, path)]; ok {
+		return policy
+	}
+	return Authenticated
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type activeSessions struct{}
+
+func (activeSessions) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	return true, nil
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	tokens := NewJWT(DefaultIssuer, DefaultAudience, NewHMACKey("test", []byte("secret")))
+	sign := func(permissions ...Permission) string {
+		token, err := tokens.Sign(Claims{UserID: 1, SessionID: "abc", Permissions: permissions}, time.Now(), time.Hour)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return token
+	}
+
+	e := echo.New()
+	routes := NewRoutes(e)
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	routes.POST("/login", ok, Public)
+	routes.GET("/trips/:id/changes", ok, RequirePermission(TripHistory))
+	routes.GET("/tickets", ok, RequirePermission(TicketManage, TicketReadAny))
+	routes.GET("/sessions", ok, Authenticated)
+	e.Use(TokenMiddleware(routes, tokens, activeSessions{}))
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		token    string
+		expected int
+	}{
+		{name: "public route with query", method: http.MethodPost, target: "/login?next=/tickets", expected: http.StatusOK},
+		{name: "authenticated without token", method: http.MethodGet, target: "/sessions", expected: http.StatusUnauthorized},
+		{name: "authenticated with token", method: http.MethodGet, target: "/sessions", token: sign(), expected: http.StatusOK},
+		{name: "one of the permissions", method: http.MethodGet, target: "/tickets", token: sign(TicketReadAny), expected: http.StatusOK},
+		{name: "without permission", method: http.MethodGet, target: "/trips/3/changes", token: sign(TicketManage), expected: http.StatusForbidden},
+		{name: "with permission", method: http.MethodGet, target: "/trips/3/changes", token: sign(TripHistory), expected: http.StatusOK},
+		{name: "invalid token", method: http.MethodGet, target: "/tickets", token: "abc", expected: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expected {
+				t.Errorf("status = %v, want %v", rec.Code, tt.expected)
+			}
+		})
+	}
+}
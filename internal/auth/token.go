@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/gommon/log"
+	"sync"
+	"time"
+)
+
+// KeyRetention is how long a key which stopped signing still verifies
+// tokens. It outlives the access tokens signed right before a rotation.
+const KeyRetention = time.Hour
+
+// JWKSMaxAge is how long partners may cache the published keys.
+const JWKSMaxAge = 15 * time.Minute
+
+// KeyPublishDelay is how long a new asymmetric key is published before it
+// signs, so partners who cached the keys right before it appeared know it by
+// the time they see tokens signed by it.
+const KeyPublishDelay = JWKSMaxAge
+
+var (
+	ErrNoSigningKey        = errors.New("there is no key to sign tokens with")
+	ErrUnknownKey          = errors.New("token is signed with an unknown key")
+	ErrUnexpectedAlgorithm = errors.New("token is not signed with the algorithm of its key")
+	ErrInvalidIssuer       = errors.New("token is not issued by us")
+	ErrInvalidAudience     = errors.New("token is not issued for us")
+	ErrMissingSession      = errors.New("token does not belong to a session")
+)
+
+// validMethods are the only signing algorithms accepted, so a token can never
+// choose to be checked with "none" or with a public key used as HMAC secret.
+var validMethods = []string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// Key signs and verifies tokens carrying its ID in the kid header. Keys
+// without a signing part only verify tokens signed before a rotation.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACKey(id string, secret []byte) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewHMACVerifyKey only verifies tokens signed with a secret rotated out.
+func NewHMACVerifyKey(id string, secret []byte) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, verifyKey: secret}
+}
+
+func NewRSAKey(id string, privateKey *rsa.PrivateKey) Key {
+	return Key{ID: id, Method: jwt.SigningMethodRS256, signKey: privateKey, verifyKey: &privateKey.PublicKey}
+}
+
+// NewRSAPublicKey verifies tokens signed by another party holding the private key.
+func NewRSAPublicKey(id string, publicKey *rsa.PublicKey) Key {
+	return Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: publicKey}
+}
+
+func NewEdDSAKey(id string, privateKey ed25519.PrivateKey) Key {
+	return Key{ID: id, Method: jwt.SigningMethodEdDSA, signKey: privateKey, verifyKey: privateKey.Public()}
+}
+
+func NewEdDSAPublicKey(id string, publicKey ed25519.PublicKey) Key {
+	return Key{ID: id, Method: jwt.SigningMethodEdDSA, verifyKey: publicKey}
+}
+
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// IsAsymmetric reports whether the verifying part of the key may be published.
+func (k Key) IsAsymmetric() bool {
+	return k.Method != jwt.SigningMethodHS256
+}
+
+// JWT signs the tokens of our users and tells whether a token is one of them.
+// Tokens are signed with the signing key and verified with any of the keys,
+// so old keys stay active until the tokens they signed expire.
+type JWT struct {
+	Issuer   string
+	Audience string
+
+	mu         sync.RWMutex
+	signingKey string
+	keys       map[string]Key
+	retiredAt  map[string]time.Time
+	seenAt     map[string]time.Time
+	// keyDir is where the keys are reloaded from by Run, when they are kept in PEM files.
+	keyDir string
+}
+
+func NewJWT(issuer, audience string, signingKey Key, verifyKeys ...Key) *JWT {
+	j := &JWT{Issuer: issuer, Audience: audience, retiredAt: make(map[string]time.Time), seenAt: make(map[string]time.Time)}
+	j.setKeys(signingKey, verifyKeys, time.Now())
+	return j
+}
+
+// Rotate starts signing with the signing key. An asymmetric key is only
+// published for KeyPublishDelay first while the current key keeps signing.
+// Keys which are not given anymore keep verifying tokens for KeyRetention.
+func (j *JWT) Rotate(signingKey Key, verifyKeys []Key, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.setKeys(signingKey, verifyKeys, now)
+}
+
+func (j *JWT) setKeys(signingKey Key, verifyKeys []Key, now time.Time) {
+	keys := map[string]Key{signingKey.ID: signingKey}
+	for _, key := range verifyKeys {
+		if _, ok := keys[key.ID]; !ok {
+			keys[key.ID] = key
+		}
+	}
+
+	for id, key := range j.keys {
+		if _, ok := keys[id]; ok {
+			delete(j.retiredAt, id)
+			continue
+		}
+		if _, ok := j.retiredAt[id]; !ok {
+			j.retiredAt[id] = now
+		}
+		if now.Sub(j.retiredAt[id]) < KeyRetention {
+			keys[id] = Key{ID: key.ID, Method: key.Method, verifyKey: key.verifyKey}
+			continue
+		}
+		delete(j.retiredAt, id)
+	}
+
+	for id := range j.seenAt {
+		if _, ok := keys[id]; !ok {
+			delete(j.seenAt, id)
+		}
+	}
+	for id := range keys {
+		if _, ok := j.seenAt[id]; !ok {
+			j.seenAt[id] = now
+		}
+	}
+
+	signing := signingKey.ID
+	if current, ok := keys[j.signingKey]; ok && current.CanSign() && signingKey.IsAsymmetric() &&
+		now.Sub(j.seenAt[signing]) < KeyPublishDelay {
+		signing = j.signingKey
+	}
+
+	j.signingKey = signing
+	j.keys = keys
+}
+
+// Sign fills the issuer, audience and validity of the claims and signs them.
+func (j *JWT) Sign(claims Claims, now time.Time, ttl time.Duration) (string, error) {
+	j.mu.RLock()
+	key, ok := j.keys[j.signingKey]
+	j.mu.RUnlock()
+
+	if !ok || !key.CanSign() {
+		return "", ErrNoSigningKey
+	}
+
+	claims.Issuer = j.Issuer
+	claims.Audience = jwt.ClaimStrings{j.Audience}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.signKey)
+}
+
+// Parse verifies the signature, the expiry, nbf, issuer and audience of the token.
+func (j *JWT) Parse(tokenString string) (*Claims, error) {
+	claims := Claims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, &claims, j.keyOf, jwt.WithValidMethods(validMethods))
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.VerifyIssuer(j.Issuer, true) {
+		return nil, ErrInvalidIssuer
+	}
+	if !claims.VerifyAudience(j.Audience, true) {
+		return nil, ErrInvalidAudience
+	}
+	if claims.SessionID == "" {
+		return nil, ErrMissingSession
+	}
+
+	return &claims, nil
+}
+
+// PublicKeys returns the keys whose verifying part may be published.
+func (j *JWT) PublicKeys() []Key {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	var keys []Key
+	for _, key := range j.keys {
+		if key.IsAsymmetric() {
+			keys = append(keys, Key{ID: key.ID, Method: key.Method, verifyKey: key.verifyKey})
+		}
+	}
+
+	return keys
+}
+
+// Run reloads the keys from their directory every interval, so a new key
+// file takes over signing without a restart.
+func (j *JWT) Run(ctx context.Context, interval time.Duration) {
+	if j.keyDir == "" {
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultRotationInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			signingKey, verifyKeys, err := LoadKeyDir(j.keyDir)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			j.Rotate(signingKey, verifyKeys, time.Now())
+		}
+	}
+}
+
+// keyOf picks the key by the kid header of the token and makes sure the
+// token is signed with the algorithm of that key.
+func (j *JWT) keyOf(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	j.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedAlgorithm
+	}
+
+	return key.verifyKey, nil
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJWT_Parse(t *testing.T) {
+	now := time.Now()
+	current := NewHMACKey("2023-06", []byte("current-secret"))
+	previous := NewHMACKey("2023-01", []byte("previous-secret"))
+	tokens := NewJWT(DefaultIssuer, DefaultAudience, current, NewHMACVerifyKey(previous.ID, []byte("previous-secret")))
+	claims := Claims{UserID: 7, UserType: IndividualUser, SessionID: "abc"}
+
+	sign := func(j *JWT, issuedAt time.Time) string {
+		token, err := j.Sign(claims, issuedAt, time.Hour)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return token
+	}
+
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "signed with current key", token: sign(tokens, now), wantErr: false},
+		{name: "signed with rotated key", token: sign(NewJWT(DefaultIssuer, DefaultAudience, previous), now), wantErr: false},
+		{name: "unknown key", token: sign(NewJWT(DefaultIssuer, DefaultAudience, NewHMACKey("other", []byte("x"))), now), wantErr: true},
+		{name: "another issuer", token: sign(NewJWT("someone", DefaultAudience, current), now), wantErr: true},
+		{name: "another audience", token: sign(NewJWT(DefaultIssuer, "partner", current), now), wantErr: true},
+		{name: "not valid yet", token: sign(tokens, now.Add(time.Hour)), wantErr: true},
+		{name: "expired", token: sign(tokens, now.Add(-2*time.Hour)), wantErr: true},
+		{name: "unsigned", token: none, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokens.Parse(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.UserID != claims.UserID || got.SessionID != claims.SessionID) {
+				t.Errorf("Parse() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestJWT_Rotate(t *testing.T) {
+	now := time.Now()
+	first := NewHMACKey("first", []byte("first-secret"))
+	second := NewHMACKey("second", []byte("second-secret"))
+	tokens := NewJWT(DefaultIssuer, DefaultAudience, first)
+
+	signedByFirst, err := tokens.Sign(Claims{SessionID: "abc"}, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	tokens.Rotate(second, nil, now)
+	if _, err = tokens.Parse(signedByFirst); err != nil {
+		t.Errorf("Parse() of retired key error = %v, want nil", err)
+	}
+
+	tokens.Rotate(second, nil, now.Add(KeyRetention))
+	if _, err = tokens.Parse(signedByFirst); err == nil {
+		t.Errorf("Parse() of dropped key error = nil, want error")
+	}
+}
+
+func TestJWT_Rotate_PublishDelay(t *testing.T) {
+	now := time.Now()
+	first := newEdDSAKey(t, "2023-05")
+	second := newEdDSAKey(t, "2023-06")
+	tokens := NewJWT(DefaultIssuer, DefaultAudience, first)
+
+	tokens.Rotate(second, []Key{first}, now)
+	if kid := signingKeyID(t, tokens, now); kid != first.ID {
+		t.Errorf("signed by %v right after the new key appeared, want %v", kid, first.ID)
+	}
+	if jwks := tokens.JWKS(); len(jwks.Keys) != 2 {
+		t.Errorf("JWKS() = %+v, want the new key published", jwks)
+	}
+
+	tokens.Rotate(second, []Key{first}, now.Add(KeyPublishDelay))
+	if kid := signingKeyID(t, tokens, now); kid != second.ID {
+		t.Errorf("signed by %v after the key was published, want %v", kid, second.ID)
+	}
+}
+
+func TestLoadKeyDir(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * KeyPublishDelay)
+	writeKeyPEM(t, dir, "2023-05", old)
+	writeKeyPEM(t, dir, "2023-06", time.Now())
+
+	signingKey, verifyKeys, err := LoadKeyDir(dir)
+	if err != nil {
+		t.Fatalf("LoadKeyDir() error = %v", err)
+	}
+	if signingKey.ID != "2023-05" || len(verifyKeys) != 1 || verifyKeys[0].ID != "2023-06" {
+		t.Errorf("LoadKeyDir() = %v, %v, want the new key to wait until it is published", signingKey.ID, verifyKeys)
+	}
+
+	if err = os.Chtimes(filepath.Join(dir, "2023-06.pem"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if signingKey, _, err = LoadKeyDir(dir); err != nil || signingKey.ID != "2023-06" {
+		t.Errorf("LoadKeyDir() = %v, %v, want 2023-06 to sign", signingKey.ID, err)
+	}
+}
+
+func newEdDSAKey(t *testing.T, id string) Key {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEdDSAKey(id, privateKey)
+}
+
+func writeKeyPEM(t *testing.T, dir, id string, modTime time.Time) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, id+".pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func signingKeyID(t *testing.T, tokens *JWT, now time.Time) string {
+	signed, err := tokens.Sign(Claims{SessionID: "abc"}, now, time.Hour)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(signed, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParseKeyPEM("2023-06", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParseKeyPEM() error = %v", err)
+	}
+	if !key.CanSign() || key.Method.Alg() != "EdDSA" {
+		t.Fatalf("ParseKeyPEM() = %v key, can sign %v", key.Method.Alg(), key.CanSign())
+	}
+
+	tokens := NewJWT(DefaultIssuer, DefaultAudience, key)
+	signed, err := tokens.Sign(Claims{SessionID: "abc"}, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err = tokens.Parse(signed); err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+
+	jwks := tokens.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "2023-06" || jwks.Keys[0].Curve != "Ed25519" {
+		t.Errorf("JWKS() = %+v", jwks)
+	}
+
+	if _, err = ParseKeyPEM("x", []byte("not a key")); err != ErrInvalidPEM {
+		t.Errorf("ParseKeyPEM() error = %v, want %v", err, ErrInvalidPEM)
+	}
+}
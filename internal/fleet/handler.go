@@ -0,0 +1,155 @@
+package fleet
+
+import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+const (
+	WarnMessageWhenThereAreEmptyBlank = "Please fill required area"
+	WarnMessageWhenInvalidType        = "Please enter valid Vehicle Type"
+	WarnMessageWhenInvalidSeatLayout  = "Please enter valid seat layout"
+	WarnMessageWhenInvalidWagons      = "Please enter valid wagons with unique numbers, class and seat layout"
+	WarnMessageWhenInvalidDeck        = "Please enter valid vehicle deck capacity"
+	WarnMessageWhenInvalidAmenity     = "Please enter valid amenities"
+	WarnMessageWhenInvalidID          = "Please enter valid ID"
+	WarnMessageWhenDuplicated         = "A vehicle with this registration is already created"
+	WarnMessageWhenVehicleNotExist    = "This vehicle does not exist or it is deleted already."
+	WarnMessageWhenVehicleInUse       = "This vehicle is assigned to upcoming trips. Please assign them another vehicle first"
+	WarnInternalError                 = "Somethings go wrong. Please try later again"
+)
+
+type handler struct {
+	fleetService Service
+}
+
+func NewHandler(routes *auth.Routes, fleetService Service) *handler {
+	h := handler{fleetService: fleetService}
+
+	routes.POST("/fleet/vehicles", h.CreateVehicle, auth.RequirePermission(auth.FleetManage))
+	routes.GET("/fleet/vehicles", h.ListVehicles, auth.RequirePermission(auth.FleetRead))
+	routes.GET("/fleet/vehicles/:id", h.GetVehicle, auth.RequirePermission(auth.FleetRead))
+	routes.PUT("/fleet/vehicles/:id", h.UpdateVehicle, auth.RequirePermission(auth.FleetManage))
+	routes.DELETE("/fleet/vehicles/:id", h.DeleteVehicle, auth.RequirePermission(auth.FleetManage))
+
+	return &h
+}
+
+func (h *handler) CreateVehicle(c echo.Context) error {
+	vehicle := new(Vehicle)
+	if err := c.Bind(vehicle); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if vehicle.CheckFieldsEmpty() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenThereAreEmptyBlank)
+	}
+
+	if vehicle.IsInvalidType() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidType)
+	}
+
+	vehicle.ApplyDefaults()
+
+	if err := vehicle.Validate(); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidWagons):
+			return c.String(http.StatusBadRequest, WarnMessageWhenInvalidWagons)
+		case errors.Is(err, ErrInvalidDeck):
+			return c.String(http.StatusBadRequest, WarnMessageWhenInvalidDeck)
+		default:
+			return c.String(http.StatusBadRequest, WarnMessageWhenInvalidSeatLayout)
+		}
+	}
+
+	if vehicle.IsInvalidAmenity() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidAmenity)
+	}
+
+	if err := h.fleetService.CreateVehicle(c.Request().Context(), vehicle); err != nil {
+		if errors.Is(err, ErrDuplicated) {
+			return c.String(http.StatusBadRequest, WarnMessageWhenDuplicated)
+		}
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.JSON(http.StatusCreated, vehicle)
+}
+
+func (h *handler) ListVehicles(c echo.Context) error {
+	vehicles, err := h.fleetService.ListVehicles(c.Request().Context(), Type(c.QueryParam("type")))
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.JSON(http.StatusOK, vehicles)
+}
+
+func (h *handler) GetVehicle(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidID)
+	}
+
+	vehicle, err := h.fleetService.GetVehicle(c.Request().Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, ErrVehicleNotExist) {
+			return c.String(http.StatusNotFound, WarnMessageWhenVehicleNotExist)
+		}
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.JSON(http.StatusOK, vehicle)
+}
+
+func (h *handler) UpdateVehicle(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidID)
+	}
+
+	vehicle := new(Vehicle)
+	if err = c.Bind(vehicle); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	vehicle.ID = uint(id)
+
+	if vehicle.Model == "" {
+		return c.String(http.StatusBadRequest, WarnMessageWhenThereAreEmptyBlank)
+	}
+
+	if vehicle.IsInvalidAmenity() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidAmenity)
+	}
+
+	if err = h.fleetService.UpdateVehicle(c.Request().Context(), vehicle); err != nil {
+		if errors.Is(err, ErrVehicleNotExist) {
+			return c.String(http.StatusNotFound, WarnMessageWhenVehicleNotExist)
+		}
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *handler) DeleteVehicle(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidID)
+	}
+
+	if err = h.fleetService.DeleteVehicle(c.Request().Context(), uint(id)); err != nil {
+		switch {
+		case errors.Is(err, ErrVehicleNotExist):
+			return c.String(http.StatusNotFound, WarnMessageWhenVehicleNotExist)
+		case errors.Is(err, ErrVehicleInUse):
+			return c.String(http.StatusConflict, WarnMessageWhenVehicleInUse)
+		}
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,178 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type DocumentRequirement string
+
+const (
+	NoDocument DocumentRequirement = "none"
+	// IdentityDocument asks for the type and number of a national ID or passport.
+	IdentityDocument DocumentRequirement = "identity"
+	// TravelDocument also asks for the date of birth and the nationality.
+	TravelDocument DocumentRequirement = "travel"
+)
+
+var (
+	ErrInvalidSeatLayout = errors.New("seat layout is invalid")
+	ErrInvalidWagons     = errors.New("wagons are invalid")
+	ErrInvalidDeck       = errors.New("vehicle deck capacity is invalid")
+)
+
+// Kind holds the rules of a vehicle type. New types are supported by
+// registering a Kind, without touching trip or ticket code.
+type Kind struct {
+	Type Type
+	// Documents tells which identity details a passenger should provide.
+	Documents DocumentRequirement
+	// DefaultLayout is used when a vehicle is created without a seat layout.
+	DefaultLayout SeatLayout
+	// Validate checks type specific fields of a vehicle.
+	Validate func(v *Vehicle) error
+	// Capacity returns the number of seats sold for the vehicle.
+	Capacity func(v *Vehicle) uint
+	// Seats returns the seats sold for the vehicle.
+	Seats func(v *Vehicle) []Seat
+}
+
+var (
+	kindsMu sync.RWMutex
+	kinds   = make(map[Type]Kind)
+)
+
+func RegisterKind(kind Kind) {
+	if kind.Validate == nil {
+		kind.Validate = validateSeatLayout
+	}
+	if kind.Capacity == nil {
+		kind.Capacity = seatLayoutCapacity
+	}
+	if kind.Seats == nil {
+		kind.Seats = seatLayoutSeats
+	}
+	if kind.Documents == "" {
+		kind.Documents = NoDocument
+	}
+
+	kindsMu.Lock()
+	defer kindsMu.Unlock()
+	kinds[kind.Type] = kind
+}
+
+func LookupKind(vehicleType Type) (Kind, bool) {
+	kindsMu.RLock()
+	defer kindsMu.RUnlock()
+	kind, ok := kinds[vehicleType]
+	return kind, ok
+}
+
+func IsValidType(vehicleType Type) bool {
+	_, ok := LookupKind(vehicleType)
+	return ok
+}
+
+// RequiresDocument reports whether passengers of the vehicle type should
+// present an identity document.
+func RequiresDocument(vehicleType Type) bool {
+	return DocumentsOf(vehicleType) != NoDocument
+}
+
+// DocumentsOf returns the identity details asked from passengers of the vehicle type.
+func DocumentsOf(vehicleType Type) DocumentRequirement {
+	kind, ok := LookupKind(vehicleType)
+	if !ok {
+		return NoDocument
+	}
+	return kind.Documents
+}
+
+func init() {
+	RegisterKind(Kind{
+		Type:          Bus,
+		DefaultLayout: SeatLayout{Rows: 15, SeatsPerRow: 3},
+	})
+	RegisterKind(Kind{
+		Type:          Flight,
+		Documents:     TravelDocument,
+		DefaultLayout: SeatLayout{Rows: 32, SeatsPerRow: 6, ExcludedSeats: []string{"1D", "1E", "1F"}},
+	})
+	RegisterKind(Kind{
+		Type:     Train,
+		Validate: validateWagons,
+		Capacity: wagonCapacity,
+		Seats:    wagonSeats,
+	})
+	RegisterKind(Kind{
+		Type:          Ferry,
+		Documents:     IdentityDocument,
+		DefaultLayout: SeatLayout{Rows: 50, SeatsPerRow: 8},
+		Validate:      validateFerry,
+	})
+}
+
+func validateSeatLayout(v *Vehicle) error {
+	if !v.SeatLayout.IsValid() {
+		return ErrInvalidSeatLayout
+	}
+	return nil
+}
+
+func seatLayoutCapacity(v *Vehicle) uint {
+	return v.SeatLayout.Capacity()
+}
+
+func seatLayoutSeats(v *Vehicle) []Seat {
+	numbers := v.SeatLayout.SeatNumbers()
+	seats := make([]Seat, 0, len(numbers))
+	for _, number := range numbers {
+		seats = append(seats, Seat{Number: number})
+	}
+	return seats
+}
+
+func validateWagons(v *Vehicle) error {
+	if len(v.Wagons) == 0 {
+		return ErrInvalidWagons
+	}
+
+	numbers := make(map[int]bool, len(v.Wagons))
+	for _, wagon := range v.Wagons {
+		if wagon.Number <= 0 || numbers[wagon.Number] || !wagon.SeatLayout.IsValid() || wagon.IsInvalidClass() {
+			return ErrInvalidWagons
+		}
+		numbers[wagon.Number] = true
+	}
+
+	return nil
+}
+
+func wagonCapacity(v *Vehicle) uint {
+	var capacity uint
+	for _, wagon := range v.Wagons {
+		capacity += wagon.SeatLayout.Capacity()
+	}
+	return capacity
+}
+
+func wagonSeats(v *Vehicle) []Seat {
+	seats := make([]Seat, 0, wagonCapacity(v))
+	for _, wagon := range v.Wagons {
+		for _, seat := range wagon.SeatLayout.SeatNumbers() {
+			seats = append(seats, Seat{Number: fmt.Sprintf("W%d-%s", wagon.Number, seat), Class: wagon.Class})
+		}
+	}
+	return seats
+}
+
+func validateFerry(v *Vehicle) error {
+	if err := validateSeatLayout(v); err != nil {
+		return err
+	}
+	if v.DeckCapacity == 0 {
+		return ErrInvalidDeck
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package fleet
+
+import "gorm.io/gorm"
+
+// MigrateRegistration drops the unique constraint registrations had before it
+// became a unique index of vehicles which are not deleted. AutoMigrate adds
+// the index but leaves the old constraint as it is.
+func MigrateRegistration(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, constraint := range []string{"vehicles_registration_key", "idx_vehicles_registration"} {
+			if err := tx.Exec("ALTER TABLE vehicles DROP CONSTRAINT IF EXISTS " + constraint).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
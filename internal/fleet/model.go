@@ -0,0 +1,211 @@
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Type string
+
+const (
+	Bus    Type = "Bus"
+	Flight Type = "Flight"
+	Train  Type = "Train"
+	Ferry  Type = "Ferry"
+)
+
+type WagonClass string
+
+const (
+	FirstClass  WagonClass = "first"
+	SecondClass WagonClass = "second"
+	Sleeper     WagonClass = "sleeper"
+)
+
+var ErrInvalidType = errors.New("vehicle type is invalid")
+
+type Amenity string
+
+const (
+	Wifi          Amenity = "wifi"
+	PowerOutlet   Amenity = "power_outlet"
+	Toilet        Amenity = "toilet"
+	Catering      Amenity = "catering"
+	Entertainment Amenity = "entertainment"
+)
+
+// Vehicle is a concrete bus, aircraft, train or ferry which can be assigned
+// to trips. Registration holds the plate, tail or fleet number of it.
+// Trains sell the seats of their wagons while ferries additionally carry
+// cars on their vehicle deck. Registration is unique among vehicles which are
+// not deleted, so the number of a retired vehicle can be registered again.
+type Vehicle struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Type         Type       `gorm:"not null" json:"type"`
+	Registration string     `gorm:"not null;uniqueIndex:idx_vehicle_registration,where:deleted_at IS NULL" json:"registration"`
+	Model        string     `gorm:"not null" json:"model"`
+	SeatLayout   SeatLayout `gorm:"serializer:json;not null" json:"seat_layout"`
+	Wagons       []Wagon    `gorm:"serializer:json" json:"wagons,omitempty"`
+	DeckCapacity uint       `gorm:"not null;default:0" json:"deck_capacity,omitempty"`
+	Amenities    []Amenity  `gorm:"serializer:json" json:"amenities"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+// SeatLayout describes a grid of seats numbered like 1A, 1B, 2A...
+// ExcludedSeats are positions of the grid which are not sold, e.g. a driver seat.
+type SeatLayout struct {
+	Rows          int      `json:"rows"`
+	SeatsPerRow   int      `json:"seats_per_row"`
+	ExcludedSeats []string `json:"excluded_seats"`
+}
+
+// Seat is a seat sold on a vehicle. Class is only set for the seats of train wagons.
+type Seat struct {
+	Number string
+	Class  WagonClass
+}
+
+type Wagon struct {
+	Number     int        `json:"number"`
+	Class      WagonClass `json:"class"`
+	SeatLayout SeatLayout `json:"seat_layout"`
+}
+
+func (w Wagon) IsInvalidClass() bool {
+	return w.Class != FirstClass && w.Class != SecondClass && w.Class != Sleeper
+}
+
+func (l SeatLayout) Capacity() uint {
+	return uint(len(l.SeatNumbers()))
+}
+
+func (l SeatLayout) SeatNumbers() []string {
+	excluded := make(map[string]bool, len(l.ExcludedSeats))
+	for _, seat := range l.ExcludedSeats {
+		excluded[seat] = true
+	}
+
+	var seats []string
+	for _, seat := range l.grid() {
+		if !excluded[seat] {
+			seats = append(seats, seat)
+		}
+	}
+
+	return seats
+}
+
+// IsValid also rejects excluded seats which are repeated or not on the grid,
+// since they would make the capacity wrong.
+func (l SeatLayout) IsValid() bool {
+	if l.Rows <= 0 || l.SeatsPerRow <= 0 || l.SeatsPerRow > 26 {
+		return false
+	}
+
+	onGrid := make(map[string]bool, l.Rows*l.SeatsPerRow)
+	for _, seat := range l.grid() {
+		onGrid[seat] = true
+	}
+
+	excluded := make(map[string]bool, len(l.ExcludedSeats))
+	for _, seat := range l.ExcludedSeats {
+		if !onGrid[seat] || excluded[seat] {
+			return false
+		}
+		excluded[seat] = true
+	}
+
+	return l.Capacity() > 0
+}
+
+// grid returns the labels of every position of the layout.
+func (l SeatLayout) grid() []string {
+	if l.Rows <= 0 || l.SeatsPerRow <= 0 || l.SeatsPerRow > 26 {
+		return nil
+	}
+
+	seats := make([]string, 0, l.Rows*l.SeatsPerRow)
+	for row := 1; row <= l.Rows; row++ {
+		for column := 0; column < l.SeatsPerRow; column++ {
+			seats = append(seats, fmt.Sprintf("%d%c", row, 'A'+column))
+		}
+	}
+
+	return seats
+}
+
+func (l SeatLayout) IsEmpty() bool {
+	return l.Rows == 0 && l.SeatsPerRow == 0
+}
+
+func (v *Vehicle) Capacity() uint {
+	kind, ok := LookupKind(v.Type)
+	if !ok {
+		return 0
+	}
+	return kind.Capacity(v)
+}
+
+func (v *Vehicle) Seats() []Seat {
+	kind, ok := LookupKind(v.Type)
+	if !ok {
+		return nil
+	}
+	return kind.Seats(v)
+}
+
+// ClassCapacities returns the number of seats of each wagon class, or nil
+// when the seats of the vehicle are not split into classes.
+func (v *Vehicle) ClassCapacities() map[WagonClass]uint {
+	var capacities map[WagonClass]uint
+	for _, seat := range v.Seats() {
+		if seat.Class == "" {
+			continue
+		}
+		if capacities == nil {
+			capacities = make(map[WagonClass]uint)
+		}
+		capacities[seat.Class]++
+	}
+	return capacities
+}
+
+// ApplyDefaults fills the seat layout of the vehicle type when none is given.
+func (v *Vehicle) ApplyDefaults() {
+	kind, ok := LookupKind(v.Type)
+	if ok && v.SeatLayout.IsEmpty() {
+		v.SeatLayout = kind.DefaultLayout
+	}
+}
+
+// Validate runs the rules of the vehicle type against the vehicle.
+func (v *Vehicle) Validate() error {
+	kind, ok := LookupKind(v.Type)
+	if !ok {
+		return ErrInvalidType
+	}
+	return kind.Validate(v)
+}
+
+func (v *Vehicle) CheckFieldsEmpty() bool {
+	return v.Registration == "" || v.Model == ""
+}
+
+func (v *Vehicle) IsInvalidType() bool {
+	return !IsValidType(v.Type)
+}
+
+func (v *Vehicle) IsInvalidAmenity() bool {
+	for _, amenity := range v.Amenities {
+		switch amenity {
+		case Wifi, PowerOutlet, Toilet, Catering, Entertainment:
+		default:
+			return true
+		}
+	}
+	return false
+}
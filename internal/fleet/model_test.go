@@ -0,0 +1,134 @@
+package fleet
+
+import (
+	"testing"
+)
+
+func TestSeatLayout_Capacity(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   SeatLayout
+		expected uint
+	}{
+		{
+			name:     "full grid",
+			layout:   SeatLayout{Rows: 15, SeatsPerRow: 3},
+			expected: 45,
+		},
+		{
+			name:     "excluded seats",
+			layout:   SeatLayout{Rows: 32, SeatsPerRow: 6, ExcludedSeats: []string{"1A", "1B", "1C"}},
+			expected: 189,
+		},
+		{
+			name:     "more excluded seats than grid",
+			layout:   SeatLayout{Rows: 1, SeatsPerRow: 1, ExcludedSeats: []string{"1A", "1B"}},
+			expected: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.layout.Capacity(); got != tt.expected {
+				t.Errorf("Capacity() = %v, want %v", got, tt.expected)
+			}
+			if got := len(tt.layout.SeatNumbers()); uint(got) != tt.expected {
+				t.Errorf("len(SeatNumbers()) = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestVehicle_Validate(t *testing.T) {
+	tests := []struct {
+		name             string
+		vehicle          Vehicle
+		expectedErr      error
+		expectedCapacity uint
+	}{
+		{
+			name:             "bus with default layout",
+			vehicle:          Vehicle{Type: Bus},
+			expectedCapacity: 45,
+		},
+		{
+			name: "train with wagons",
+			vehicle: Vehicle{Type: Train, Wagons: []Wagon{
+				{Number: 1, Class: FirstClass, SeatLayout: SeatLayout{Rows: 10, SeatsPerRow: 3}},
+				{Number: 2, Class: SecondClass, SeatLayout: SeatLayout{Rows: 15, SeatsPerRow: 4}},
+			}},
+			expectedCapacity: 90,
+		},
+		{
+			name: "train with duplicated wagon number",
+			vehicle: Vehicle{Type: Train, Wagons: []Wagon{
+				{Number: 1, Class: FirstClass, SeatLayout: SeatLayout{Rows: 10, SeatsPerRow: 3}},
+				{Number: 1, Class: SecondClass, SeatLayout: SeatLayout{Rows: 15, SeatsPerRow: 4}},
+			}},
+			expectedErr:      ErrInvalidWagons,
+			expectedCapacity: 90,
+		},
+		{
+			name:             "ferry without vehicle deck",
+			vehicle:          Vehicle{Type: Ferry},
+			expectedErr:      ErrInvalidDeck,
+			expectedCapacity: 400,
+		},
+		{
+			name:        "unknown type",
+			vehicle:     Vehicle{Type: "Zeppelin"},
+			expectedErr: ErrInvalidType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.vehicle.ApplyDefaults()
+			if err := tt.vehicle.Validate(); err != tt.expectedErr {
+				t.Errorf("Validate() = %v, want %v", err, tt.expectedErr)
+			}
+			if got := tt.vehicle.Capacity(); got != tt.expectedCapacity {
+				t.Errorf("Capacity() = %v, want %v", got, tt.expectedCapacity)
+			}
+		})
+	}
+}
+
+func TestSeatLayout_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   SeatLayout
+		expected bool
+	}{
+		{
+			name:     "full grid",
+			layout:   SeatLayout{Rows: 15, SeatsPerRow: 3},
+			expected: true,
+		},
+		{
+			name:     "excluded seats on the grid",
+			layout:   SeatLayout{Rows: 2, SeatsPerRow: 2, ExcludedSeats: []string{"1A", "2B"}},
+			expected: true,
+		},
+		{
+			name:     "duplicated excluded seat",
+			layout:   SeatLayout{Rows: 2, SeatsPerRow: 2, ExcludedSeats: []string{"1A", "1A"}},
+			expected: false,
+		},
+		{
+			name:     "excluded seat outside the grid",
+			layout:   SeatLayout{Rows: 2, SeatsPerRow: 2, ExcludedSeats: []string{"3A"}},
+			expected: false,
+		},
+		{
+			name:     "every seat excluded",
+			layout:   SeatLayout{Rows: 1, SeatsPerRow: 1, ExcludedSeats: []string{"1A"}},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.layout.IsValid(); got != tt.expected {
+				t.Errorf("IsValid() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrVehicleNotFound = errors.New("there is no vehicle with that id")
+	ErrDuplicated      = errors.New("vehicle registration should be unique")
+	ErrVehicleAssigned = errors.New("vehicle is assigned to upcoming trips")
+)
+
+type Repository interface {
+	Create(ctx context.Context, vehicle *Vehicle) error
+	FindAll(ctx context.Context, vehicleType Type) ([]Vehicle, error)
+	FindByID(ctx context.Context, id uint) (*Vehicle, error)
+	Update(ctx context.Context, vehicle *Vehicle) error
+	// Delete fails with ErrVehicleAssigned while trips departing after now use the vehicle.
+	Delete(ctx context.Context, id uint, now time.Time) error
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+func (r *defaultRepository) Create(ctx context.Context, vehicle *Vehicle) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Create(vehicle).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrDuplicated
+		}
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) FindAll(ctx context.Context, vehicleType Type) ([]Vehicle, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var vehicles []Vehicle
+
+	if err := r.database.WithContext(timeoutCtx).Where(&Vehicle{Type: vehicleType}).Order("id").Find(&vehicles).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return vehicles, nil
+}
+
+func (r *defaultRepository) FindByID(ctx context.Context, id uint) (*Vehicle, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var vehicle Vehicle
+
+	if err := r.database.WithContext(timeoutCtx).First(&vehicle, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVehicleNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &vehicle, nil
+}
+
+func (r *defaultRepository) Update(ctx context.Context, vehicle *Vehicle) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result := r.database.WithContext(timeoutCtx).Model(&Vehicle{ID: vehicle.ID}).
+		Select("model", "amenities").
+		Updates(vehicle)
+	if result.Error != nil {
+		log.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrVehicleNotFound
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) Delete(ctx context.Context, id uint, now time.Time) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		// Trips lock the vehicle before they are assigned to it, so none can be assigned meanwhile.
+		var vehicle Vehicle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&vehicle, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVehicleNotFound
+			}
+			return err
+		}
+
+		var trips int64
+		if err := tx.Table("trips").
+			Where("vehicle_id = ? AND date >= ? AND deleted_at IS NULL", id, now).
+			Count(&trips).Error; err != nil {
+			return err
+		}
+		if trips > 0 {
+			return ErrVehicleAssigned
+		}
+
+		return tx.Delete(&vehicle).Error
+	})
+	if err != nil && !errors.Is(err, ErrVehicleNotFound) && !errors.Is(err, ErrVehicleAssigned) {
+		log.Error(err)
+	}
+
+	return err
+}
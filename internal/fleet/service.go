@@ -0,0 +1,76 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrVehicleNotExist = errors.New("this vehicle does not exist")
+	ErrVehicleInUse    = errors.New("vehicle is assigned to upcoming trips")
+)
+
+type Service interface {
+	CreateVehicle(ctx context.Context, vehicle *Vehicle) error
+	ListVehicles(ctx context.Context, vehicleType Type) ([]Vehicle, error)
+	GetVehicle(ctx context.Context, id uint) (*Vehicle, error)
+	UpdateVehicle(ctx context.Context, vehicle *Vehicle) error
+	DeleteVehicle(ctx context.Context, id uint) error
+}
+
+type defaultService struct {
+	fleetRepo Repository
+}
+
+func NewService(fleetRepo Repository) Service {
+	return &defaultService{fleetRepo: fleetRepo}
+}
+
+func (s *defaultService) CreateVehicle(ctx context.Context, vehicle *Vehicle) error {
+	return s.fleetRepo.Create(ctx, vehicle)
+}
+
+func (s *defaultService) ListVehicles(ctx context.Context, vehicleType Type) ([]Vehicle, error) {
+	return s.fleetRepo.FindAll(ctx, vehicleType)
+}
+
+func (s *defaultService) GetVehicle(ctx context.Context, id uint) (*Vehicle, error) {
+	vehicle, err := s.fleetRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrVehicleNotFound) {
+			return nil, ErrVehicleNotExist
+		}
+		return nil, err
+	}
+
+	return vehicle, nil
+}
+
+// UpdateVehicle only changes the model and amenities of a vehicle. The seat
+// layout is fixed because sold tickets depend on it.
+func (s *defaultService) UpdateVehicle(ctx context.Context, vehicle *Vehicle) error {
+	if err := s.fleetRepo.Update(ctx, vehicle); err != nil {
+		if errors.Is(err, ErrVehicleNotFound) {
+			return ErrVehicleNotExist
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteVehicle keeps vehicles which upcoming trips are assigned to.
+func (s *defaultService) DeleteVehicle(ctx context.Context, id uint) error {
+	if err := s.fleetRepo.Delete(ctx, id, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, ErrVehicleNotFound):
+			return ErrVehicleNotExist
+		case errors.Is(err, ErrVehicleAssigned):
+			return ErrVehicleInUse
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package loyalty
+
+import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+var (
+	WarnInvalidRule   = "Points per unit should not be negative and vehicle should be valid"
+	WarnSystemFailure = "There is something wrong. Please try again later"
+)
+
+type handler struct {
+	service Service
+}
+
+func NewHandler(routes *auth.Routes, service Service) *handler {
+	h := handler{service: service}
+
+	routes.GET("/loyalty", h.GetAccount, auth.RequirePermission(auth.AccountManage))
+	routes.GET("/loyalty/history", h.History, auth.RequirePermission(auth.AccountManage))
+	routes.POST("/loyalty/rules", h.CreateRule, auth.RequirePermission(auth.LoyaltyManage))
+	routes.GET("/loyalty/rules", h.ListRules, auth.RequirePermission(auth.LoyaltyManage))
+
+	return &h
+}
+
+func (h *handler) GetAccount(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	account, err := h.service.GetAccount(c.Request().Context(), claim.UserID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, account)
+}
+
+func (h *handler) History(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	movements, err := h.service.History(c.Request().Context(), claim.UserID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, movements)
+}
+
+func (h *handler) CreateRule(c echo.Context) error {
+	rule := new(Rule)
+	if err := c.Bind(rule); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	rule.ID = 0
+
+	if err := h.service.CreateRule(c.Request().Context(), rule); err != nil {
+		if errors.Is(err, ErrInvalidRule) {
+			return c.String(http.StatusBadRequest, WarnInvalidRule)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusCreated, rule)
+}
+
+func (h *handler) ListRules(c echo.Context) error {
+	rules, err := h.service.ListRules(c.Request().Context())
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
@@ -0,0 +1,166 @@
+package loyalty
+
+import (
+	"fmt"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"math"
+	"time"
+)
+
+type Tier string
+
+const (
+	Basic  Tier = "basic"
+	Silver Tier = "silver"
+	Gold   Tier = "gold"
+
+	SilverPoints = 1000
+	GoldPoints   = 5000
+
+	// DefaultPointsPerUnit is accrued for every unit of price when no rule matches the trip.
+	DefaultPointsPerUnit = 1.0
+	// PointValue is the discount a single point is worth at checkout.
+	PointValue = 0.01
+)
+
+type Kind string
+
+const (
+	Accrual    Kind = "accrual"
+	Reversal   Kind = "reversal"
+	Redemption Kind = "redemption"
+	Restore    Kind = "restore"
+)
+
+// Perks are what a tier brings to its members.
+type Perks struct {
+	// PurchaseLimitBonus is added to the number of tickets allowed in a purchase.
+	PurchaseLimitBonus int `json:"purchase_limit_bonus"`
+	// FreeChanges waives the change fee when a ticket is exchanged.
+	FreeChanges bool `json:"free_changes"`
+}
+
+var tierPerks = map[Tier]Perks{
+	Basic:  {},
+	Silver: {PurchaseLimitBonus: 3},
+	Gold:   {PurchaseLimitBonus: 10, FreeChanges: true},
+}
+
+// Rule sets how many points are accrued for every unit of price paid for
+// trips matching it. Empty fields match every trip.
+type Rule struct {
+	ID            uint         `gorm:"primarykey" json:"id"`
+	From          string       `json:"from,omitempty"`
+	To            string       `json:"to,omitempty"`
+	Vehicle       trip.Vehicle `json:"vehicle,omitempty"`
+	PointsPerUnit float64      `gorm:"not null;check:points_per_unit>=0" json:"points_per_unit"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// Account holds the points of a user. LifetimePoints are the points accrued
+// when tickets are paid, less those reversed when they are refunded, and
+// decide the tier.
+type Account struct {
+	UserID         uint      `gorm:"primarykey" json:"user_id"`
+	Points         int       `gorm:"not null;default:0;check:points>=0" json:"points"`
+	LifetimePoints int       `gorm:"not null;default:0" json:"lifetime_points"`
+	Tier           Tier      `gorm:"not null;default:basic" json:"tier"`
+	Perks          Perks     `gorm:"-" json:"perks"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Movement changes the points of a user. Reference tells the ticket or order
+// it was recorded for, and a reference is recorded once for each kind.
+type Movement struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Kind      Kind      `gorm:"not null;uniqueIndex:idx_loyalty_reference_kind" json:"kind"`
+	Reference string    `gorm:"not null;uniqueIndex:idx_loyalty_reference_kind" json:"reference"`
+	Points    int       `gorm:"not null" json:"points"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (Movement) TableName() string {
+	return "loyalty_movements"
+}
+
+func (Account) TableName() string {
+	return "loyalty_accounts"
+}
+
+func (Rule) TableName() string {
+	return "loyalty_rules"
+}
+
+func TierOf(lifetimePoints int) Tier {
+	switch {
+	case lifetimePoints >= GoldPoints:
+		return Gold
+	case lifetimePoints >= SilverPoints:
+		return Silver
+	default:
+		return Basic
+	}
+}
+
+func (t Tier) Perks() Perks {
+	return tierPerks[t]
+}
+
+// CountsForTier reports whether points of the movement change the lifetime points.
+func (k Kind) CountsForTier() bool {
+	return k == Accrual || k == Reversal
+}
+
+func (r *Rule) IsValid() bool {
+	return r.PointsPerUnit >= 0 && (r.Vehicle == "" || fleet.IsValidType(r.Vehicle))
+}
+
+// specificity ranks rules matching the trip, higher is more specific. It is
+// negative when the rule does not match.
+func (r *Rule) specificity(t *trip.Trip) int {
+	if (r.From != "" && r.From != t.From) || (r.To != "" && r.To != t.To) || (r.Vehicle != "" && r.Vehicle != t.Vehicle) {
+		return -1
+	}
+
+	score := 0
+	if r.From != "" {
+		score += 2
+	}
+	if r.To != "" {
+		score += 2
+	}
+	if r.Vehicle != "" {
+		score++
+	}
+	return score
+}
+
+// PointsFor returns the points accrued for price paid on the trip, using the
+// most specific matching rule.
+func PointsFor(rules []Rule, t *trip.Trip, price float64) int {
+	pointsPerUnit := DefaultPointsPerUnit
+	best := -1
+	for i := range rules {
+		if score := rules[i].specificity(t); score > best {
+			best = score
+			pointsPerUnit = rules[i].PointsPerUnit
+		}
+	}
+
+	return int(math.Floor(price * pointsPerUnit))
+}
+
+// Discount is what the points are worth at checkout.
+func Discount(points int) float64 {
+	return float64(points) * PointValue
+}
+
+func TicketReference(ticketID int) string {
+	return fmt.Sprintf("ticket:%d", ticketID)
+}
+
+func OrderReference(orderID int) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
@@ -0,0 +1,70 @@
+package loyalty
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"testing"
+)
+
+func TestPointsFor(t *testing.T) {
+	istanbulAnkara := &trip.Trip{From: "Istanbul", To: "Ankara", Vehicle: trip.VehicleBus}
+
+	tests := []struct {
+		name     string
+		rules    []Rule
+		price    float64
+		expected int
+	}{
+		{name: "no rules", rules: nil, price: 150.75, expected: 150},
+		{
+			name:     "vehicle rule",
+			rules:    []Rule{{Vehicle: trip.VehicleBus, PointsPerUnit: 2}},
+			price:    100,
+			expected: 200,
+		},
+		{
+			name:     "route rule wins over vehicle rule",
+			rules:    []Rule{{Vehicle: trip.VehicleBus, PointsPerUnit: 2}, {From: "Istanbul", To: "Ankara", PointsPerUnit: 3}},
+			price:    100,
+			expected: 300,
+		},
+		{
+			name:     "rule of another route",
+			rules:    []Rule{{From: "Izmir", To: "Ankara", PointsPerUnit: 5}},
+			price:    100,
+			expected: 100,
+		},
+		{
+			name:     "route without points",
+			rules:    []Rule{{From: "Istanbul", PointsPerUnit: 0}},
+			price:    100,
+			expected: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PointsFor(tt.rules, istanbulAnkara, tt.price); got != tt.expected {
+				t.Errorf("PointsFor() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTierOf(t *testing.T) {
+	tests := []struct {
+		name           string
+		lifetimePoints int
+		expected       Tier
+	}{
+		{name: "new member", lifetimePoints: 0, expected: Basic},
+		{name: "just below silver", lifetimePoints: SilverPoints - 1, expected: Basic},
+		{name: "silver", lifetimePoints: SilverPoints, expected: Silver},
+		{name: "gold", lifetimePoints: GoldPoints + 20, expected: Gold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TierOf(tt.lifetimePoints); got != tt.expected {
+				t.Errorf("TierOf() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
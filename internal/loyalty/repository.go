@@ -0,0 +1,162 @@
+package loyalty
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrInsufficientPoints = errors.New("points are not enough")
+	ErrAlreadyRecorded    = errors.New("points have already been recorded for this reference")
+	ErrMovementNotFound   = errors.New("there is no movement for that reference")
+)
+
+type Repository interface {
+	Record(ctx context.Context, movement *Movement) error
+	FindAccount(ctx context.Context, userID uint) (*Account, error)
+	FindMovement(ctx context.Context, reference string, kind Kind) (*Movement, error)
+	FindMovements(ctx context.Context, userID uint) ([]Movement, error)
+	CreateRule(ctx context.Context, rule *Rule) error
+	FindRules(ctx context.Context) ([]Rule, error)
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+// Record stores the movement and changes the points, and the tier when
+// lifetime points change, of the user account in a single transaction.
+func (r *defaultRepository) Record(ctx context.Context, movement *Movement) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(movement).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrAlreadyRecorded
+			}
+			return err
+		}
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Account{UserID: movement.UserID, Tier: Basic}).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"points": gorm.Expr("points + ?", movement.Points)}
+		if movement.Kind.CountsForTier() {
+			updates["lifetime_points"] = gorm.Expr("lifetime_points + ?", movement.Points)
+		}
+
+		result := tx.Model(&Account{}).
+			Where("user_id = ? AND points + ? >= 0", movement.UserID, movement.Points).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientPoints
+		}
+
+		var account Account
+		if err := tx.First(&account, movement.UserID).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&account).Update("tier", TierOf(account.LifetimePoints)).Error
+	})
+	if err != nil && err != ErrInsufficientPoints && err != ErrAlreadyRecorded {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// FindAccount returns an empty account for users who have never earned points.
+func (r *defaultRepository) FindAccount(ctx context.Context, userID uint) (*Account, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var account Account
+
+	if err := r.database.WithContext(timeoutCtx).First(&account, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Account{UserID: userID, Tier: Basic}, nil
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (r *defaultRepository) FindMovement(ctx context.Context, reference string, kind Kind) (*Movement, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var movement Movement
+
+	if err := r.database.WithContext(timeoutCtx).
+		Where("reference = ? AND kind = ?", reference, kind).
+		First(&movement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMovementNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &movement, nil
+}
+
+func (r *defaultRepository) FindMovements(ctx context.Context, userID uint) ([]Movement, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var movements []Movement
+
+	if err := r.database.WithContext(timeoutCtx).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Find(&movements).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return movements, nil
+}
+
+func (r *defaultRepository) CreateRule(ctx context.Context, rule *Rule) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Create(rule).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) FindRules(ctx context.Context) ([]Rule, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var rules []Rule
+
+	if err := r.database.WithContext(timeoutCtx).Order("id").Find(&rules).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return rules, nil
+}
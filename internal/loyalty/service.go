@@ -0,0 +1,152 @@
+package loyalty
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+)
+
+var (
+	ErrInvalidPoints = errors.New("points should be positive")
+	ErrInvalidRule   = errors.New("loyalty rule is invalid")
+)
+
+type Service interface {
+	// Accrue gives the user points for the share of the price of an issued
+	// ticket paid by card.
+	Accrue(ctx context.Context, userID uint, ticketID int, t *trip.Trip, price float64) error
+	// Reverse takes back the points accrued for a refunded ticket.
+	Reverse(ctx context.Context, userID uint, ticketID int) error
+	// Redeem spends points at checkout of the order and returns their discount.
+	Redeem(ctx context.Context, userID uint, points int, orderID int) (float64, error)
+	// Restore gives back the points redeemed for an order which failed.
+	Restore(ctx context.Context, userID uint, orderID int) error
+	// RestoreTicket gives back the points redeemed for the refunded share of a ticket.
+	RestoreTicket(ctx context.Context, userID uint, ticketID int, points int) error
+	Perks(ctx context.Context, userID uint) (Perks, error)
+	GetAccount(ctx context.Context, userID uint) (*Account, error)
+	History(ctx context.Context, userID uint) ([]Movement, error)
+	CreateRule(ctx context.Context, rule *Rule) error
+	ListRules(ctx context.Context) ([]Rule, error)
+}
+
+type defaultService struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &defaultService{repo: repo}
+}
+
+func (s *defaultService) Accrue(ctx context.Context, userID uint, ticketID int, t *trip.Trip, price float64) error {
+	rules, err := s.repo.FindRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	points := PointsFor(rules, t, price)
+	if points <= 0 {
+		return nil
+	}
+
+	return s.record(ctx, &Movement{UserID: userID, Kind: Accrual, Reference: TicketReference(ticketID), Points: points})
+}
+
+func (s *defaultService) Reverse(ctx context.Context, userID uint, ticketID int) error {
+	accrual, err := s.repo.FindMovement(ctx, TicketReference(ticketID), Accrual)
+	if err != nil {
+		if errors.Is(err, ErrMovementNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	err = s.record(ctx, &Movement{UserID: userID, Kind: Reversal, Reference: accrual.Reference, Points: -accrual.Points})
+	if errors.Is(err, ErrInsufficientPoints) {
+		// The points have been redeemed already, take back what is left.
+		account, findErr := s.repo.FindAccount(ctx, userID)
+		if findErr != nil || account.Points == 0 {
+			return findErr
+		}
+		return s.record(ctx, &Movement{UserID: userID, Kind: Reversal, Reference: accrual.Reference, Points: -account.Points})
+	}
+
+	return err
+}
+
+func (s *defaultService) Redeem(ctx context.Context, userID uint, points int, orderID int) (float64, error) {
+	if points <= 0 {
+		return 0, ErrInvalidPoints
+	}
+
+	if err := s.repo.Record(ctx, &Movement{UserID: userID, Kind: Redemption, Reference: OrderReference(orderID), Points: -points}); err != nil {
+		return 0, err
+	}
+
+	return Discount(points), nil
+}
+
+func (s *defaultService) Restore(ctx context.Context, userID uint, orderID int) error {
+	redemption, err := s.repo.FindMovement(ctx, OrderReference(orderID), Redemption)
+	if err != nil {
+		if errors.Is(err, ErrMovementNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return s.record(ctx, &Movement{UserID: userID, Kind: Restore, Reference: redemption.Reference, Points: -redemption.Points})
+}
+
+func (s *defaultService) RestoreTicket(ctx context.Context, userID uint, ticketID int, points int) error {
+	if points <= 0 {
+		return ErrInvalidPoints
+	}
+
+	return s.record(ctx, &Movement{UserID: userID, Kind: Restore, Reference: TicketReference(ticketID), Points: points})
+}
+
+func (s *defaultService) Perks(ctx context.Context, userID uint) (Perks, error) {
+	account, err := s.repo.FindAccount(ctx, userID)
+	if err != nil {
+		return Perks{}, err
+	}
+
+	return account.Tier.Perks(), nil
+}
+
+func (s *defaultService) GetAccount(ctx context.Context, userID uint) (*Account, error) {
+	account, err := s.repo.FindAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	account.Perks = account.Tier.Perks()
+
+	return account, nil
+}
+
+func (s *defaultService) History(ctx context.Context, userID uint) ([]Movement, error) {
+	return s.repo.FindMovements(ctx, userID)
+}
+
+func (s *defaultService) CreateRule(ctx context.Context, rule *Rule) error {
+	if !rule.IsValid() {
+		return ErrInvalidRule
+	}
+
+	return s.repo.CreateRule(ctx, rule)
+}
+
+func (s *defaultService) ListRules(ctx context.Context) ([]Rule, error) {
+	return s.repo.FindRules(ctx)
+}
+
+// record treats a movement recorded already as done, so accruals and
+// reversals may be retried safely.
+func (s *defaultService) record(ctx context.Context, movement *Movement) error {
+	if err := s.repo.Record(ctx, movement); err != nil && !errors.Is(err, ErrAlreadyRecorded) {
+		return err
+	}
+
+	return nil
+}
@@ -3,7 +3,8 @@ package payment
 import "fmt"
 
 type Client interface {
-	Transfer() error
+	Transfer(amount float64) error
+	Refund(amount float64) error
 }
 
 type defaultClient struct {
@@ -13,7 +14,12 @@ func NewClient() Client {
 	return &defaultClient{}
 }
 
-func (p *defaultClient) Transfer() error {
-	fmt.Println("Payment Received")
+func (p *defaultClient) Transfer(amount float64) error {
+	fmt.Printf("Payment Received: %.2f\n", amount)
+	return nil
+}
+
+func (p *defaultClient) Refund(amount float64) error {
+	fmt.Printf("Payment Refunded: %.2f\n", amount)
 	return nil
 }
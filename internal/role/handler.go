@@ -0,0 +1,60 @@
+package role
+
+import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+var (
+	WarnInvalidPermission = "Permission is unknown"
+	WarnRoleNotFound      = "This role does not exist"
+	WarnAdminLockout      = "role:manage cannot be removed from the admin role"
+	WarnSystemFailure     = "There is something wrong. Please try again later"
+)
+
+type handler struct {
+	service Service
+}
+
+func NewHandler(routes *auth.Routes, service Service) *handler {
+	h := handler{service: service}
+
+	routes.GET("/roles", h.List, auth.RequirePermission(auth.RoleManage))
+	routes.PUT("/roles/:name/permissions", h.SetPermissions, auth.RequirePermission(auth.RoleManage))
+
+	return &h
+}
+
+func (h *handler) List(c echo.Context) error {
+	grants, err := h.service.List(c.Request().Context())
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, grants)
+}
+
+// SetPermissions takes effect for a user when their token is refreshed.
+func (h *handler) SetPermissions(c echo.Context) error {
+	var permissions []auth.Permission
+	if err := c.Bind(&permissions); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.service.SetPermissions(c.Request().Context(), auth.Role(c.Param("name")), permissions); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidPermission):
+			return c.String(http.StatusBadRequest, WarnInvalidPermission)
+		case errors.Is(err, ErrRoleNotFound):
+			return c.String(http.StatusNotFound, WarnRoleNotFound)
+		case errors.Is(err, ErrAdminLockout):
+			return c.String(http.StatusConflict, WarnAdminLockout)
+		default:
+			return c.String(http.StatusInternalServerError, WarnSystemFailure)
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,93 @@
+package role
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"time"
+)
+
+// Role is stored with its permissions, so they can be changed without a release.
+type Role struct {
+	Name        auth.Role        `gorm:"primarykey" json:"name"`
+	Permissions []RolePermission `gorm:"foreignKey:Role;constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+type RolePermission struct {
+	Role       auth.Role       `gorm:"primarykey"`
+	Permission auth.Permission `gorm:"primarykey"`
+}
+
+// SeededPermission records a default permission given to a role by Seed, so
+// defaults added in a later release reach existing roles while permissions
+// removed by admins stay removed.
+type SeededPermission struct {
+	Role       auth.Role       `gorm:"primarykey"`
+	Permission auth.Permission `gorm:"primarykey"`
+}
+
+// UserRole assigns a role to a user on top of the roles of their user type.
+type UserRole struct {
+	UserID    uint      `gorm:"primarykey" json:"user_id"`
+	Role      auth.Role `gorm:"primarykey" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Grant is a role with the permissions it gives, as listed to admins.
+type Grant struct {
+	Role        auth.Role         `json:"role"`
+	Permissions []auth.Permission `json:"permissions"`
+}
+
+func (r *Role) PermissionNames() []auth.Permission {
+	permissions := make([]auth.Permission, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		permissions = append(permissions, p.Permission)
+	}
+	return permissions
+}
+
+// mergePermissions returns every permission of the roles once, in catalog order.
+func mergePermissions(roles []Role) []auth.Permission {
+	granted := make(map[auth.Permission]bool)
+	for i := range roles {
+		for _, p := range roles[i].Permissions {
+			granted[p.Permission] = true
+		}
+	}
+
+	var permissions []auth.Permission
+	for _, p := range auth.Permissions {
+		if granted[p] {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+// unseeded returns the default permissions which have not been seeded yet.
+func unseeded(defaults []auth.Permission, seeded []auth.Permission) []auth.Permission {
+	done := make(map[auth.Permission]bool, len(seeded))
+	for _, p := range seeded {
+		done[p] = true
+	}
+
+	var missing []auth.Permission
+	for _, p := range defaults {
+		if !done[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+func uniqueRoles(roles []auth.Role) []auth.Role {
+	seen := make(map[auth.Role]bool)
+	unique := roles[:0:0]
+	for _, r := range roles {
+		if !seen[r] {
+			seen[r] = true
+			unique = append(unique, r)
+		}
+	}
+	return unique
+}
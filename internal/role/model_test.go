@@ -0,0 +1,53 @@
+package role
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"reflect"
+	"testing"
+)
+
+func TestMergePermissions(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []Role
+		expected []auth.Permission
+	}{
+		{name: "no roles", roles: nil, expected: nil},
+		{
+			name: "overlapping roles",
+			roles: []Role{
+				{Name: auth.FinanceRole, Permissions: []RolePermission{{Permission: auth.ReportRevenue}, {Permission: auth.WalletCompensate}}},
+				{Name: auth.SupportRole, Permissions: []RolePermission{{Permission: auth.WalletCompensate}, {Permission: auth.TicketRefund}}},
+			},
+			expected: []auth.Permission{auth.ReportRevenue, auth.TicketRefund, auth.WalletCompensate},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergePermissions(tt.roles); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("mergePermissions() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnseeded(t *testing.T) {
+	defaults := []auth.Permission{auth.RoleManage, auth.UserManage, auth.ReportRevenue}
+
+	tests := []struct {
+		name     string
+		seeded   []auth.Permission
+		expected []auth.Permission
+	}{
+		{name: "new role", seeded: nil, expected: defaults},
+		{name: "default added in a release", seeded: []auth.Permission{auth.RoleManage, auth.UserManage}, expected: []auth.Permission{auth.ReportRevenue}},
+		{name: "every default seeded", seeded: defaults, expected: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unseeded(defaults, tt.seeded); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unseeded() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
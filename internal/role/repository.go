@@ -0,0 +1,194 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var ErrRoleNotFound = errors.New("there is no role with that name")
+
+type Repository interface {
+	// Seed creates the roles which do not exist yet and gives every role the
+	// default permissions it has not been given before. Permissions removed
+	// from a role after they were seeded are not given again.
+	Seed(ctx context.Context, defaults map[auth.Role][]auth.Permission) error
+	FindAll(ctx context.Context) ([]Role, error)
+	FindByNames(ctx context.Context, names []auth.Role) ([]Role, error)
+	FindUserRoles(ctx context.Context, userID uint) ([]auth.Role, error)
+	SetPermissions(ctx context.Context, name auth.Role, permissions []auth.Permission) error
+	SetUserRoles(ctx context.Context, userID uint, roles []auth.Role) error
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+func (r *defaultRepository) Seed(ctx context.Context, defaults map[auth.Role][]auth.Permission) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		for name, permissions := range defaults {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Permissions").Create(&Role{Name: name}).Error; err != nil {
+				return err
+			}
+
+			var seeded []auth.Permission
+			if err := tx.Model(&SeededPermission{}).Where("role = ?", name).Pluck("permission", &seeded).Error; err != nil {
+				return err
+			}
+
+			missing := unseeded(permissions, seeded)
+			if len(missing) == 0 {
+				continue
+			}
+
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(rolePermissions(name, missing)).Error; err != nil {
+				return err
+			}
+
+			rows := make([]SeededPermission, 0, len(missing))
+			for _, p := range missing {
+				rows = append(rows, SeededPermission{Role: name, Permission: p})
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func (r *defaultRepository) FindAll(ctx context.Context) ([]Role, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var roles []Role
+
+	if err := r.database.WithContext(timeoutCtx).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *defaultRepository) FindByNames(ctx context.Context, names []auth.Role) ([]Role, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var roles []Role
+
+	if err := r.database.WithContext(timeoutCtx).Preload("Permissions").
+		Where("name IN ?", names).
+		Find(&roles).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r *defaultRepository) FindUserRoles(ctx context.Context, userID uint) ([]auth.Role, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var roles []auth.Role
+
+	if err := r.database.WithContext(timeoutCtx).Model(&UserRole{}).
+		Where("user_id = ?", userID).
+		Pluck("role", &roles).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// SetPermissions replaces the permissions of the role.
+func (r *defaultRepository) SetPermissions(ctx context.Context, name auth.Role, permissions []auth.Permission) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		var role Role
+		if err := tx.First(&role, "name = ?", name).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRoleNotFound
+			}
+			return err
+		}
+
+		if err := tx.Where("role = ?", name).Delete(&RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		if len(permissions) == 0 {
+			return nil
+		}
+
+		return tx.Create(rolePermissions(name, permissions)).Error
+	})
+	if err != nil && err != ErrRoleNotFound {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// SetUserRoles replaces the roles assigned to the user.
+func (r *defaultRepository) SetUserRoles(ctx context.Context, userID uint, roles []auth.Role) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		return SetUserRolesIn(tx, userID, roles)
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// SetUserRolesIn replaces the roles assigned to the user within the
+// transaction tx, so another package can assign them together with its own
+// changes.
+func SetUserRolesIn(tx *gorm.DB, userID uint, roles []auth.Role) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&UserRole{}).Error; err != nil {
+		return err
+	}
+
+	roles = uniqueRoles(roles)
+	if len(roles) == 0 {
+		return nil
+	}
+
+	rows := make([]UserRole, 0, len(roles))
+	for _, role := range roles {
+		rows = append(rows, UserRole{UserID: userID, Role: role})
+	}
+	return tx.Create(&rows).Error
+}
+
+func rolePermissions(name auth.Role, permissions []auth.Permission) []RolePermission {
+	rows := make([]RolePermission, 0, len(permissions))
+	for _, p := range permissions {
+		rows = append(rows, RolePermission{Role: name, Permission: p})
+	}
+	return rows
+}
@@ -0,0 +1,112 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+)
+
+var (
+	ErrInvalidPermission = errors.New("permission is unknown")
+	ErrAdminLockout      = errors.New("role:manage cannot be removed from the admin role")
+)
+
+type Service interface {
+	Seed(ctx context.Context) error
+	// Resolve returns the roles of the user, implicit and assigned ones, and
+	// every permission they give.
+	Resolve(ctx context.Context, userID uint, userType auth.UserType) ([]auth.Role, []auth.Permission, error)
+	List(ctx context.Context) ([]Grant, error)
+	SetPermissions(ctx context.Context, name auth.Role, permissions []auth.Permission) error
+	// CheckRoles returns ErrRoleNotFound unless every role exists.
+	CheckRoles(ctx context.Context, roles []auth.Role) error
+	// SetUserRoles replaces the roles assigned to the user.
+	SetUserRoles(ctx context.Context, userID uint, roles []auth.Role) error
+}
+
+type defaultService struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &defaultService{repo: repo}
+}
+
+func (s *defaultService) Seed(ctx context.Context) error {
+	return s.repo.Seed(ctx, auth.DefaultRolePermissions)
+}
+
+func (s *defaultService) Resolve(ctx context.Context, userID uint, userType auth.UserType) ([]auth.Role, []auth.Permission, error) {
+	assigned, err := s.repo.FindUserRoles(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := uniqueRoles(append(auth.ImplicitRoles(userType), assigned...))
+	if len(names) == 0 {
+		return nil, nil, nil
+	}
+
+	roles, err := s.repo.FindByNames(ctx, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return names, mergePermissions(roles), nil
+}
+
+func (s *defaultService) List(ctx context.Context) ([]Grant, error) {
+	roles, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := make([]Grant, 0, len(roles))
+	for i := range roles {
+		grants = append(grants, Grant{Role: roles[i].Name, Permissions: roles[i].PermissionNames()})
+	}
+
+	return grants, nil
+}
+
+func (s *defaultService) SetPermissions(ctx context.Context, name auth.Role, permissions []auth.Permission) error {
+	keepsRoleManage := false
+	for _, p := range permissions {
+		if !p.IsValid() {
+			return ErrInvalidPermission
+		}
+		keepsRoleManage = keepsRoleManage || p == auth.RoleManage
+	}
+
+	// Without role:manage on admin, nobody could give any permission back.
+	if name == auth.AdminRole && !keepsRoleManage {
+		return ErrAdminLockout
+	}
+
+	return s.repo.SetPermissions(ctx, name, permissions)
+}
+
+func (s *defaultService) CheckRoles(ctx context.Context, roles []auth.Role) error {
+	roles = uniqueRoles(roles)
+	if len(roles) == 0 {
+		return nil
+	}
+
+	found, err := s.repo.FindByNames(ctx, roles)
+	if err != nil {
+		return err
+	}
+	if len(found) != len(roles) {
+		return ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (s *defaultService) SetUserRoles(ctx context.Context, userID uint, roles []auth.Role) error {
+	if err := s.CheckRoles(ctx, roles); err != nil {
+		return err
+	}
+
+	return s.repo.SetUserRoles(ctx, userID, uniqueRoles(roles))
+}
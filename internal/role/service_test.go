@@ -0,0 +1,45 @@
+package role
+
+import (
+	"context"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"testing"
+)
+
+type fakeRepository struct {
+	Repository
+	permissions map[auth.Role][]auth.Permission
+}
+
+func (r *fakeRepository) SetPermissions(ctx context.Context, name auth.Role, permissions []auth.Permission) error {
+	r.permissions[name] = permissions
+	return nil
+}
+
+func TestService_SetPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        auth.Role
+		permissions []auth.Permission
+		expectedErr error
+	}{
+		{name: "admin keeps role:manage", role: auth.AdminRole, permissions: []auth.Permission{auth.RoleManage}},
+		{name: "admin without any permission", role: auth.AdminRole, permissions: []auth.Permission{}, expectedErr: ErrAdminLockout},
+		{name: "admin without role:manage", role: auth.AdminRole, permissions: []auth.Permission{auth.UserManage}, expectedErr: ErrAdminLockout},
+		{name: "other role without role:manage", role: auth.SupportRole, permissions: []auth.Permission{}},
+		{name: "unknown permission", role: auth.SupportRole, permissions: []auth.Permission{"ticket:steal"}, expectedErr: ErrInvalidPermission},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{permissions: map[auth.Role][]auth.Permission{}}
+			service := NewService(repo)
+
+			if err := service.SetPermissions(context.Background(), tt.role, tt.permissions); err != tt.expectedErr {
+				t.Errorf("SetPermissions() error = %v, want %v", err, tt.expectedErr)
+			}
+			if _, ok := repo.permissions[tt.role]; ok != (tt.expectedErr == nil) {
+				t.Errorf("permissions stored = %v, want %v", ok, tt.expectedErr == nil)
+			}
+		})
+	}
+}
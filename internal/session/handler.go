@@ -0,0 +1,120 @@
+package session
+
+import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const (
+	AccessTokenCookie  = "token"
+	RefreshTokenCookie = "refresh_token"
+	refreshPath        = "/token/refresh"
+)
+
+var (
+	WarnWhenRefreshTokenMissing = "Refresh token is required"
+	WarnWhenRefreshTokenInvalid = "Please login again"
+	WarnWhenSessionNotFound     = "This session does not exist"
+	WarnSystemFailure           = "There is something wrong. Please try again later"
+	SuccessRevokedMessage       = "Session has been revoked"
+)
+
+type handler struct {
+	service Service
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewHandler(routes *auth.Routes, service Service) *handler {
+	h := handler{service: service}
+
+	routes.POST(refreshPath, h.Refresh, auth.Public)
+	routes.GET("/sessions", h.List, auth.Authenticated)
+	routes.DELETE("/sessions/:id", h.Revoke, auth.Authenticated)
+
+	return &h
+}
+
+// Refresh reads the refresh token from its cookie for browsers and from the
+// body for API clients.
+func (h *handler) Refresh(c echo.Context) error {
+	var request refreshRequest
+	if cookie, err := c.Cookie(RefreshTokenCookie); err == nil {
+		request.RefreshToken = cookie.Value
+	} else if err = c.Bind(&request); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if request.RefreshToken == "" {
+		return c.String(http.StatusBadRequest, WarnWhenRefreshTokenMissing)
+	}
+
+	tokens, err := h.service.Refresh(c.Request().Context(), request.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTokenNotFound), errors.Is(err, ErrTokenReused), errors.Is(err, ErrTokenExpired),
+			errors.Is(err, ErrSessionRevoked), errors.Is(err, ErrSessionNotFound):
+			ClearTokenCookies(c)
+			return c.String(http.StatusUnauthorized, WarnWhenRefreshTokenInvalid)
+		default:
+			return c.String(http.StatusInternalServerError, WarnSystemFailure)
+		}
+	}
+
+	SetTokenCookies(c, tokens)
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (h *handler) List(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	sessions, err := h.service.List(c.Request().Context(), claim)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, sessions)
+}
+
+func (h *handler) Revoke(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	if err := h.service.Revoke(c.Request().Context(), c.Param("id"), claim); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return c.String(http.StatusNotFound, WarnWhenSessionNotFound)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.String(http.StatusOK, SuccessRevokedMessage)
+}
+
+// SetTokenCookies gives the tokens to browser clients. The access token cookie
+// is sent to every endpoint, whichever one set it, while the refresh token
+// cookie is only sent to the refresh endpoint. Neither is readable by scripts.
+func SetTokenCookies(c echo.Context, tokens *Tokens) {
+	c.SetCookie(&http.Cookie{
+		Name:     AccessTokenCookie,
+		Value:    tokens.AccessToken,
+		Path:     "/",
+		Expires:  tokens.AccessExpiresAt,
+		HttpOnly: true,
+	})
+	c.SetCookie(&http.Cookie{
+		Name:     RefreshTokenCookie,
+		Value:    tokens.RefreshToken,
+		Path:     refreshPath,
+		Expires:  tokens.RefreshExpiresAt,
+		HttpOnly: true,
+	})
+}
+
+func ClearTokenCookies(c echo.Context) {
+	c.SetCookie(&http.Cookie{Name: AccessTokenCookie, Value: "", Path: "/", MaxAge: -1})
+	c.SetCookie(&http.Cookie{Name: RefreshTokenCookie, Value: "", Path: refreshPath, MaxAge: -1})
+}
@@ -0,0 +1,34 @@
+package session
+
+import (
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenCookies_Path(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c echo.Context)
+	}{
+		{name: "set", write: func(c echo.Context) {
+			SetTokenCookies(c, &Tokens{AccessToken: "access", AccessExpiresAt: time.Now().Add(time.Hour), RefreshToken: "refresh", RefreshExpiresAt: time.Now().Add(time.Hour)})
+		}},
+		{name: "clear", write: ClearTokenCookies},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.write(echo.New().NewContext(httptest.NewRequest(http.MethodPost, refreshPath, nil), rec))
+
+			expected := map[string]string{AccessTokenCookie: "/", RefreshTokenCookie: refreshPath}
+			for _, cookie := range rec.Result().Cookies() {
+				if cookie.Path != expected[cookie.Name] {
+					t.Errorf("path of cookie %s = %q, want %q", cookie.Name, cookie.Path, expected[cookie.Name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"time"
+)
+
+const (
+	// AccessTokenTTL is how long an access token is accepted. It is short,
+	// since a revoked session is noticed only when its tokens are checked.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a session lives without being refreshed.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Session is a login of a user on a device. It is kept alive by refresh
+// tokens, each of which may be used only once. UserName and UserType are
+// those at login, they are loaded from the user again on every refresh.
+type Session struct {
+	ID         string        `gorm:"primarykey" json:"id"`
+	UserID     uint          `gorm:"not null;index" json:"user_id"`
+	UserName   string        `gorm:"not null" json:"-"`
+	UserType   auth.UserType `gorm:"not null" json:"-"`
+	UserAgent  string        `json:"user_agent"`
+	IP         string        `json:"ip"`
+	Current    bool          `gorm:"-" json:"current"`
+	ExpiresAt  time.Time     `gorm:"not null" json:"expires_at"`
+	LastUsedAt time.Time     `gorm:"not null" json:"last_used_at"`
+	RevokedAt  *time.Time    `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// RefreshToken is stored by its hash. A token used already is kept, so its
+// reuse can be told apart from an unknown token.
+type RefreshToken struct {
+	ID        uint   `gorm:"primarykey"`
+	SessionID string `gorm:"not null;index"`
+	TokenHash string `gorm:"not null;unique"`
+	UsedAt    *time.Time
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+// Tokens are given to the client when a session starts or is refreshed.
+type Tokens struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// Client tells the device a session is started from.
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+func (t *RefreshToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+func newRefreshToken(sessionID string, now time.Time) (RefreshToken, string, error) {
+	token, err := randomString()
+	if err != nil {
+		return RefreshToken{}, "", err
+	}
+
+	return RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	}, token, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
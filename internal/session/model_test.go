@@ -0,0 +1,43 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSession_IsActive(t *testing.T) {
+	now := time.Date(2023, 6, 15, 9, 0, 0, 0, time.UTC)
+	revokedAt := now.Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		session  Session
+		expected bool
+	}{
+		{name: "active", session: Session{ExpiresAt: now.Add(time.Hour)}, expected: true},
+		{name: "expired", session: Session{ExpiresAt: now}, expected: false},
+		{name: "revoked", session: Session{ExpiresAt: now.Add(time.Hour), RevokedAt: &revokedAt}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.session.IsActive(now); got != tt.expected {
+				t.Errorf("IsActive() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	now := time.Date(2023, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	token, plain, err := newRefreshToken("abc", now)
+	if err != nil {
+		t.Fatalf("newRefreshToken() error = %v", err)
+	}
+	if token.TokenHash == plain || token.TokenHash != hashToken(plain) {
+		t.Errorf("TokenHash = %v, want the hash of the token", token.TokenHash)
+	}
+	if token.SessionID != "abc" || !token.ExpiresAt.Equal(now.Add(RefreshTokenTTL)) {
+		t.Errorf("newRefreshToken() = %+v", token)
+	}
+}
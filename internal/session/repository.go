@@ -0,0 +1,169 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("there is no session with that id")
+	ErrTokenNotFound   = errors.New("refresh token is unknown")
+	ErrTokenReused     = errors.New("refresh token has been used already")
+)
+
+type Repository interface {
+	Create(ctx context.Context, session *Session, token *RefreshToken) error
+	FindByID(ctx context.Context, sessionID string) (*Session, error)
+	FindToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	FindActive(ctx context.Context, userID uint, now time.Time) ([]Session, error)
+	// Rotate uses the token up and stores the next token of its session.
+	Rotate(ctx context.Context, token *RefreshToken, next *RefreshToken, now time.Time) error
+	Revoke(ctx context.Context, sessionID string, now time.Time) error
+	RevokeAll(ctx context.Context, userID uint, now time.Time) error
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+func (r *defaultRepository) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func (r *defaultRepository) FindByID(ctx context.Context, sessionID string) (*Session, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var session Session
+
+	if err := r.database.WithContext(timeoutCtx).First(&session, "id = ?", sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *defaultRepository) FindToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var token RefreshToken
+
+	if err := r.database.WithContext(timeoutCtx).First(&token, "token_hash = ?", tokenHash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTokenNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *defaultRepository) FindActive(ctx context.Context, userID uint, now time.Time) ([]Session, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var sessions []Session
+
+	if err := r.database.WithContext(timeoutCtx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Rotate marks the token used only if nobody used it in the meantime, so
+// two clients racing with the same token are caught as reuse.
+func (r *defaultRepository) Rotate(ctx context.Context, token *RefreshToken, next *RefreshToken, now time.Time) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenReused
+		}
+
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&Session{}).Where("id = ?", token.SessionID).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"expires_at":   next.ExpiresAt,
+		}).Error
+	})
+	if err != nil && err != ErrTokenReused {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func (r *defaultRepository) Revoke(ctx context.Context, sessionID string, now time.Time) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", now).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) RevokeAll(ctx context.Context, userID uint, now time.Time) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := RevokeAllIn(r.database.WithContext(timeoutCtx), userID, now); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAllIn ends every session of the user within the transaction tx, so
+// another package can end them together with its own changes.
+func RevokeAllIn(tx *gorm.DB, userID uint, now time.Time) error {
+	return tx.Model(&Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
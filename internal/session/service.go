@@ -0,0 +1,216 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/gommon/log"
+	"time"
+)
+
+var (
+	ErrSessionRevoked = errors.New("session has been revoked or expired")
+	ErrTokenExpired   = errors.New("refresh token has expired")
+)
+
+type Service interface {
+	// Start opens a session for the user and issues its first tokens.
+	Start(ctx context.Context, userID uint, userName string, userType auth.UserType, client Client) (*Tokens, error)
+	// Refresh exchanges a refresh token for new tokens. A refresh token used
+	// twice means it has been stolen, so its session is revoked.
+	Refresh(ctx context.Context, refreshToken string) (*Tokens, error)
+	// List returns the active sessions of the user, marking the current one.
+	List(ctx context.Context, claims auth.Claims) ([]Session, error)
+	Revoke(ctx context.Context, sessionID string, claims auth.Claims) error
+	RevokeAll(ctx context.Context, userID uint) error
+	IsActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// RoleResolver finds the roles of a user and the permissions they give.
+type RoleResolver interface {
+	Resolve(ctx context.Context, userID uint, userType auth.UserType) ([]auth.Role, []auth.Permission, error)
+}
+
+// Account is what a session needs to know of its user to issue tokens.
+type Account struct {
+	UserName string
+	UserType auth.UserType
+	// Active is false for deactivated and deleted users.
+	Active bool
+}
+
+// AccountFinder loads the user of a session when it is refreshed, so its
+// tokens carry the current user name and type.
+type AccountFinder interface {
+	FindAccount(ctx context.Context, userID uint) (*Account, error)
+}
+
+type defaultService struct {
+	repo     Repository
+	tokens   *auth.JWT
+	roles    RoleResolver
+	accounts AccountFinder
+}
+
+func NewService(repo Repository, tokens *auth.JWT, roles RoleResolver, accounts AccountFinder) Service {
+	return &defaultService{repo: repo, tokens: tokens, roles: roles, accounts: accounts}
+}
+
+func (s *defaultService) Start(ctx context.Context, userID uint, userName string, userType auth.UserType, client Client) (*Tokens, error) {
+	sessionID, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserName:   userName,
+		UserType:   userType,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		ExpiresAt:  now.Add(RefreshTokenTTL),
+		LastUsedAt: now,
+	}
+
+	token, refreshToken, err := newRefreshToken(session.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.Create(ctx, &session, &token); err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, &session, refreshToken, token.ExpiresAt, now)
+}
+
+func (s *defaultService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	token, err := s.repo.FindToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.repo.FindByID(ctx, token.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		return nil, ErrSessionRevoked
+	}
+	if token.IsUsed() {
+		return nil, s.revokeReused(ctx, session, now)
+	}
+	if token.IsExpired(now) {
+		return nil, ErrTokenExpired
+	}
+
+	account, err := s.accounts.FindAccount(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !account.Active {
+		return nil, ErrSessionRevoked
+	}
+	session.UserName = account.UserName
+	session.UserType = account.UserType
+
+	next, nextRefreshToken, err := newRefreshToken(session.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.repo.Rotate(ctx, token, &next, now); err != nil {
+		if errors.Is(err, ErrTokenReused) {
+			return nil, s.revokeReused(ctx, session, now)
+		}
+		return nil, err
+	}
+
+	return s.issue(ctx, session, nextRefreshToken, next.ExpiresAt, now)
+}
+
+func (s *defaultService) List(ctx context.Context, claims auth.Claims) ([]Session, error) {
+	sessions, err := s.repo.FindActive(ctx, claims.UserID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+
+	return sessions, nil
+}
+
+func (s *defaultService) Revoke(ctx context.Context, sessionID string, claims auth.Claims) error {
+	session, err := s.repo.FindByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if session.UserID != claims.UserID && !claims.Can(auth.UserManage) {
+		return ErrSessionNotFound
+	}
+
+	return s.repo.Revoke(ctx, session.ID, time.Now())
+}
+
+func (s *defaultService) RevokeAll(ctx context.Context, userID uint) error {
+	return s.repo.RevokeAll(ctx, userID, time.Now())
+}
+
+func (s *defaultService) IsActive(ctx context.Context, sessionID string) (bool, error) {
+	session, err := s.repo.FindByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return session.IsActive(time.Now()), nil
+}
+
+func (s *defaultService) revokeReused(ctx context.Context, session *Session, now time.Time) error {
+	log.Warnf("refresh token of session %s of user %d has been reused, revoking the session", session.ID, session.UserID)
+
+	if err := s.repo.Revoke(ctx, session.ID, now); err != nil {
+		return err
+	}
+
+	return ErrTokenReused
+}
+
+// issue signs an access token for the session along with its refresh token.
+// Roles are resolved every time, so changed permissions apply on the next refresh.
+func (s *defaultService) issue(ctx context.Context, session *Session, refreshToken string, refreshExpiresAt time.Time, now time.Time) (*Tokens, error) {
+	roles, permissions, err := s.roles.Resolve(ctx, session.UserID, session.UserType)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := auth.Claims{
+		Username:    session.UserName,
+		UserID:      session.UserID,
+		UserType:    session.UserType,
+		SessionID:   session.ID,
+		Roles:       roles,
+		Permissions: permissions,
+	}
+
+	accessToken, err := s.tokens.Sign(claims, now, AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  now.Add(AccessTokenTTL),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"context"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"testing"
+	"time"
+)
+
+type fakeRepository struct {
+	Repository
+	sessions map[string]*Session
+	tokens   map[string]*RefreshToken
+}
+
+func (r *fakeRepository) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	r.sessions[session.ID] = session
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *fakeRepository) FindByID(ctx context.Context, sessionID string) (*Session, error) {
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *fakeRepository) FindToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeRepository) Rotate(ctx context.Context, token *RefreshToken, next *RefreshToken, now time.Time) error {
+	if r.tokens[token.TokenHash].IsUsed() {
+		return ErrTokenReused
+	}
+	r.tokens[token.TokenHash].UsedAt = &now
+	r.tokens[next.TokenHash] = next
+	return nil
+}
+
+func (r *fakeRepository) Revoke(ctx context.Context, sessionID string, now time.Time) error {
+	r.sessions[sessionID].RevokedAt = &now
+	return nil
+}
+
+type fakeRoleResolver struct{}
+
+func (fakeRoleResolver) Resolve(ctx context.Context, userID uint, userType auth.UserType) ([]auth.Role, []auth.Permission, error) {
+	return nil, nil, nil
+}
+
+type fakeAccountFinder struct {
+	account Account
+}
+
+func (f *fakeAccountFinder) FindAccount(ctx context.Context, userID uint) (*Account, error) {
+	account := f.account
+	return &account, nil
+}
+
+func newTestService() (*defaultService, *fakeRepository, *fakeAccountFinder) {
+	repo := &fakeRepository{sessions: map[string]*Session{}, tokens: map[string]*RefreshToken{}}
+	accounts := &fakeAccountFinder{account: Account{UserName: "ayse", UserType: auth.IndividualUser, Active: true}}
+	tokens := auth.NewJWT("ticket", "ticket", auth.NewHMACKey("test", []byte("secret")))
+
+	return &defaultService{repo: repo, tokens: tokens, roles: fakeRoleResolver{}, accounts: accounts}, repo, accounts
+}
+
+func TestService_Refresh_Rotation(t *testing.T) {
+	s, _, accounts := newTestService()
+
+	started, err := s.Start(context.Background(), 7, "ayse", auth.IndividualUser, Client{})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	accounts.account.UserType = auth.CorporateUser
+	refreshed, err := s.Refresh(context.Background(), started.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if refreshed.RefreshToken == started.RefreshToken {
+		t.Error("Refresh() kept the refresh token, want a new one")
+	}
+
+	claims, err := s.tokens.Parse(refreshed.AccessToken)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if claims.UserType != auth.CorporateUser {
+		t.Errorf("UserType = %v, want the current type %v", claims.UserType, auth.CorporateUser)
+	}
+
+	if refreshed, err = s.Refresh(context.Background(), refreshed.RefreshToken); err != nil {
+		t.Fatalf("Refresh() with the new token error = %v", err)
+	}
+
+	accounts.account.Active = false
+	if _, err = s.Refresh(context.Background(), refreshed.RefreshToken); err != ErrSessionRevoked {
+		t.Errorf("Refresh() of a deactivated user error = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestService_Refresh_Reuse(t *testing.T) {
+	s, repo, _ := newTestService()
+
+	started, err := s.Start(context.Background(), 7, "ayse", auth.IndividualUser, Client{})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	refreshed, err := s.Refresh(context.Background(), started.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if _, err = s.Refresh(context.Background(), started.RefreshToken); err != ErrTokenReused {
+		t.Fatalf("Refresh() with a used token error = %v, want %v", err, ErrTokenReused)
+	}
+	for _, session := range repo.sessions {
+		if session.IsActive(time.Now()) {
+			t.Error("session of a reused token is still active")
+		}
+	}
+	if _, err = s.Refresh(context.Background(), refreshed.RefreshToken); err != ErrSessionRevoked {
+		t.Errorf("Refresh() after reuse error = %v, want %v", err, ErrSessionRevoked)
+	}
+}
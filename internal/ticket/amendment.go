@@ -0,0 +1,141 @@
+package ticket
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// MaxAmendments is how many times the passenger details of a ticket may be amended.
+	MaxAmendments = 2
+	// MaxNameCorrection is how many letters of the full name as issued may be
+	// corrected over all amendments. A larger change would hand the ticket
+	// over to another person.
+	MaxNameCorrection = 3
+)
+
+var (
+	ErrEmptyAmendment      = errors.New("amendment does not change anything")
+	ErrNotAmendable        = errors.New("ticket cannot be amended in its status")
+	ErrExceedAmendments    = errors.New("ticket cannot be amended anymore")
+	ErrNameChangeTooLarge  = errors.New("only a correction of the full name is allowed")
+	ErrConcurrentAmendment = errors.New("ticket has been changed in the meantime")
+)
+
+// Amendment holds the passenger details which may be corrected on an issued
+// ticket. Empty fields are left untouched.
+type Amendment struct {
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+}
+
+// TicketVersion keeps the passenger details of a ticket before an amendment.
+type TicketVersion struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	TicketID  int    `gorm:"not null;uniqueIndex:idx_ticket_version" json:"ticket_id"`
+	Version   int    `gorm:"not null;uniqueIndex:idx_ticket_version" json:"version"`
+	FullName  string `gorm:"not null" json:"full_name"`
+	Email     string `gorm:"not null" json:"email"`
+	Phone     string `gorm:"not null" json:"phone"`
+	UserID    uint   `gorm:"not null" json:"user_id"`
+	CreatedAt time.Time
+}
+
+func (a *Amendment) IsEmpty() bool {
+	return a.FullName == "" && a.Email == "" && a.Phone == ""
+}
+
+// Apply returns the passenger with the amended details, or an error when
+// the amendment is out of the policy limits. The full name is compared with
+// issuedName, the name the ticket has been issued for, so corrections do
+// not add up to another name.
+func (a *Amendment) Apply(passenger Passenger, issuedName string) (Passenger, error) {
+	amended := passenger
+	if a.FullName != "" {
+		amended.FullName = strings.TrimSpace(a.FullName)
+	}
+	if a.Email != "" {
+		amended.Email = a.Email
+	}
+	if a.Phone != "" {
+		amended.Phone = a.Phone
+	}
+
+	if amended.FullName == passenger.FullName && amended.Email == passenger.Email && amended.Phone == passenger.Phone {
+		return Passenger{}, ErrEmptyAmendment
+	}
+
+	if nameDistance(issuedName, amended.FullName) > MaxNameCorrection {
+		return Passenger{}, ErrNameChangeTooLarge
+	}
+
+	return amended, nil
+}
+
+// Snapshot records the passenger details of the ticket at its current version.
+func (t *Ticket) Snapshot(userID uint) TicketVersion {
+	return TicketVersion{
+		TicketID: t.ID,
+		Version:  t.Version,
+		FullName: t.FullName,
+		Email:    t.Email,
+		Phone:    t.Phone,
+		UserID:   userID,
+	}
+}
+
+// IssuedName returns the full name the ticket has been issued for, before
+// any amendment, given the versions of the ticket.
+func (t *Ticket) IssuedName(versions []TicketVersion) string {
+	for i := range versions {
+		if versions[i].Version == 1 {
+			return versions[i].FullName
+		}
+	}
+	return t.FullName
+}
+
+// CanBeAmended reports whether the ticket is still within the amendment limit.
+func (t *Ticket) CanBeAmended() bool {
+	return t.Version <= MaxAmendments
+}
+
+// nameDistance is the number of letters to insert, delete or replace to turn
+// one name into the other, ignoring case.
+func nameDistance(from, to string) int {
+	a := []rune(strings.Map(unicode.ToLower, from))
+	b := []rune(strings.Map(unicode.ToLower, to))
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,77 @@
+package ticket
+
+import (
+	"testing"
+)
+
+func TestAmendment_Apply(t *testing.T) {
+	passenger := Passenger{FullName: "Dilara Gorum", Email: "dilara@mail.com", Phone: "5551112233"}
+
+	tests := []struct {
+		name        string
+		amendment   Amendment
+		issuedName  string
+		expected    Passenger
+		expectedErr error
+	}{
+		{
+			name:      "typo in full name",
+			amendment: Amendment{FullName: "Dilara Görüm"},
+			expected:  Passenger{FullName: "Dilara Görüm", Email: "dilara@mail.com", Phone: "5551112233"},
+		},
+		{
+			name:      "case of full name",
+			amendment: Amendment{FullName: "DILARA GORUM"},
+			expected:  Passenger{FullName: "DILARA GORUM", Email: "dilara@mail.com", Phone: "5551112233"},
+		},
+		{
+			name:      "contact details",
+			amendment: Amendment{Email: "dilara@company.com", Phone: "5559998877"},
+			expected:  Passenger{FullName: "Dilara Gorum", Email: "dilara@company.com", Phone: "5559998877"},
+		},
+		{
+			name:        "another person",
+			amendment:   Amendment{FullName: "Ahmet Yilmaz"},
+			expectedErr: ErrNameChangeTooLarge,
+		},
+		{
+			name:        "corrections add up to another name",
+			amendment:   Amendment{FullName: "Dilara Gorumxyz"},
+			issuedName:  "Dilara Go",
+			expectedErr: ErrNameChangeTooLarge,
+		},
+		{
+			name:        "same details",
+			amendment:   Amendment{FullName: "Dilara Gorum"},
+			expectedErr: ErrEmptyAmendment,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuedName := tt.issuedName
+			if issuedName == "" {
+				issuedName = passenger.FullName
+			}
+
+			got, err := tt.amendment.Apply(passenger, issuedName)
+			if err != tt.expectedErr {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.expectedErr)
+			}
+			if got != tt.expected {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTicket_IssuedName(t *testing.T) {
+	ticket := Ticket{Passenger: Passenger{FullName: "Dilara Gorumm"}, Version: 3}
+
+	versions := []TicketVersion{{Version: 1, FullName: "Dilara Gorum"}, {Version: 2, FullName: "Dilara Gorun"}}
+	if got := ticket.IssuedName(versions); got != "Dilara Gorum" {
+		t.Errorf("IssuedName() = %q, want %q", got, "Dilara Gorum")
+	}
+	if got := ticket.IssuedName(nil); got != "Dilara Gorumm" {
+		t.Errorf("IssuedName() without versions = %q, want %q", got, "Dilara Gorumm")
+	}
+}
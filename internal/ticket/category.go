@@ -0,0 +1,152 @@
+package ticket
+
+import (
+	"errors"
+	"time"
+)
+
+type Category string
+
+const (
+	Adult   Category = "adult"
+	Child   Category = "child"
+	Infant  Category = "infant"
+	Student Category = "student"
+	Senior  Category = "senior"
+
+	InfantMaxAge = 2
+	ChildMaxAge  = 12
+	SeniorMinAge = 65
+)
+
+var (
+	ErrInvalidCategory      = errors.New("passenger category is invalid")
+	ErrCategoryMismatch     = errors.New("passenger category does not match the birth date")
+	ErrExceedInfantPerAdult = errors.New("every infant on lap should travel with a separate adult")
+	ErrBirthDateRequired    = errors.New("birth date is required for child, infant and senior passengers")
+	ErrAdultRequired        = errors.New("every trip of an order should have an adult passenger")
+)
+
+// CategoryFareRates are the shares of the fare class price paid by each
+// passenger category. Infants on lap pay the infant rate, infants with their
+// own seat pay the child rate.
+var CategoryFareRates = map[Category]float64{
+	Adult:   1,
+	Child:   0.5,
+	Infant:  0.1,
+	Student: 0.8,
+	Senior:  0.7,
+}
+
+func (c Category) IsValid() bool {
+	_, ok := CategoryFareRates[c]
+	return ok
+}
+
+// RequiresBirthDate reports whether passengers may only be of the category
+// as their birth date tells, since the category pays a reduced fare.
+func (c Category) RequiresBirthDate() bool {
+	return c == Child || c == Infant || c == Senior
+}
+
+// CanHoldInfant reports whether a passenger of the category may travel with an infant on lap.
+func (c Category) CanHoldInfant() bool {
+	return c == Adult || c == Student || c == Senior
+}
+
+// ResolveCategory derives the category of the passenger from the birth date
+// at the departure of the trip. Without a birth date the passenger is an adult,
+// or a student when declared so, and student is kept for passengers at adult age.
+func (p *Passenger) ResolveCategory(departure time.Time) (Category, error) {
+	if p.Category != "" && !p.Category.IsValid() {
+		return "", ErrInvalidCategory
+	}
+
+	if p.BirthDate == "" {
+		if p.Category.RequiresBirthDate() {
+			return "", ErrBirthDateRequired
+		}
+		if p.Category == "" {
+			return Adult, nil
+		}
+		return p.Category, nil
+	}
+
+	birthDate, err := p.BirthDate.Time()
+	if err != nil {
+		return "", ErrInvalidBirthDate
+	}
+
+	category := categoryOfAge(age(birthDate, departure))
+	if p.Category == "" || p.Category == category {
+		return category, nil
+	}
+	if p.Category == Student && category == Adult {
+		return Student, nil
+	}
+
+	return "", ErrCategoryMismatch
+}
+
+// FareRate is the share of the fare class price paid by the passenger.
+func (p *Passenger) FareRate() float64 {
+	if p.Category == Infant && p.InfantSeat {
+		return CategoryFareRates[Child]
+	}
+
+	rate, ok := CategoryFareRates[p.Category]
+	if !ok {
+		return CategoryFareRates[Adult]
+	}
+	return rate
+}
+
+// IsOnLap reports whether the passenger travels without a seat of their own.
+func (p *Passenger) IsOnLap() bool {
+	return p.Category == Infant && !p.InfantSeat
+}
+
+func categoryOfAge(years int) Category {
+	switch {
+	case years < InfantMaxAge:
+		return Infant
+	case years < ChildMaxAge:
+		return Child
+	case years >= SeniorMinAge:
+		return Senior
+	default:
+		return Adult
+	}
+}
+
+func age(birthDate, on time.Time) int {
+	years := on.Year() - birthDate.Year()
+	if on.Month() < birthDate.Month() || (on.Month() == birthDate.Month() && on.Day() < birthDate.Day()) {
+		years--
+	}
+	return years
+}
+
+// checkInfantLimit allows at most one infant on lap for every passenger who
+// may hold them, and asks for at least one such passenger on every trip.
+func checkInfantLimit(passengers []Passenger) error {
+	var infants, holders int
+
+	for i := range passengers {
+		if passengers[i].IsOnLap() {
+			infants++
+		}
+		if passengers[i].Category.CanHoldInfant() {
+			holders++
+		}
+	}
+
+	if infants > holders {
+		return ErrExceedInfantPerAdult
+	}
+	if holders == 0 {
+		return ErrAdultRequired
+	}
+
+	return nil
+}
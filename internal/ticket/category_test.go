@@ -0,0 +1,87 @@
+package ticket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPassenger_ResolveCategory(t *testing.T) {
+	departure := time.Date(2023, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		passenger   Passenger
+		expected    Category
+		expectedErr error
+	}{
+		{name: "no birth date nor category", passenger: Passenger{}, expected: Adult},
+		{name: "declared without birth date", passenger: Passenger{Category: Student}, expected: Student},
+		{name: "infant", passenger: Passenger{BirthDate: "2022-01-01"}, expected: Infant},
+		{name: "second birthday on departure", passenger: Passenger{BirthDate: "2021-06-15"}, expected: Child},
+		{name: "day before second birthday", passenger: Passenger{BirthDate: "2021-06-16"}, expected: Infant},
+		{name: "senior", passenger: Passenger{BirthDate: "1950-01-01"}, expected: Senior},
+		{name: "student at adult age", passenger: Passenger{BirthDate: "2001-01-01", Category: Student}, expected: Student},
+		{name: "declared child at adult age", passenger: Passenger{BirthDate: "2001-01-01", Category: Child}, expectedErr: ErrCategoryMismatch},
+		{name: "unknown category", passenger: Passenger{Category: "pet"}, expectedErr: ErrInvalidCategory},
+		{name: "declared child without birth date", passenger: Passenger{Category: Child}, expectedErr: ErrBirthDateRequired},
+		{name: "declared infant without birth date", passenger: Passenger{Category: Infant}, expectedErr: ErrBirthDateRequired},
+		{name: "declared senior without birth date", passenger: Passenger{Category: Senior}, expectedErr: ErrBirthDateRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.passenger.ResolveCategory(departure)
+			if err != tt.expectedErr {
+				t.Fatalf("ResolveCategory() error = %v, want %v", err, tt.expectedErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ResolveCategory() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckInfantLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		passengers []Passenger
+		expected   error
+	}{
+		{
+			name:       "one infant per adult",
+			passengers: []Passenger{{Category: Adult}, {Category: Infant}},
+			expected:   nil,
+		},
+		{
+			name:       "two infants with one adult",
+			passengers: []Passenger{{Category: Adult}, {Category: Infant}, {Category: Infant}},
+			expected:   ErrExceedInfantPerAdult,
+		},
+		{
+			name:       "infant with its own seat",
+			passengers: []Passenger{{Category: Adult}, {Category: Infant}, {Category: Infant, InfantSeat: true}},
+			expected:   nil,
+		},
+		{
+			name:       "infant with a child",
+			passengers: []Passenger{{Category: Child}, {Category: Infant}},
+			expected:   ErrExceedInfantPerAdult,
+		},
+		{
+			name:       "only an infant with its own seat",
+			passengers: []Passenger{{Category: Infant, InfantSeat: true}},
+			expected:   ErrAdultRequired,
+		},
+		{
+			name:       "only children",
+			passengers: []Passenger{{Category: Child}, {Category: Child}},
+			expected:   ErrAdultRequired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkInfantLimit(tt.passengers); got != tt.expected {
+				t.Errorf("checkInfantLimit() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package ticket
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type DocumentType string
+
+const (
+	NationalID DocumentType = "national_id"
+	Passport   DocumentType = "passport"
+
+	BirthDateLayout    = "2006-01-02"
+	TurkishNationality = "TR"
+)
+
+var (
+	ErrInvalidDocumentType    = errors.New("document type is invalid")
+	ErrInvalidNationalID      = errors.New("national ID number is invalid")
+	ErrInvalidPassport        = errors.New("passport number is invalid")
+	ErrInvalidBirthDate       = errors.New("birth date is invalid")
+	ErrInvalidNationality     = errors.New("nationality is invalid")
+	ErrTravelDocumentRequired = errors.New("birth date and nationality are required for this trip")
+)
+
+var (
+	passportRegexp    = regexp.MustCompile(`^[A-Z0-9]{6,9}$`)
+	nationalityRegexp = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// DocumentNumber only shows its last characters when it is printed or sent in a response.
+type DocumentNumber string
+
+func (n DocumentNumber) String() string {
+	return mask(string(n), 3)
+}
+
+func (n DocumentNumber) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.String())
+}
+
+// BirthDate is written like 2006-01-02. Only the year is shown when it is
+// printed or sent in a response.
+type BirthDate string
+
+func (d BirthDate) String() string {
+	if _, err := d.Time(); err != nil {
+		return mask(string(d), 0)
+	}
+	return string(d[:4]) + "-**-**"
+}
+
+func (d BirthDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d BirthDate) Time() (time.Time, error) {
+	return time.Parse(BirthDateLayout, string(d))
+}
+
+func mask(value string, visible int) string {
+	if len(value) <= visible {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-visible) + value[len(value)-visible:]
+}
+
+// KeepMasked takes the current identity details back where they are sent
+// masked, as they are shown in responses, so a passenger which has been
+// fetched can be sent back as it is to change its other details.
+func (p *Passenger) KeepMasked(current Passenger) {
+	if p.DocumentNumber != "" && string(p.DocumentNumber) == current.DocumentNumber.String() {
+		p.DocumentNumber = current.DocumentNumber
+	}
+	if p.BirthDate != "" && string(p.BirthDate) == current.BirthDate.String() {
+		p.BirthDate = current.BirthDate
+	}
+}
+
+// NormalizeDocument trims the identity details and writes them in upper case.
+func (p *Passenger) NormalizeDocument() {
+	p.DocumentNumber = DocumentNumber(strings.ToUpper(strings.TrimSpace(string(p.DocumentNumber))))
+	p.Nationality = strings.ToUpper(strings.TrimSpace(p.Nationality))
+	p.BirthDate = BirthDate(strings.TrimSpace(string(p.BirthDate)))
+}
+
+// ValidateDocument checks the format of the identity details which are given.
+func (p *Passenger) ValidateDocument(now time.Time) error {
+	if p.DocumentType != "" || !p.isDocumentNumberEmpty() {
+		switch p.DocumentType {
+		case NationalID:
+			if !IsValidNationalID(string(p.DocumentNumber)) {
+				return ErrInvalidNationalID
+			}
+			if p.Nationality != "" && p.Nationality != TurkishNationality {
+				return ErrInvalidNationalID
+			}
+		case Passport:
+			if !passportRegexp.MatchString(string(p.DocumentNumber)) {
+				return ErrInvalidPassport
+			}
+		default:
+			return ErrInvalidDocumentType
+		}
+	}
+
+	if p.BirthDate != "" {
+		birthDate, err := p.BirthDate.Time()
+		if err != nil || birthDate.After(now) {
+			return ErrInvalidBirthDate
+		}
+	}
+
+	if p.Nationality != "" && !nationalityRegexp.MatchString(p.Nationality) {
+		return ErrInvalidNationality
+	}
+
+	return nil
+}
+
+// CheckDocument reports whether the passenger gave the identity details
+// asked by the vehicle type of a trip.
+func (p *Passenger) CheckDocument(requirement fleet.DocumentRequirement) error {
+	switch requirement {
+	case fleet.IdentityDocument:
+		if p.isDocumentNumberEmpty() {
+			return ErrDocumentRequired
+		}
+	case fleet.TravelDocument:
+		if p.isDocumentNumberEmpty() {
+			return ErrDocumentRequired
+		}
+		if p.BirthDate == "" || p.Nationality == "" {
+			return ErrTravelDocumentRequired
+		}
+	}
+
+	return nil
+}
+
+// IsValidNationalID checks the number and the checksum digits of a Turkish
+// national ID (TC Kimlik No).
+func IsValidNationalID(number string) bool {
+	if len(number) != 11 || number[0] == '0' {
+		return false
+	}
+
+	var digits [11]int
+	for i, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+		digits[i] = int(r - '0')
+	}
+
+	odd := digits[0] + digits[2] + digits[4] + digits[6] + digits[8]
+	even := digits[1] + digits[3] + digits[5] + digits[7]
+	if ((odd*7-even)%10+10)%10 != digits[9] {
+		return false
+	}
+
+	sum := 0
+	for _, digit := range digits[:10] {
+		sum += digit
+	}
+
+	return sum%10 == digits[10]
+}
@@ -0,0 +1,145 @@
+package ticket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestIsValidNationalID(t *testing.T) {
+	tests := []struct {
+		name     string
+		number   string
+		expected bool
+	}{
+		{name: "valid", number: "10000000146", expected: true},
+		{name: "wrong tenth digit", number: "10000000156", expected: false},
+		{name: "wrong last digit", number: "10000000147", expected: false},
+		{name: "leading zero", number: "00000000146", expected: false},
+		{name: "too short", number: "1000000014", expected: false},
+		{name: "not a number", number: "1000000014A", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidNationalID(tt.number); got != tt.expected {
+				t.Errorf("IsValidNationalID(%q) = %v, want %v", tt.number, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPassenger_ValidateDocument(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		passenger Passenger
+		expected  error
+	}{
+		{
+			name:      "no document",
+			passenger: Passenger{},
+			expected:  nil,
+		},
+		{
+			name:      "turkish national id",
+			passenger: Passenger{DocumentType: NationalID, DocumentNumber: "10000000146", Nationality: "TR"},
+			expected:  nil,
+		},
+		{
+			name:      "national id of another country",
+			passenger: Passenger{DocumentType: NationalID, DocumentNumber: "10000000146", Nationality: "DE"},
+			expected:  ErrInvalidNationalID,
+		},
+		{
+			name:      "passport",
+			passenger: Passenger{DocumentType: Passport, DocumentNumber: "U12345678", BirthDate: "1990-05-14", Nationality: "DE"},
+			expected:  nil,
+		},
+		{
+			name:      "short passport",
+			passenger: Passenger{DocumentType: Passport, DocumentNumber: "U123"},
+			expected:  ErrInvalidPassport,
+		},
+		{
+			name:      "number without type",
+			passenger: Passenger{DocumentNumber: "U12345678"},
+			expected:  ErrInvalidDocumentType,
+		},
+		{
+			name:      "birth date in the future",
+			passenger: Passenger{BirthDate: "2024-01-01"},
+			expected:  ErrInvalidBirthDate,
+		},
+		{
+			name:      "nationality not a country code",
+			passenger: Passenger{Nationality: "TUR"},
+			expected:  ErrInvalidNationality,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.passenger.ValidateDocument(now); got != tt.expected {
+				t.Errorf("ValidateDocument() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPassenger_Masking(t *testing.T) {
+	passenger := Passenger{DocumentType: Passport, DocumentNumber: "U12345678", BirthDate: "1990-05-14"}
+
+	body, err := json.Marshal(passenger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err = json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["document_number"] != "******678" {
+		t.Errorf("document_number = %q, want %q", got["document_number"], "******678")
+	}
+	if got["birth_date"] != "1990-**-**" {
+		t.Errorf("birth_date = %q, want %q", got["birth_date"], "1990-**-**")
+	}
+}
+
+func TestPassenger_KeepMasked(t *testing.T) {
+	current := Passenger{DocumentType: Passport, DocumentNumber: "U12345678", BirthDate: "1990-05-14"}
+
+	body, err := json.Marshal(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedNumber DocumentNumber
+		expectedBirth  BirthDate
+	}{
+		{name: "sent back as fetched", body: string(body), expectedNumber: "U12345678", expectedBirth: "1990-05-14"},
+		{name: "changed values", body: `{"document_type":"passport","document_number":"U87654321","birth_date":"1991-01-01"}`, expectedNumber: "U87654321", expectedBirth: "1991-01-01"},
+		{name: "removed values", body: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var passenger Passenger
+			if err := json.Unmarshal([]byte(tt.body), &passenger); err != nil {
+				t.Fatal(err)
+			}
+
+			passenger.KeepMasked(current)
+
+			if passenger.DocumentNumber != tt.expectedNumber || passenger.BirthDate != tt.expectedBirth {
+				t.Errorf("KeepMasked() = %q, %q, want %q, %q", passenger.DocumentNumber, passenger.BirthDate, tt.expectedNumber, tt.expectedBirth)
+			}
+			if err := passenger.ValidateDocument(time.Now()); err != nil {
+				t.Errorf("ValidateDocument() = %v", err)
+			}
+		})
+	}
+}
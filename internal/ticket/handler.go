@@ -1,10 +1,19 @@
 package ticket
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/dilaragorum/online-ticket-project-go/internal/loyalty"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/dilaragorum/online-ticket-project-go/internal/waitlist"
+	"github.com/dilaragorum/online-ticket-project-go/internal/wallet"
 	"github.com/labstack/echo/v4"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -18,9 +27,58 @@ var (
 
 	WarnWhenExceedMaleTicketNumber = "You are not allowed to purchase ticket for male more than 2"
 
-	WarnWhenCapacityFull     = "Capacity is full. Please search another trip"
+	WarnWhenCapacityFull     = "Capacity is full. Please search another trip or join the waitlist of this trip"
 	WarnWhenTripDoesNotExist = "This trip does not exist. Please check trip information."
 
+	WarnWhenInvalidItinerary  = "Trips of the itinerary do not connect. Please search itineraries again."
+	WarnWhenDuplicatedLegTrip = "The same trip cannot be booked twice in an itinerary"
+
+	WarnWhenDocumentRequired       = "Identity document number of every passenger is required for this trip"
+	WarnWhenTravelDocumentRequired = "Birth date and nationality of every passenger are required for this trip"
+	WarnWhenDocumentTypeInvalid    = "Document type should be national_id or passport"
+	WarnWhenNationalIDInvalid      = "Please enter valid national ID number"
+	WarnWhenPassportInvalid        = "Please enter valid passport number"
+	WarnWhenBirthDateInvalid       = "Birth date should be written like 2006-01-02 and not be in the future"
+	WarnWhenNationalityInvalid     = "Nationality should be a two letter country code"
+	WarnWhenCategoryInvalid        = "Passenger category should be adult, child, infant, student or senior"
+	WarnWhenCategoryMismatch       = "Passenger category does not match the birth date"
+	WarnWhenExceedInfantPerAdult   = "Every infant on lap should travel with a separate adult"
+	WarnWhenBirthDateRequired      = "Birth date of child, infant and senior passengers is required"
+	WarnWhenAdultRequired          = "Children and infants should travel with an adult passenger"
+	WarnWhenDeckFull               = "Vehicle deck is full. Please search another trip"
+	WarnWhenNoVehicleDeck          = "This trip does not carry vehicles"
+	WarnWhenFareClassInvalid       = "This fare class is not sold on the trip"
+	WarnWhenClaimInvalid           = "This claim link is invalid or expired"
+	WarnWhenTravellerInvalid       = "This saved traveller does not exist"
+	WarnWhenSeatInvalid            = "This seat does not exist on the vehicle or is not sold in this fare class"
+	WarnWhenSeatTaken              = "This seat has already been taken. Please choose another one"
+
+	WarnWhenWalletAmountInvalid = "Wallet amount should be a positive number"
+	WarnWhenCheckoutInQuery     = "Wallet amount and points should be sent in the body with the tickets"
+	WarnWhenWalletBalanceLow    = "Your wallet balance is not enough for this amount"
+	WarnWhenPointsInvalid       = "Points should be a positive number"
+	WarnWhenPointsLow           = "You do not have enough loyalty points"
+	WarnWhenEmailNotVerified    = "Please verify your email address before purchasing tickets"
+
+	WarnWhenInvalidID       = "Please enter valid ID"
+	WarnWhenTicketNotExist  = "This ticket does not exist"
+	WarnWhenTripDeparted    = "This trip has already departed"
+	SuccessCancelledMessage = "Ticket was successfully cancelled"
+
+	WarnWhenNotChangeable  = "Tickets of this fare class cannot be exchanged"
+	WarnWhenDifferentRoute = "Ticket can only be exchanged for a trip on the same route"
+	WarnWhenSameTrip       = "Ticket is already on this trip and fare class"
+
+	WarnWhenEmptyAmendment      = "Amendment does not change any passenger detail"
+	WarnWhenExceedAmendments    = fmt.Sprintf("Passenger details of a ticket can be amended at most %d times", MaxAmendments)
+	WarnWhenNameChangeTooLarge  = fmt.Sprintf("Only a correction of at most %d letters of the full name is allowed", MaxNameCorrection)
+	WarnWhenConcurrentAmendment = "Ticket has been changed in the meantime. Please try again"
+	WarnWhenNotAmendable        = "Passenger details of this ticket cannot be amended anymore"
+
+	WarnWhenInvalidStatus     = "Status should be one of held, pending_payment, issued, checked_in, boarded, cancelled, refunded, no_show or exchanged"
+	WarnWhenInvalidTransition = "This is not possible in the current status of the ticket"
+	WarnWhenCheckInNotOpen    = "Check-in opens 24 hours before the departure"
+
 	WarnSystemFailureMessage = "There is something wrong. Please try again later"
 	SuccessPurchasedMessage  = "Ticket was successfully purchased"
 )
@@ -29,10 +87,19 @@ type handler struct {
 	service Service
 }
 
-func NewHandler(e *echo.Echo, service Service) *handler {
+func NewHandler(routes *auth.Routes, service Service) *handler {
 	h := handler{service: service}
 
-	e.POST("/purchase/:id", h.Purchase)
+	routes.POST("/purchase/:id", h.Purchase, auth.RequirePermission(auth.TicketPurchase))
+	routes.POST("/purchase/itinerary", h.PurchaseItinerary, auth.RequirePermission(auth.TicketPurchase))
+	routes.DELETE("/tickets/:id", h.Cancel, auth.RequirePermission(auth.TicketManage, auth.TicketRefund))
+	routes.POST("/tickets/:id/exchange", h.Exchange, auth.RequirePermission(auth.TicketManage, auth.TicketManageAny))
+	routes.PATCH("/tickets/:id/passenger", h.Amend, auth.RequirePermission(auth.TicketManage, auth.TicketManageAny))
+	routes.GET("/tickets/:id/versions", h.GetVersions, auth.RequirePermission(auth.TicketManage, auth.TicketReadAny))
+	routes.GET("/tickets", h.List, auth.RequirePermission(auth.TicketManage, auth.TicketReadAny))
+	routes.POST("/tickets/:id/check-in", h.CheckIn, auth.RequirePermission(auth.TicketManage, auth.TicketManageAny))
+	routes.POST("/tickets/:id/board", h.Board, auth.RequirePermission(auth.TicketBoard))
+	routes.GET("/tickets/:id/transitions", h.GetTransitions, auth.RequirePermission(auth.TicketManage, auth.TicketReadAny))
 
 	return &h
 }
@@ -40,44 +107,411 @@ func NewHandler(e *echo.Echo, service Service) *handler {
 func (ti *handler) Purchase(c echo.Context) error {
 	claim := c.Get("claim").(auth.Claims)
 
-	var tickets []Ticket
+	// Wallet amount and points used to be query parameters. They are refused
+	// rather than ignored, so an old client is not charged by card instead.
+	if c.QueryParam("wallet") != "" || c.QueryParam("points") != "" {
+		return c.String(http.StatusBadRequest, WarnWhenCheckoutInQuery)
+	}
 
-	if err := c.Bind(&tickets); err != nil {
+	var order PurchaseOrder
+
+	if err := c.Bind(&order); err != nil {
 		return c.String(http.StatusBadRequest, err.Error())
 	}
 
+	tickets := order.Tickets
 	for i := range tickets {
-		ticket := tickets[i]
+		if tickets[i].HasTraveller() {
+			continue
+		}
+		if msg, ok := ValidatePassenger(&tickets[i].Passenger); !ok {
+			return c.String(http.StatusBadRequest, msg)
+		}
+	}
 
-		if ticket.CheckFieldsEmpty() {
-			return c.String(http.StatusBadRequest, WarnWhenEmptyFields)
+	if msg, ok := validateCheckout(order.WalletAmount, order.Points); !ok {
+		return c.String(http.StatusBadRequest, msg)
+	}
+	checkout := Checkout{WalletAmount: order.WalletAmount, Points: order.Points}
+
+	var err error
+	if claimToken := c.QueryParam("claim"); claimToken != "" {
+		err = ti.service.PurchaseClaimed(c.Request().Context(), claimToken, tickets, checkout, claim)
+	} else {
+		err = ti.service.Purchase(c.Request().Context(), tickets, checkout, claim)
+	}
+
+	if err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.String(http.StatusOK, SuccessPurchasedMessage)
+}
+
+func (ti *handler) PurchaseItinerary(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	var order ItineraryOrder
+
+	if err := c.Bind(&order); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if order.CheckFieldsEmpty() {
+		return c.String(http.StatusBadRequest, WarnWhenEmptyFields)
+	}
+
+	if msg, ok := validateCheckout(order.WalletAmount, order.Points); !ok {
+		return c.String(http.StatusBadRequest, msg)
+	}
+
+	for i := range order.Passengers {
+		if msg, ok := ValidatePassenger(&order.Passengers[i]); !ok {
+			return c.String(http.StatusBadRequest, msg)
 		}
+	}
+
+	if err := ti.service.PurchaseItinerary(c.Request().Context(), order, claim); err != nil {
+		return purchaseError(c, err)
+	}
+
+	return c.String(http.StatusOK, SuccessPurchasedMessage)
+}
 
-		if ticket.IsEmailInvalid() {
-			return c.String(http.StatusBadRequest, WarnWhenEmailInvalid)
+// validateCheckout checks what is paid besides the card. A wallet amount which
+// is not a finite number would slip through the balance check.
+func validateCheckout(walletAmount float64, points int) (string, bool) {
+	if walletAmount != 0 && wallet.IsInvalidAmount(walletAmount) {
+		return WarnWhenWalletAmountInvalid, false
+	}
+	if points < 0 {
+		return WarnWhenPointsInvalid, false
+	}
+	return "", true
+}
+
+func (ti *handler) Cancel(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+	}
+
+	toWallet := c.QueryParam("refund_to") == "wallet"
+
+	if err = ti.service.Cancel(c.Request().Context(), ticketID, toWallet, claim); err != nil {
+		switch {
+		case errors.Is(err, ErrTicketNotExist):
+			return c.String(http.StatusNotFound, WarnWhenTicketNotExist)
+		case errors.Is(err, ErrTripDeparted):
+			return c.String(http.StatusBadRequest, WarnWhenTripDeparted)
+		case errors.Is(err, ErrTripNotFound):
+			return c.String(http.StatusBadRequest, WarnWhenTripDoesNotExist)
+		case errors.Is(err, ErrInvalidTransition):
+			return c.String(http.StatusConflict, WarnWhenInvalidTransition)
+		default:
+			return c.String(http.StatusInternalServerError, WarnSystemFailureMessage)
 		}
+	}
+
+	return c.String(http.StatusOK, SuccessCancelledMessage)
+}
+
+func (ti *handler) Exchange(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+	}
 
-		if ticket.IsPhoneNumberInvalid() {
-			return c.String(http.StatusBadRequest, WarnWhenPhoneInvalid)
+	var exchange Exchange
+	if err = c.Bind(&exchange); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if trip.IsInvalidID(exchange.TripID) {
+		return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+	}
+
+	receipt, err := ti.service.Exchange(c.Request().Context(), ticketID, exchange, claim)
+	if err != nil {
+		switch err {
+		case ErrTicketNotExist:
+			return c.String(http.StatusNotFound, WarnWhenTicketNotExist)
+		case ErrTripDeparted:
+			return c.String(http.StatusBadRequest, WarnWhenTripDeparted)
+		case ErrNotChangeable:
+			return c.String(http.StatusBadRequest, WarnWhenNotChangeable)
+		case ErrDifferentRoute:
+			return c.String(http.StatusBadRequest, WarnWhenDifferentRoute)
+		case ErrSameTrip:
+			return c.String(http.StatusBadRequest, WarnWhenSameTrip)
+		case ErrInvalidTransition:
+			return c.String(http.StatusConflict, WarnWhenInvalidTransition)
+		default:
+			return purchaseError(c, err)
 		}
 	}
 
-	if err := ti.service.Purchase(c.Request().Context(), tickets, claim); err != nil {
+	return c.JSON(http.StatusOK, receipt)
+}
+
+func (ti *handler) Amend(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+	}
+
+	var amendment Amendment
+	if err = c.Bind(&amendment); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if amendment.IsEmpty() {
+		return c.String(http.StatusBadRequest, WarnWhenEmptyAmendment)
+	}
+
+	contact := Passenger{Email: amendment.Email, Phone: amendment.Phone}
+	if amendment.Email != "" && contact.IsEmailInvalid() {
+		return c.String(http.StatusBadRequest, WarnWhenEmailInvalid)
+	}
+	if amendment.Phone != "" && contact.IsPhoneNumberInvalid() {
+		return c.String(http.StatusBadRequest, WarnWhenPhoneInvalid)
+	}
+
+	ticket, err := ti.service.Amend(c.Request().Context(), ticketID, amendment, claim)
+	if err != nil {
 		switch err {
-		case ErrExceedAllowedTicketToPurchase(CorporatedLimit):
-			return c.String(http.StatusBadRequest, WarnWhenExceedAllowedTicketToPurchase(CorporatedLimit))
-		case ErrExceedAllowedTicketToPurchase(IndividualLimit):
-			return c.String(http.StatusBadRequest, WarnWhenExceedAllowedTicketToPurchase(IndividualLimit))
-		case ErrExceedMaleTicketNumber:
-			return c.String(http.StatusBadRequest, WarnWhenExceedMaleTicketNumber)
-		case ErrNoCapacity:
-			return c.String(http.StatusBadRequest, WarnWhenCapacityFull)
+		case ErrTicketNotExist:
+			return c.String(http.StatusNotFound, WarnWhenTicketNotExist)
+		case ErrTripDeparted:
+			return c.String(http.StatusBadRequest, WarnWhenTripDeparted)
 		case ErrTripNotFound:
 			return c.String(http.StatusBadRequest, WarnWhenTripDoesNotExist)
+		case ErrEmptyAmendment:
+			return c.String(http.StatusBadRequest, WarnWhenEmptyAmendment)
+		case ErrNotAmendable:
+			return c.String(http.StatusConflict, WarnWhenNotAmendable)
+		case ErrExceedAmendments:
+			return c.String(http.StatusBadRequest, WarnWhenExceedAmendments)
+		case ErrNameChangeTooLarge:
+			return c.String(http.StatusBadRequest, WarnWhenNameChangeTooLarge)
+		case ErrConcurrentAmendment:
+			return c.String(http.StatusConflict, WarnWhenConcurrentAmendment)
 		default:
 			return c.String(http.StatusInternalServerError, WarnSystemFailureMessage)
 		}
 	}
 
-	return c.String(http.StatusOK, SuccessPurchasedMessage)
+	return c.JSON(http.StatusOK, ticket)
+}
+
+func (ti *handler) GetVersions(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+	}
+
+	versions, err := ti.service.GetVersions(c.Request().Context(), ticketID, claim)
+	if err != nil {
+		if errors.Is(err, ErrTicketNotExist) {
+			return c.String(http.StatusNotFound, WarnWhenTicketNotExist)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailureMessage)
+	}
+
+	return c.JSON(http.StatusOK, versions)
+}
+
+func (ti *handler) List(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	var filter ListFilter
+
+	for _, value := range c.QueryParams()["status"] {
+		for _, name := range strings.Split(value, ",") {
+			status := Status(strings.TrimSpace(name))
+			if !status.IsValid() {
+				return c.String(http.StatusBadRequest, WarnWhenInvalidStatus)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if value := c.QueryParam("trip_id"); value != "" {
+		tripID, err := strconv.Atoi(value)
+		if err != nil || trip.IsInvalidID(tripID) {
+			return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+		}
+		filter.TripID = tripID
+	}
+
+	if value := c.QueryParam("user_id"); value != "" {
+		userID, err := strconv.Atoi(value)
+		if err != nil || userID <= 0 {
+			return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+		}
+		filter.UserID = uint(userID)
+	}
+
+	tickets, err := ti.service.List(c.Request().Context(), filter, claim)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailureMessage)
+	}
+
+	return c.JSON(http.StatusOK, tickets)
+}
+
+func (ti *handler) CheckIn(c echo.Context) error {
+	return ti.changeStatus(c, ti.service.CheckIn)
+}
+
+func (ti *handler) Board(c echo.Context) error {
+	return ti.changeStatus(c, ti.service.Board)
+}
+
+func (ti *handler) changeStatus(c echo.Context, change func(ctx context.Context, ticketID int, claims auth.Claims) (*Ticket, error)) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+	}
+
+	ticket, err := change(c.Request().Context(), ticketID, claim)
+	if err != nil {
+		switch err {
+		case ErrTicketNotExist:
+			return c.String(http.StatusNotFound, WarnWhenTicketNotExist)
+		case ErrTripNotFound:
+			return c.String(http.StatusBadRequest, WarnWhenTripDoesNotExist)
+		case ErrInvalidTransition:
+			return c.String(http.StatusConflict, WarnWhenInvalidTransition)
+		case ErrCheckInNotOpen:
+			return c.String(http.StatusBadRequest, WarnWhenCheckInNotOpen)
+		default:
+			return c.String(http.StatusInternalServerError, WarnSystemFailureMessage)
+		}
+	}
+
+	return c.JSON(http.StatusOK, ticket)
+}
+
+func (ti *handler) GetTransitions(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		return c.String(http.StatusBadRequest, WarnWhenInvalidID)
+	}
+
+	transitions, err := ti.service.GetTransitions(c.Request().Context(), ticketID, claim)
+	if err != nil {
+		if errors.Is(err, ErrTicketNotExist) {
+			return c.String(http.StatusNotFound, WarnWhenTicketNotExist)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailureMessage)
+	}
+
+	return c.JSON(http.StatusOK, transitions)
+}
+
+// ValidatePassenger checks the contact and identity details of a passenger
+// and returns the message to show when they are invalid.
+func ValidatePassenger(passenger *Passenger) (string, bool) {
+	if passenger.CheckFieldsEmpty() {
+		return WarnWhenEmptyFields, false
+	}
+
+	if passenger.IsEmailInvalid() {
+		return WarnWhenEmailInvalid, false
+	}
+
+	if passenger.IsPhoneNumberInvalid() {
+		return WarnWhenPhoneInvalid, false
+	}
+
+	passenger.NormalizeDocument()
+	switch passenger.ValidateDocument(time.Now()) {
+	case ErrInvalidDocumentType:
+		return WarnWhenDocumentTypeInvalid, false
+	case ErrInvalidNationalID:
+		return WarnWhenNationalIDInvalid, false
+	case ErrInvalidPassport:
+		return WarnWhenPassportInvalid, false
+	case ErrInvalidBirthDate:
+		return WarnWhenBirthDateInvalid, false
+	case ErrInvalidNationality:
+		return WarnWhenNationalityInvalid, false
+	}
+
+	if passenger.Category != "" && !passenger.Category.IsValid() {
+		return WarnWhenCategoryInvalid, false
+	}
+
+	return "", true
+}
+
+func purchaseError(c echo.Context, err error) error {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return c.String(http.StatusBadRequest, WarnWhenExceedAllowedTicketToPurchase(limitErr.Limit))
+	}
+
+	switch err {
+	case ErrExceedMaleTicketNumber:
+		return c.String(http.StatusBadRequest, WarnWhenExceedMaleTicketNumber)
+	case ErrNoCapacity:
+		return c.String(http.StatusBadRequest, WarnWhenCapacityFull)
+	case ErrTripNotFound:
+		return c.String(http.StatusBadRequest, WarnWhenTripDoesNotExist)
+	case ErrInvalidItinerary:
+		return c.String(http.StatusBadRequest, WarnWhenInvalidItinerary)
+	case ErrDuplicatedLegTrip:
+		return c.String(http.StatusBadRequest, WarnWhenDuplicatedLegTrip)
+	case ErrDocumentRequired:
+		return c.String(http.StatusBadRequest, WarnWhenDocumentRequired)
+	case ErrTravelDocumentRequired:
+		return c.String(http.StatusBadRequest, WarnWhenTravelDocumentRequired)
+	case ErrInvalidCategory:
+		return c.String(http.StatusBadRequest, WarnWhenCategoryInvalid)
+	case ErrCategoryMismatch:
+		return c.String(http.StatusBadRequest, WarnWhenCategoryMismatch)
+	case ErrInvalidBirthDate:
+		return c.String(http.StatusBadRequest, WarnWhenBirthDateInvalid)
+	case ErrExceedInfantPerAdult:
+		return c.String(http.StatusBadRequest, WarnWhenExceedInfantPerAdult)
+	case ErrBirthDateRequired:
+		return c.String(http.StatusBadRequest, WarnWhenBirthDateRequired)
+	case ErrAdultRequired:
+		return c.String(http.StatusBadRequest, WarnWhenAdultRequired)
+	case ErrNoDeckCapacity:
+		return c.String(http.StatusBadRequest, WarnWhenDeckFull)
+	case ErrNoVehicleDeck:
+		return c.String(http.StatusBadRequest, WarnWhenNoVehicleDeck)
+	case ErrFareClassNotFound:
+		return c.String(http.StatusBadRequest, WarnWhenFareClassInvalid)
+	case ErrTravellerNotFound:
+		return c.String(http.StatusBadRequest, WarnWhenTravellerInvalid)
+	case ErrInvalidSeat:
+		return c.String(http.StatusBadRequest, WarnWhenSeatInvalid)
+	case ErrSeatTaken:
+		return c.String(http.StatusConflict, WarnWhenSeatTaken)
+	case wallet.ErrInsufficientBalance:
+		return c.String(http.StatusBadRequest, WarnWhenWalletBalanceLow)
+	case loyalty.ErrInsufficientPoints:
+		return c.String(http.StatusBadRequest, WarnWhenPointsLow)
+	case ErrInvalidClaim, waitlist.ErrInvalidClaim:
+		return c.String(http.StatusBadRequest, WarnWhenClaimInvalid)
+	case ErrEmailNotVerified:
+		return c.String(http.StatusForbidden, WarnWhenEmailNotVerified)
+	default:
+		return c.String(http.StatusInternalServerError, WarnSystemFailureMessage)
+	}
 }
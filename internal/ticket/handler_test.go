@@ -0,0 +1,38 @@
+package ticket
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPurchaseError_Limit(t *testing.T) {
+	tests := []struct {
+		name       string
+		userType   auth.UserType
+		passengers int
+		bonus      int
+		expected   string
+	}{
+		{name: "individual limit", userType: auth.IndividualUser, passengers: IndividualLimit + 1, expected: WarnWhenExceedAllowedTicketToPurchase(IndividualLimit)},
+		{name: "individual limit with tier bonus", userType: auth.IndividualUser, passengers: IndividualLimit + 3, bonus: 2, expected: WarnWhenExceedAllowedTicketToPurchase(IndividualLimit + 2)},
+		{name: "corporate limit with tier bonus", userType: auth.CorporateUser, passengers: CorporatedLimit + 6, bonus: 5, expected: WarnWhenExceedAllowedTicketToPurchase(CorporatedLimit + 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPurchaseLimits(auth.Claims{UserType: tt.userType}, make([]Passenger, tt.passengers), tt.bonus)
+
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/purchase", nil), rec)
+			if err = purchaseError(c, err); err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.Code != http.StatusBadRequest || rec.Body.String() != tt.expected {
+				t.Errorf("purchaseError() = %v %q, want %v %q", rec.Code, rec.Body.String(), http.StatusBadRequest, tt.expected)
+			}
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package ticket
 
 import (
+	"bytes"
+	"encoding/json"
+	"github.com/dilaragorum/online-ticket-project-go/internal/loyalty"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
 	"gorm.io/gorm"
+	"math"
 	"net/mail"
 	"regexp"
 	"time"
@@ -14,31 +19,209 @@ const (
 	Female Gender = "Female"
 )
 
-type Ticket struct {
-	ID     int  `gorm:"primaryKey" json:"id"`
-	TripID int  `gorm:"not null" json:"trip_id"`
-	UserID uint `gorm:"not null" json:"user_id"`
-	Passenger
+// Order groups the tickets bought with a single payment, e.g. every leg of
+// a round trip for every passenger.
+type Order struct {
+	ID         int     `gorm:"primaryKey" json:"id"`
+	UserID     uint    `gorm:"not null" json:"user_id"`
+	TotalPrice float64 `gorm:"not null" json:"total_price"`
+	// WalletAmount is the part of the total paid from the wallet of the user.
+	WalletAmount float64 `gorm:"not null;default:0" json:"wallet_amount"`
+	// Points are the loyalty points redeemed for Discount off the total.
+	Points    int      `gorm:"not null;default:0" json:"points"`
+	Discount  float64  `gorm:"not null;default:0" json:"discount"`
+	Tickets   []Ticket `json:"tickets"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+type ItineraryOrder struct {
+	OutboundTripIDs []int              `json:"outbound_trip_ids"`
+	ReturnTripIDs   []int              `json:"return_trip_ids"`
+	FareClass       trip.FareClassName `json:"fare_class"`
+	Passengers      []Passenger        `json:"passengers"`
+	TravellerIDs    []uint             `json:"traveller_ids"`
+	WalletAmount    float64            `json:"wallet_amount"`
+	Points          int                `json:"points"`
+}
+
+// PurchaseOrder is the body of a purchase: the tickets and how they are paid
+// besides the card. A bare list of tickets is paid by card only.
+type PurchaseOrder struct {
+	Tickets      []Ticket `json:"tickets"`
+	WalletAmount float64  `json:"wallet_amount"`
+	Points       int      `json:"points"`
+}
+
+func (o *PurchaseOrder) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.Unmarshal(data, &o.Tickets)
+	}
+
+	type purchaseOrder PurchaseOrder
+	return json.Unmarshal(data, (*purchaseOrder)(o))
+}
+
+// Checkout tells how an order is paid besides the card: up to WalletAmount
+// from the wallet of the user and Points redeemed for a discount.
+type Checkout struct {
+	WalletAmount float64
+	Points       int
+}
+
+// Exchange asks to move a ticket to another departure on the same route.
+// The fare class of the ticket is kept when FareClass is empty, and a free
+// seat is given when Seat is empty.
+type Exchange struct {
+	TripID    int                `json:"trip_id"`
+	FareClass trip.FareClassName `json:"fare_class"`
+	Seat      string             `json:"seat"`
+}
+
+// ExchangeReceipt tells what was paid for an exchange. Amount is charged
+// when positive and refunded when negative.
+type ExchangeReceipt struct {
+	Ticket         Ticket  `json:"ticket"`
+	FareDifference float64 `json:"fare_difference"`
+	ChangeFee      float64 `json:"change_fee"`
+	Amount         float64 `json:"amount"`
+}
+
+type Ticket struct {
+	ID      int  `gorm:"primaryKey" json:"id"`
+	TripID  int  `gorm:"not null;uniqueIndex:idx_ticket_trip_seat,priority:1" json:"trip_id"`
+	UserID  uint `gorm:"not null" json:"user_id"`
+	OrderID int  `gorm:"index" json:"order_id"`
+	// Seat is the seat number on the vehicle of the trip, see assignSeats. A
+	// seat is taken by one ticket at a time, until the ticket gives it back.
+	Seat string `gorm:"uniqueIndex:idx_ticket_trip_seat,priority:2,where:seat <> '' AND deleted_at IS NULL" json:"seat,omitempty"`
+	// FareClass is the cabin the seat is sold from, Price is what was paid for it.
+	FareClass trip.FareClassName `gorm:"not null;default:economy" json:"fare_class"`
+	Price     float64            `gorm:"not null;default:0" json:"price"`
+	// TravellerID refers to a saved traveller used instead of inline passenger details.
+	TravellerID uint `gorm:"index" json:"traveller_id,omitempty"`
+	Passenger
+	// Status is where the ticket is in its lifecycle, see transitions.
+	Status Status `gorm:"not null;default:issued;index" json:"status"`
+	// ExchangedFrom is the ticket this one has been issued in place of.
+	ExchangedFrom int `gorm:"index" json:"exchanged_from,omitempty"`
+	// NoShowCredit is what was credited back by the no-show policy of the fare class.
+	NoShowCredit float64 `gorm:"not null;default:0" json:"no_show_credit,omitempty"`
+	// NoShowCredited tells whether NoShowCredit has been booked on the wallet of the user.
+	NoShowCredited bool `gorm:"not null;default:false" json:"-"`
+	// PointsPending tells the loyalty points of the ticket failed to be
+	// accrued or reversed, and are retried.
+	PointsPending bool `gorm:"not null;default:false" json:"-"`
+	// RefundPending tells the refund of the cancelled ticket failed and is
+	// retried. PendingWallet and PendingPoints are what is still to be given
+	// back to the wallet and the loyalty account.
+	RefundPending bool    `gorm:"not null;default:false" json:"-"`
+	PendingWallet float64 `gorm:"not null;default:0" json:"-"`
+	PendingPoints int     `gorm:"not null;default:0" json:"-"`
+	// Version is increased on every amendment of the passenger details, see TicketVersion.
+	Version int `gorm:"not null;default:1" json:"version"`
+	// OnLap tickets are sold to infants who do not take a seat.
+	OnLap bool `gorm:"not null;default:false" json:"on_lap"`
+	// VehiclePlate is the car the passenger takes on the vehicle deck of a ferry.
+	VehiclePlate string `json:"vehicle_plate,omitempty"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
 type Passenger struct {
 	Gender   Gender `gorm:"not null" json:"gender"`
 	FullName string `gorm:"not null" json:"full_name"`
 	Email    string `gorm:"not null" json:"email"`
 	Phone    string `gorm:"not null" json:"phone"`
+	// Identity details are asked depending on the vehicle type of the trip,
+	// see CheckDocument. They are masked in responses and logs.
+	DocumentType   DocumentType   `json:"document_type,omitempty"`
+	DocumentNumber DocumentNumber `json:"document_number,omitempty"`
+	BirthDate      BirthDate      `json:"birth_date,omitempty"`
+	Nationality    string         `json:"nationality,omitempty"`
+	// Category is derived from the birth date when it is given, see ResolveCategory.
+	// Infants travel on lap unless InfantSeat is set.
+	Category   Category `gorm:"not null;default:adult" json:"category,omitempty"`
+	InfantSeat bool     `gorm:"not null;default:false" json:"infant_seat,omitempty"`
 }
 
-func (t *Ticket) CheckFieldsEmpty() bool {
-	return t.isGenderEmpty() || t.isFullNameEmpty() || t.isEmailEmpty() || t.isPhoneEmpty()
+// Reservation is what an order takes from a trip. Claim is the waitlist
+// offer whose held seats the order takes, seats held for other offers are
+// left to them.
+type Reservation struct {
+	Seats       int
+	DeckSpaces  int
+	FareClasses map[trip.FareClassName]int
+	Claim       uint
+}
+
+// CardAmount is the part of the total paid by card.
+func (o *Order) CardAmount() float64 {
+	return o.TotalPrice - o.Discount - o.WalletAmount
+}
+
+// RefundShares splits what is given back for a ticket of the order by how the
+// order was paid: the share paid from the wallet goes back to the wallet, the
+// share paid by points goes back as Points and the rest to the card.
+type RefundShares struct {
+	Card   float64
+	Wallet float64
+	Points int
+}
+
+func (o *Order) RefundShares(amount float64) RefundShares {
+	if o.TotalPrice <= 0 {
+		return RefundShares{Card: amount}
+	}
+
+	walletShare := amount * o.WalletAmount / o.TotalPrice
+	pointsShare := amount * o.Discount / o.TotalPrice
+	return RefundShares{
+		Card:   amount - walletShare - pointsShare,
+		Wallet: walletShare,
+		Points: int(math.Min(math.Round(pointsShare/loyalty.PointValue), float64(o.Points))),
+	}
+}
+
+// CardShare is the part of price of a ticket of the order paid by card.
+func (o *Order) CardShare(price float64) float64 {
+	if o.TotalPrice <= 0 {
+		return price
+	}
+
+	return price * o.CardAmount() / o.TotalPrice
+}
+
+func (o *ItineraryOrder) CheckFieldsEmpty() bool {
+	return len(o.OutboundTripIDs) == 0 || (len(o.Passengers) == 0 && len(o.TravellerIDs) == 0)
+}
+
+func (t *Ticket) HasTraveller() bool {
+	return t.TravellerID != 0
+}
+
+// Seats is the number of seats the ticket takes on its trip.
+func (t *Ticket) Seats() int {
+	if t.OnLap {
+		return 0
+	}
+	return 1
+}
+
+func (p *Passenger) CheckFieldsEmpty() bool {
+	return p.isGenderEmpty() || p.isFullNameEmpty() || p.isEmailEmpty() || p.isPhoneEmpty()
 }
 
 func (t *Ticket) isTripIDEmpty() bool {
 	return t.TripID == 0
 }
 
+func (p *Passenger) isDocumentNumberEmpty() bool {
+	return p.DocumentNumber == ""
+}
+
 func (p *Passenger) isGenderEmpty() bool {
 	return p.Gender == ""
 }
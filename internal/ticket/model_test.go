@@ -0,0 +1,51 @@
+package ticket
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPurchaseOrder_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name                 string
+		body                 string
+		expectedTickets      int
+		expectedWalletAmount float64
+		expectedPoints       int
+	}{
+		{name: "tickets with checkout", body: `{"tickets":[{"trip_id":1},{"trip_id":1}],"wallet_amount":25.5,"points":100}`, expectedTickets: 2, expectedWalletAmount: 25.5, expectedPoints: 100},
+		{name: "bare list of tickets", body: ` [{"trip_id":1}]`, expectedTickets: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order PurchaseOrder
+			if err := json.Unmarshal([]byte(tt.body), &order); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if len(order.Tickets) != tt.expectedTickets || order.WalletAmount != tt.expectedWalletAmount || order.Points != tt.expectedPoints {
+				t.Errorf("order = %+v, want %d tickets, %v wallet amount and %d points", order, tt.expectedTickets, tt.expectedWalletAmount, tt.expectedPoints)
+			}
+		})
+	}
+}
+
+func TestOrder_RefundShares(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    Order
+		amount   float64
+		expected RefundShares
+	}{
+		{name: "paid by card", order: Order{TotalPrice: 200}, amount: 90, expected: RefundShares{Card: 90}},
+		{name: "paid by wallet", order: Order{TotalPrice: 200, WalletAmount: 200}, amount: 90, expected: RefundShares{Wallet: 90}},
+		{name: "paid by both", order: Order{TotalPrice: 200, WalletAmount: 50}, amount: 80, expected: RefundShares{Card: 60, Wallet: 20}},
+		{name: "paid with points", order: Order{TotalPrice: 200, WalletAmount: 50, Discount: 50, Points: 5000}, amount: 80, expected: RefundShares{Card: 40, Wallet: 20, Points: 2000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.order.RefundShares(tt.amount); got != tt.expected {
+				t.Errorf("RefundShares() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
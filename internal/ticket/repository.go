@@ -2,13 +2,42 @@ package ticket
 
 import (
 	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/dilaragorum/online-ticket-project-go/internal/waitlist"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/labstack/gommon/log"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
+var ErrTicketNotFound = errors.New("there is no ticket with that id")
+
 type Repository interface {
-	CreateTicketWithDetails(ctx context.Context, ticket *Ticket) error
+	CreateOrder(ctx context.Context, order *Order, reservations map[int]Reservation) error
+	FindByID(ctx context.Context, id int) (*Ticket, error)
+	FindOrder(ctx context.Context, id int) (*Order, error)
+	List(ctx context.Context, filter ListFilter) ([]Ticket, error)
+	UpdateStatus(ctx context.Context, tickets []Ticket, to Status, userID uint) error
+	FindTransitions(ctx context.Context, ticketID int) ([]TicketTransition, error)
+	FindNoShows(ctx context.Context, departedBefore time.Time) ([]Ticket, error)
+	MarkNoShow(ctx context.Context, ticket *Ticket, credit float64) error
+	// FindUncreditedNoShows returns the no-show tickets whose credit has not been booked yet.
+	FindUncreditedNoShows(ctx context.Context) ([]Ticket, error)
+	MarkNoShowCredited(ctx context.Context, ticketID int) error
+	// FindPointsPending returns the tickets whose loyalty points are to be retried.
+	FindPointsPending(ctx context.Context) ([]Ticket, error)
+	SetPointsPending(ctx context.Context, ticketID int, pending bool) error
+	// FindRefundsPending returns the cancelled tickets whose refund is to be retried.
+	FindRefundsPending(ctx context.Context) ([]Ticket, error)
+	// SetRefundPending leaves what is still to be given back for the ticket to be retried.
+	SetRefundPending(ctx context.Context, ticketID int, pending bool, walletAmount float64, points int) error
+	Exchange(ctx context.Context, ticket *Ticket, exchanged *Ticket, userID uint) error
+	// FindTakenSeats returns the seat numbers of the trip taken by tickets.
+	FindTakenSeats(ctx context.Context, tripID int) ([]string, error)
+	Amend(ctx context.Context, ticket *Ticket, amended Passenger, userID uint) error
+	FindVersions(ctx context.Context, ticketID int) ([]TicketVersion, error)
 }
 
 type repository struct {
@@ -19,14 +48,525 @@ func NewTicketRepository(database *gorm.DB) Repository {
 	return &repository{database: database}
 }
 
-func (r *repository) CreateTicketWithDetails(ctx context.Context, ticket *Ticket) error {
+// CreateOrder reserves the requested seats and deck spaces on every trip and
+// stores the order with its tickets in a single transaction.
+func (r *repository) CreateOrder(ctx context.Context, order *Order, reservations map[int]Reservation) error {
 	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	if err := r.database.WithContext(timeoutCtx).Model(&Ticket{}).Create(ticket).Error; err != nil {
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		for tripID, reservation := range reservations {
+			if err := reserve(tx, tripID, reservation); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(order).Error; err != nil {
+			return seatError(err)
+		}
+
+		transitions := make([]TicketTransition, 0, len(order.Tickets))
+		for i := range order.Tickets {
+			transitions = append(transitions, TicketTransition{TicketID: order.Tickets[i].ID, To: order.Tickets[i].Status, UserID: order.UserID})
+		}
+
+		return tx.Create(&transitions).Error
+	})
+	if err != nil && err != ErrNoCapacity && err != ErrSeatTaken {
 		log.Error(err)
+	}
+
+	return err
+}
+
+// Exchange marks the ticket as exchanged and issues the exchanged ticket in
+// its place in a single transaction: the old seat is given back and a seat
+// is reserved on the new trip.
+func (r *repository) Exchange(ctx context.Context, ticket *Ticket, exchanged *Ticket, userID uint) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	old := *ticket
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if err := updateStatus(tx, &old, Exchanged, userID); err != nil {
+			return err
+		}
+
+		reservation := Reservation{
+			Seats:       exchanged.Seats(),
+			FareClasses: map[trip.FareClassName]int{exchanged.FareClass: exchanged.Seats()},
+		}
+		if exchanged.VehiclePlate != "" {
+			reservation.DeckSpaces = 1
+		}
+		if err := reserve(tx, exchanged.TripID, reservation); err != nil {
+			return err
+		}
+
+		if err := tx.Create(exchanged).Error; err != nil {
+			return seatError(err)
+		}
+
+		return tx.Create(&TicketTransition{TicketID: exchanged.ID, To: exchanged.Status, UserID: userID}).Error
+	})
+	if err != nil {
+		if err != ErrNoCapacity && err != ErrInvalidTransition && err != ErrSeatTaken {
+			log.Error(err)
+		}
 		return err
 	}
 
+	ticket.Status = old.Status
+
 	return nil
 }
+
+func (r *repository) FindTakenSeats(ctx context.Context, tripID int) ([]string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var seats []string
+	err := r.database.WithContext(timeoutCtx).Model(&Ticket{}).
+		Where("trip_id = ? AND seat <> ''", tripID).
+		Pluck("seat", &seats).Error
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return seats, nil
+}
+
+// seatError tells a seat taken by a concurrent order apart from other errors.
+func seatError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrSeatTaken
+	}
+	return err
+}
+
+// FindByID also finds tickets which gave their seat back, so their status can be told.
+func (r *repository) FindByID(ctx context.Context, id int) (*Ticket, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var ticket Ticket
+
+	if err := r.database.WithContext(timeoutCtx).Unscoped().First(&ticket, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTicketNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+func (r *repository) FindOrder(ctx context.Context, id int) (*Order, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var order Order
+
+	if err := r.database.WithContext(timeoutCtx).Unscoped().First(&order, id).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func (r *repository) List(ctx context.Context, filter ListFilter) ([]Ticket, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := r.database.WithContext(timeoutCtx).Unscoped()
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.TripID != 0 {
+		query = query.Where("trip_id = ?", filter.TripID)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+
+	var tickets []Ticket
+
+	if err := query.Order("id DESC").Find(&tickets).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+// UpdateStatus moves every ticket to the status in a single transaction.
+// Tickets moving to a status which releases the seat give it back to the trip.
+// The statuses of the given tickets only change once it is committed.
+func (r *repository) UpdateStatus(ctx context.Context, tickets []Ticket, to Status, userID uint) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	updated := append([]Ticket(nil), tickets...)
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		for i := range updated {
+			if err := updateStatus(tx, &updated[i], to, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if err != ErrInvalidTransition {
+			log.Error(err)
+		}
+		return err
+	}
+
+	copy(tickets, updated)
+
+	return nil
+}
+
+func (r *repository) FindTransitions(ctx context.Context, ticketID int) ([]TicketTransition, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var transitions []TicketTransition
+
+	if err := r.database.WithContext(timeoutCtx).
+		Where("ticket_id = ?", ticketID).
+		Order("id").
+		Find(&transitions).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+// Amend keeps the current passenger details of the ticket as a version and
+// writes the amended ones, unless the ticket has been amended in the meantime.
+func (r *repository) Amend(ctx context.Context, ticket *Ticket, amended Passenger, userID uint) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		snapshot := ticket.Snapshot(userID)
+		if err := tx.Create(&snapshot).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrConcurrentAmendment
+			}
+			return err
+		}
+
+		result := tx.Model(&Ticket{}).
+			Where("id = ? AND version = ?", ticket.ID, ticket.Version).
+			Updates(map[string]interface{}{
+				"full_name": amended.FullName,
+				"email":     amended.Email,
+				"phone":     amended.Phone,
+				"version":   gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConcurrentAmendment
+		}
+
+		return nil
+	})
+	if err != nil && err != ErrConcurrentAmendment {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func (r *repository) FindVersions(ctx context.Context, ticketID int) ([]TicketVersion, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var versions []TicketVersion
+
+	if err := r.database.WithContext(timeoutCtx).
+		Where("ticket_id = ?", ticketID).
+		Order("version").
+		Find(&versions).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// FindNoShows returns the tickets not checked in for trips which departed
+// before the given time. Tickets of deleted trips are left out.
+func (r *repository) FindNoShows(ctx context.Context, departedBefore time.Time) ([]Ticket, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var tickets []Ticket
+
+	if err := r.database.WithContext(timeoutCtx).
+		Select("tickets.*").
+		Joins("JOIN trips ON trips.id = tickets.trip_id").
+		Where("tickets.status = ? AND trips.date < ? AND trips.deleted_at IS NULL", Issued, departedBefore).
+		Find(&tickets).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+// MarkNoShow moves the ticket to no-show and records the credit given back for it.
+func (r *repository) MarkNoShow(ctx context.Context, ticket *Ticket, credit float64) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if err := updateStatus(tx, ticket, NoShow, 0); err != nil {
+			return err
+		}
+
+		ticket.NoShowCredit = credit
+		return tx.Model(&Ticket{}).Where("id = ?", ticket.ID).Update("no_show_credit", credit).Error
+	})
+	if err != nil && err != ErrInvalidTransition {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func (r *repository) FindUncreditedNoShows(ctx context.Context) ([]Ticket, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var tickets []Ticket
+
+	if err := r.database.WithContext(timeoutCtx).
+		Where("status = ? AND no_show_credit > 0 AND NOT no_show_credited", NoShow).
+		Find(&tickets).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+func (r *repository) MarkNoShowCredited(ctx context.Context, ticketID int) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Model(&Ticket{}).
+		Where("id = ?", ticketID).
+		Update("no_show_credited", true).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) FindPointsPending(ctx context.Context) ([]Ticket, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var tickets []Ticket
+
+	if err := r.database.WithContext(timeoutCtx).Unscoped().
+		Where("points_pending").
+		Find(&tickets).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+func (r *repository) SetPointsPending(ctx context.Context, ticketID int, pending bool) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Unscoped().Model(&Ticket{}).
+		Where("id = ?", ticketID).
+		Update("points_pending", pending).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) FindRefundsPending(ctx context.Context) ([]Ticket, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var tickets []Ticket
+
+	if err := r.database.WithContext(timeoutCtx).Unscoped().
+		Where("refund_pending").
+		Find(&tickets).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+func (r *repository) SetRefundPending(ctx context.Context, ticketID int, pending bool, walletAmount float64, points int) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Unscoped().Model(&Ticket{}).
+		Where("id = ?", ticketID).
+		Updates(map[string]interface{}{
+			"refund_pending": pending,
+			"pending_wallet": walletAmount,
+			"pending_points": points,
+		}).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// updateStatus moves the ticket to the status unless it has been moved in
+// the meantime, and records the transition.
+func updateStatus(tx *gorm.DB, ticket *Ticket, to Status, userID uint) error {
+	from := ticket.Status
+
+	transition, err := ticket.MoveTo(to, userID)
+	if err != nil {
+		return err
+	}
+
+	result := tx.Unscoped().Model(&Ticket{}).
+		Where("id = ? AND status = ?", ticket.ID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTransition
+	}
+
+	if to.ReleasesSeat() && !from.ReleasesSeat() {
+		if err = releaseSeat(tx, ticket); err != nil {
+			return err
+		}
+		if err = tx.Delete(&Ticket{}, ticket.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Create(&transition).Error
+}
+
+// reserve takes the seats and deck spaces of the reservation from the trip,
+// or fails with ErrNoCapacity when they are not available anymore.
+func reserve(tx *gorm.DB, tripID int, reservation Reservation) error {
+	held, err := heldSeats(tx, tripID, reservation.Claim)
+	if err != nil {
+		return err
+	}
+
+	heldTotal := 0
+	if reservation.Seats > 0 {
+		for _, seats := range held {
+			heldTotal += seats
+		}
+	}
+
+	result := tx.Model(&trip.Trip{}).
+		Where("id = ? AND available_seat >= ? AND available_deck >= ?", tripID, reservation.Seats+heldTotal, reservation.DeckSpaces).
+		Updates(map[string]interface{}{
+			"available_seat": gorm.Expr("available_seat - ?", reservation.Seats),
+			"available_deck": gorm.Expr("available_deck - ?", reservation.DeckSpaces),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNoCapacity
+	}
+
+	for fareClass, seats := range reservation.FareClasses {
+		if seats == 0 {
+			continue
+		}
+
+		result = tx.Model(&trip.FareClass{}).
+			Where("trip_id = ? AND name = ? AND available_seat >= ?", tripID, fareClass, seats+held[fareClass]).
+			Update("available_seat", gorm.Expr("available_seat - ?", seats))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNoCapacity
+		}
+	}
+
+	return nil
+}
+
+// heldSeats returns the seats of the trip held for waitlist offers per fare
+// class, except the offer being claimed. The trip is locked first, so an
+// order waits for the orders and offers of the trip before it.
+func heldSeats(tx *gorm.DB, tripID int, claim uint) (map[trip.FareClassName]int, error) {
+	var locked trip.Trip
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, tripID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoCapacity
+		}
+		return nil, err
+	}
+
+	var rows []struct {
+		FareClass trip.FareClassName
+		Seats     int
+	}
+	err := tx.Model(&waitlist.Entry{}).
+		Select("fare_class, SUM(seats) AS seats").
+		Where("trip_id = ? AND status = ? AND offer_expires_at >= ? AND id <> ?", tripID, waitlist.Offered, time.Now(), claim).
+		Group("fare_class").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[trip.FareClassName]int, len(rows))
+	for _, row := range rows {
+		held[row.FareClass] = row.Seats
+	}
+
+	return held, nil
+}
+
+func releaseSeat(tx *gorm.DB, ticket *Ticket) error {
+	deckSpaces := 0
+	if ticket.VehiclePlate != "" {
+		deckSpaces = 1
+	}
+
+	if err := tx.Model(&trip.Trip{}).Where("id = ?", ticket.TripID).Updates(map[string]interface{}{
+		"available_seat": gorm.Expr("available_seat + ?", ticket.Seats()),
+		"available_deck": gorm.Expr("available_deck + ?", deckSpaces),
+	}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&trip.FareClass{}).
+		Where("trip_id = ? AND name = ?", ticket.TripID, ticket.FareClass).
+		Update("available_seat", gorm.Expr("available_seat + ?", ticket.Seats())).Error
+}
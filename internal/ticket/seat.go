@@ -0,0 +1,105 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+)
+
+var (
+	ErrInvalidSeat = errors.New("seat does not exist on the vehicle or is not sold in this fare class")
+	ErrSeatTaken   = errors.New("seat has already been taken")
+)
+
+// VehicleFinder loads the vehicle of a trip to find its seats.
+type VehicleFinder interface {
+	FindByID(ctx context.Context, id uint) (*fleet.Vehicle, error)
+}
+
+// assignSeats gives the tickets of a trip their seats from the seat layout of
+// the vehicle. Trips without a vehicle are sold without seat numbers.
+// released is a seat given back in the same transaction the tickets are
+// stored in, as when a ticket is exchanged within its trip.
+func (s *defaultService) assignSeats(ctx context.Context, requestedTrip *trip.Trip, tickets []*Ticket, released string) error {
+	if requestedTrip.VehicleID == 0 {
+		return nil
+	}
+
+	vehicle, err := s.vehicleFinder.FindByID(ctx, requestedTrip.VehicleID)
+	if err != nil {
+		return err
+	}
+
+	taken, err := s.ticketRepo.FindTakenSeats(ctx, requestedTrip.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range taken {
+		if taken[i] == released {
+			taken = append(taken[:i], taken[i+1:]...)
+			break
+		}
+	}
+
+	return assignSeats(vehicle.Seats(), taken, tickets)
+}
+
+// assignSeats gives every ticket taking a seat the seat asked for, or the
+// first free seat sold in its fare class when none is asked for. Seats of
+// train wagons are only sold in the fare class of their wagon class.
+func assignSeats(seats []fleet.Seat, taken []string, tickets []*Ticket) error {
+	isTaken := make(map[string]bool, len(taken))
+	for _, number := range taken {
+		isTaken[number] = true
+	}
+
+	byNumber := make(map[string]fleet.Seat, len(seats))
+	for _, seat := range seats {
+		byNumber[seat.Number] = seat
+	}
+
+	for _, ticket := range tickets {
+		if ticket.Seats() == 0 {
+			ticket.Seat = ""
+			continue
+		}
+		if ticket.Seat == "" {
+			continue
+		}
+
+		seat, ok := byNumber[ticket.Seat]
+		if !ok || !isSoldIn(seat, ticket.FareClass) {
+			return ErrInvalidSeat
+		}
+		if isTaken[seat.Number] {
+			return ErrSeatTaken
+		}
+		isTaken[seat.Number] = true
+	}
+
+	for _, ticket := range tickets {
+		if ticket.Seats() == 0 || ticket.Seat != "" {
+			continue
+		}
+
+		for _, seat := range seats {
+			if !isTaken[seat.Number] && isSoldIn(seat, ticket.FareClass) {
+				ticket.Seat = seat.Number
+				isTaken[seat.Number] = true
+				break
+			}
+		}
+		if ticket.Seat == "" {
+			return ErrNoCapacity
+		}
+	}
+
+	return nil
+}
+
+func isSoldIn(seat fleet.Seat, fareClass trip.FareClassName) bool {
+	name, ok := trip.FareClassOf(seat)
+	return !ok || name == fareClass
+}
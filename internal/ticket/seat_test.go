@@ -0,0 +1,106 @@
+package ticket
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"reflect"
+	"testing"
+)
+
+func TestAssignSeats(t *testing.T) {
+	bus := []fleet.Seat{{Number: "1A"}, {Number: "1B"}, {Number: "1C"}}
+	train := []fleet.Seat{
+		{Number: "W1-1A", Class: fleet.FirstClass},
+		{Number: "W2-1A", Class: fleet.SecondClass},
+		{Number: "W2-1B", Class: fleet.SecondClass},
+	}
+
+	tests := []struct {
+		name        string
+		seats       []fleet.Seat
+		taken       []string
+		tickets     []Ticket
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:     "first free seats",
+			seats:    bus,
+			taken:    []string{"1A"},
+			tickets:  []Ticket{{FareClass: trip.Economy}, {FareClass: trip.Economy}},
+			expected: []string{"1B", "1C"},
+		},
+		{
+			name:     "asked seat is kept",
+			seats:    bus,
+			tickets:  []Ticket{{FareClass: trip.Economy}, {FareClass: trip.Economy, Seat: "1A"}},
+			expected: []string{"1B", "1A"},
+		},
+		{
+			name:     "infant on lap takes no seat",
+			seats:    bus,
+			tickets:  []Ticket{{FareClass: trip.Economy, OnLap: true, Seat: "1A"}, {FareClass: trip.Economy}},
+			expected: []string{"", "1A"},
+		},
+		{
+			name:        "seat not on the vehicle",
+			seats:       bus,
+			tickets:     []Ticket{{FareClass: trip.Economy, Seat: "9Z"}},
+			expectedErr: ErrInvalidSeat,
+		},
+		{
+			name:        "seat taken",
+			seats:       bus,
+			taken:       []string{"1A"},
+			tickets:     []Ticket{{FareClass: trip.Economy, Seat: "1A"}},
+			expectedErr: ErrSeatTaken,
+		},
+		{
+			name:        "seat asked twice",
+			seats:       bus,
+			tickets:     []Ticket{{FareClass: trip.Economy, Seat: "1A"}, {FareClass: trip.Economy, Seat: "1A"}},
+			expectedErr: ErrSeatTaken,
+		},
+		{
+			name:     "wagon of the fare class",
+			seats:    train,
+			tickets:  []Ticket{{FareClass: trip.Economy}, {FareClass: trip.First}},
+			expected: []string{"W2-1A", "W1-1A"},
+		},
+		{
+			name:        "seat of another wagon class",
+			seats:       train,
+			tickets:     []Ticket{{FareClass: trip.Economy, Seat: "W1-1A"}},
+			expectedErr: ErrInvalidSeat,
+		},
+		{
+			name:        "wagon class is full",
+			seats:       train,
+			tickets:     []Ticket{{FareClass: trip.First}, {FareClass: trip.First}},
+			expectedErr: ErrNoCapacity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tickets := make([]*Ticket, 0, len(tt.tickets))
+			for i := range tt.tickets {
+				tickets = append(tickets, &tt.tickets[i])
+			}
+
+			if err := assignSeats(tt.seats, tt.taken, tickets); err != tt.expectedErr {
+				t.Fatalf("assignSeats() error = %v, want %v", err, tt.expectedErr)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+
+			got := make([]string, 0, len(tickets))
+			for _, ticket := range tickets {
+				got = append(got, ticket.Seat)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("assignSeats() seats = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -5,9 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/dilaragorum/online-ticket-project-go/internal/loyalty"
 	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
 	"github.com/dilaragorum/online-ticket-project-go/internal/payment"
 	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/dilaragorum/online-ticket-project-go/internal/waitlist"
+	"github.com/dilaragorum/online-ticket-project-go/internal/wallet"
+	"github.com/labstack/gommon/log"
+	"math"
+	"time"
 )
 
 const (
@@ -17,61 +23,1027 @@ const (
 )
 
 var (
-	ErrNoCapacity   = errors.New("capacity is full")
-	ErrTripNotFound = errors.New("this trip does not exist")
-
-	ErrExceedAllowedTicketToPurchase = func(limit int) error {
-		return fmt.Errorf("exceed number of tickets allowed to be purchased(%d)", limit)
-	}
+	ErrNoCapacity        = errors.New("capacity is full")
+	ErrTripNotFound      = errors.New("this trip does not exist")
+	ErrInvalidItinerary  = errors.New("trips of the itinerary do not connect")
+	ErrDuplicatedLegTrip = errors.New("the same trip cannot appear twice in an itinerary")
+	ErrDocumentRequired  = errors.New("identity document is required for this trip")
+	ErrNoDeckCapacity    = errors.New("vehicle deck is full")
+	ErrNoVehicleDeck     = errors.New("this trip does not carry vehicles")
+	ErrFareClassNotFound = errors.New("this fare class is not sold on the trip")
+	ErrInvalidClaim      = errors.New("claim does not match the tickets")
+	ErrTicketNotExist    = errors.New("this ticket does not exist")
+	ErrTripDeparted      = errors.New("trip has already departed")
+	ErrTravellerNotFound = errors.New("this traveller does not exist")
+	ErrNotChangeable     = errors.New("ticket of this fare class cannot be exchanged")
+	ErrDifferentRoute    = errors.New("ticket can only be exchanged for a trip on the same route")
+	ErrSameTrip          = errors.New("ticket is already on this trip and fare class")
+	ErrEmailNotVerified  = errors.New("email of the user has not been verified")
 
 	ErrExceedMaleTicketNumber = errors.New("exceed number of male ticket allowed to be purchased")
 )
 
+// LimitError is returned when more tickets are bought at once than the user
+// may buy. Limit includes the bonus of the loyalty tier of the user.
+type LimitError struct {
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("exceed number of tickets allowed to be purchased(%d)", e.Limit)
+}
+
 type Service interface {
-	Purchase(ctx context.Context, tickets []Ticket, claims auth.Claims) error
+	// Purchase redeems the loyalty points of the checkout, pays up to its
+	// wallet amount from the wallet of the user and the rest by card.
+	Purchase(ctx context.Context, tickets []Ticket, checkout Checkout, claims auth.Claims) error
+	// PurchaseClaimed buys seats held for a waitlist offer identified by its claim token.
+	PurchaseClaimed(ctx context.Context, claimToken string, tickets []Ticket, checkout Checkout, claims auth.Claims) error
+	PurchaseItinerary(ctx context.Context, order ItineraryOrder, claims auth.Claims) error
+	// Cancel refunds what was paid from the wallet to the wallet, and the
+	// rest to the wallet of the user when toWallet is set, by card otherwise.
+	Cancel(ctx context.Context, ticketID int, toWallet bool, claims auth.Claims) error
+	// Exchange moves the ticket to another departure on the same route,
+	// charging or refunding the fare difference and the change fee.
+	Exchange(ctx context.Context, ticketID int, exchange Exchange, claims auth.Claims) (*ExchangeReceipt, error)
+	// Amend corrects the passenger details of an issued ticket and re-issues it.
+	Amend(ctx context.Context, ticketID int, amendment Amendment, claims auth.Claims) (*Ticket, error)
+	GetVersions(ctx context.Context, ticketID int, claims auth.Claims) ([]TicketVersion, error)
+	// List returns the tickets of the user, or of every user for admins.
+	List(ctx context.Context, filter ListFilter, claims auth.Claims) ([]Ticket, error)
+	CheckIn(ctx context.Context, ticketID int, claims auth.Claims) (*Ticket, error)
+	Board(ctx context.Context, ticketID int, claims auth.Claims) (*Ticket, error)
+	GetTransitions(ctx context.Context, ticketID int, claims auth.Claims) ([]TicketTransition, error)
+	// ProcessNoShows marks the tickets not checked in for departed trips as
+	// no-show and applies the no-show policy of their fare class. Credits
+	// which could not be booked are retried on the next run.
+	ProcessNoShows(ctx context.Context) error
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// TravellerFinder resolves the saved travellers of a user into passengers.
+type TravellerFinder interface {
+	FindPassenger(ctx context.Context, userID uint, travellerID uint) (*Passenger, error)
+}
+
+// EmailVerifier tells whether a user has verified their email address.
+type EmailVerifier interface {
+	IsEmailVerified(ctx context.Context, userID uint) (bool, error)
 }
 
 type defaultService struct {
 	ticketRepo          Repository
 	notificationService notification.Service
 	tripRepo            trip.Repository
+	vehicleFinder       VehicleFinder
 	payment             payment.Client
+	waitlistService     waitlist.Service
+	walletService       wallet.Service
+	loyaltyService      loyalty.Service
+	travellerFinder     TravellerFinder
+	emailVerifier       EmailVerifier
+}
+
+// NewService takes a nil emailVerifier when users may purchase before
+// verifying their email address.
+func NewService(ticketRepo Repository, notificationService notification.Service, tripRepo trip.Repository, vehicleFinder VehicleFinder, payment payment.Client, waitlistService waitlist.Service, walletService wallet.Service, loyaltyService loyalty.Service, travellerFinder TravellerFinder, emailVerifier EmailVerifier) Service {
+	return &defaultService{
+		ticketRepo:          ticketRepo,
+		notificationService: notificationService,
+		tripRepo:            tripRepo,
+		vehicleFinder:       vehicleFinder,
+		payment:             payment,
+		waitlistService:     waitlistService,
+		walletService:       walletService,
+		loyaltyService:      loyaltyService,
+		travellerFinder:     travellerFinder,
+		emailVerifier:       emailVerifier,
+	}
 }
 
-func NewService(ticketRepo Repository, notificationService notification.Service, tripRepo trip.Repository, payment payment.Client) Service {
-	return &defaultService{ticketRepo: ticketRepo, notificationService: notificationService, tripRepo: tripRepo, payment: payment}
+func (s *defaultService) Purchase(ctx context.Context, tickets []Ticket, checkout Checkout, claims auth.Claims) error {
+	return s.purchase(ctx, tickets, checkout, claims, nil)
 }
 
-func (s *defaultService) Purchase(ctx context.Context, tickets []Ticket, claims auth.Claims) error {
-	if err := checkCorporatedLimit(claims, tickets); err != nil {
+func (s *defaultService) PurchaseClaimed(ctx context.Context, claimToken string, tickets []Ticket, checkout Checkout, claims auth.Claims) error {
+	if len(tickets) == 0 {
+		return ErrInvalidClaim
+	}
+
+	hold, err := s.waitlistService.Claim(ctx, claimToken, tickets[0].TripID, claims.UserID)
+	if err != nil {
 		return err
 	}
 
-	if err := checkIndividualLimit(claims, tickets); err != nil {
+	if len(tickets) > hold.Seats {
+		return ErrInvalidClaim
+	}
+
+	for i := range tickets {
+		if tickets[i].TripID != hold.TripID {
+			return ErrInvalidClaim
+		}
+		tickets[i].FareClass = hold.FareClass
+	}
+
+	if err = s.purchase(ctx, tickets, checkout, claims, hold); err != nil {
 		return err
 	}
 
-	if err := checkMaleTicketLimit(tickets, claims); err != nil {
+	// The tickets are bought already, and an offer left open expires by itself.
+	if err = s.waitlistService.Complete(ctx, hold); err != nil {
+		log.Error(err)
+	}
+
+	return nil
+}
+
+func (s *defaultService) purchase(ctx context.Context, tickets []Ticket, checkout Checkout, claims auth.Claims, hold *waitlist.Entry) error {
+	if err := s.checkEmailVerified(ctx, claims); err != nil {
 		return err
 	}
 
-	params := make([]notification.Param, 0, len(tickets))
-	passengersNames := make([]string, 0, len(tickets))
+	passengers := make([]Passenger, 0, len(tickets))
+	for i := range tickets {
+		if tickets[i].HasTraveller() {
+			passenger, err := s.travellerFinder.FindPassenger(ctx, claims.UserID, tickets[i].TravellerID)
+			if err != nil {
+				return err
+			}
+			tickets[i].Passenger = *passenger
+		}
+		passengers = append(passengers, tickets[i].Passenger)
+	}
 
+	perks, err := s.loyaltyService.Perks(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkPurchaseLimits(claims, passengers, perks.PurchaseLimitBonus); err != nil {
+		return err
+	}
+
+	trips := make(map[int]*trip.Trip)
+	for i := range tickets {
+		if _, ok := trips[tickets[i].TripID]; ok {
+			continue
+		}
+
+		requestedTrip, err := s.findTrip(ctx, tickets[i].TripID)
+		if err != nil {
+			return err
+		}
+		trips[requestedTrip.ID] = requestedTrip
+	}
+
+	order := Order{UserID: claims.UserID, WalletAmount: checkout.WalletAmount, Points: checkout.Points}
 	for i := range tickets {
-		ticket := tickets[i]
+		ticket := newTicket(trips[tickets[i].TripID], claims, tickets[i].Passenger)
+		ticket.TravellerID = tickets[i].TravellerID
+		ticket.FareClass = tickets[i].FareClass
+		ticket.Seat = tickets[i].Seat
+		ticket.VehiclePlate = tickets[i].VehiclePlate
+		order.Tickets = append(order.Tickets, ticket)
+	}
 
-		passengersNames = append(passengersNames, ticket.FullName)
+	return s.placeOrder(ctx, &order, trips, passengers, claims, hold)
+}
 
-		requestedTrip, err := s.tripRepo.FindByTripID(ctx, ticket.TripID)
+func (s *defaultService) PurchaseItinerary(ctx context.Context, itineraryOrder ItineraryOrder, claims auth.Claims) error {
+	if err := s.checkEmailVerified(ctx, claims); err != nil {
+		return err
+	}
+
+	passengers, travellerIDs, err := s.findTravellers(ctx, claims.UserID, itineraryOrder.Passengers, itineraryOrder.TravellerIDs)
+	if err != nil {
+		return err
+	}
+	itineraryOrder.Passengers = passengers
+
+	perks, err := s.loyaltyService.Perks(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkPurchaseLimits(claims, itineraryOrder.Passengers, perks.PurchaseLimitBonus); err != nil {
+		return err
+	}
+
+	trips := make(map[int]*trip.Trip)
+
+	outbound, err := s.findJourney(ctx, itineraryOrder.OutboundTripIDs, trips)
+	if err != nil {
+		return err
+	}
+
+	var ret *trip.Journey
+	if len(itineraryOrder.ReturnTripIDs) > 0 {
+		journey, err := s.findJourney(ctx, itineraryOrder.ReturnTripIDs, trips)
 		if err != nil {
-			if errors.Is(err, trip.ErrTripNotFound) {
-				return ErrTripNotFound
-			}
 			return err
 		}
+		ret = &journey
+	}
+
+	if _, err = trip.NewItinerary(outbound, ret); err != nil {
+		return ErrInvalidItinerary
+	}
+
+	tripIDs := append(append([]int{}, itineraryOrder.OutboundTripIDs...), itineraryOrder.ReturnTripIDs...)
+
+	order := Order{UserID: claims.UserID, WalletAmount: itineraryOrder.WalletAmount, Points: itineraryOrder.Points}
+	for i := range itineraryOrder.Passengers {
+		for k := range tripIDs {
+			ticket := newTicket(trips[tripIDs[k]], claims, itineraryOrder.Passengers[i])
+			ticket.TravellerID = travellerIDs[i]
+			ticket.FareClass = itineraryOrder.FareClass
+			order.Tickets = append(order.Tickets, ticket)
+		}
+	}
+
+	return s.placeOrder(ctx, &order, trips, itineraryOrder.Passengers, claims, nil)
+}
+
+// Cancel gives the seat of an upcoming trip back, refunds the ticket by the
+// rules of its fare class and offers the seat to the waitlist.
+func (s *defaultService) Cancel(ctx context.Context, ticketID int, toWallet bool, claims auth.Claims) error {
+	ticket, err := s.findOwnTicket(ctx, ticketID, claims, auth.TicketRefund)
+	if err != nil {
+		return err
+	}
+
+	if !ticket.Status.CanTransitionTo(Cancelled) {
+		return ErrInvalidTransition
+	}
+
+	requestedTrip, err := s.findTrip(ctx, ticket.TripID)
+	if err != nil {
+		return err
+	}
+
+	if requestedTrip.IsInPast(time.Now()) {
+		return ErrTripDeparted
+	}
+
+	// Held and pending tickets have not been charged, so there is nothing to give back.
+	paid := ticket.Status.IsPaid()
+
+	order, err := s.findOrder(ctx, ticket)
+	if err != nil {
+		return err
+	}
+
+	if ticket, err = s.moveTo(ctx, ticket, Cancelled, claims); err != nil {
+		return err
+	}
+
+	refund := 0.0
+	if paid {
+		s.reversePoints(ctx, ticket)
+
+		refund = ticket.Price
+		if fareClass, err := requestedTrip.FareClass(ticket.FareClass); err == nil {
+			refund = fareClass.RefundAmount(ticket.Price)
+		}
+	}
+
+	var shares RefundShares
+	if refund > 0 {
+		shares = order.RefundShares(refund)
+		if toWallet {
+			shares.Wallet += shares.Card
+			shares.Card = 0
+		}
+
+		// The ticket is cancelled already, so a refund which cannot be made by
+		// card is credited to the wallet, and what fails to be given back is
+		// retried by retryRefunds instead of being lost.
+		if shares.Card > 0 {
+			if err = s.payment.Refund(shares.Card); err != nil {
+				log.Error(err)
+				shares.Wallet += shares.Card
+				shares.Card = 0
+			}
+		}
+		s.settleRefund(ctx, ticket, shares.Wallet, shares.Points, claims)
+	}
+
+	param := notification.Param{
+		Channel:     notification.Email,
+		To:          ticket.Email,
+		From:        "ticket@company.com",
+		Title:       "Ticket Cancelled",
+		Description: fmt.Sprintf("Dear %s, your ticket for %s-%s on %s has been cancelled. Refund: %.2f, points: %d", ticket.FullName, requestedTrip.From, requestedTrip.To, requestedTrip.DepartureAt, shares.Card+shares.Wallet, shares.Points),
+		LogMsg:      fmt.Sprintf("Ticket %d has been cancelled by %s who has %d id", ticket.ID, claims.Username, claims.UserID),
+	}
+
+	if err = s.notificationService.Send(ctx, param); err != nil {
+		log.Error(err)
+	}
+
+	if err = s.waitlistService.SeatsReleased(ctx, ticket.TripID); err != nil {
+		log.Error(err)
+	}
+
+	return nil
+}
+
+func (s *defaultService) Exchange(ctx context.Context, ticketID int, exchange Exchange, claims auth.Claims) (*ExchangeReceipt, error) {
+	ticket, err := s.findOwnTicket(ctx, ticketID, claims, auth.TicketManageAny)
+	if err != nil {
+		return nil, err
+	}
+
+	currentTrip, err := s.findTrip(ctx, ticket.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if currentTrip.IsInPast(now) {
+		return nil, ErrTripDeparted
+	}
+
+	currentFareClass, err := currentTrip.FareClass(ticket.FareClass)
+	if err != nil {
+		return nil, ErrFareClassNotFound
+	}
+	if !currentFareClass.Changeable {
+		return nil, ErrNotChangeable
+	}
+	if !ticket.Status.CanTransitionTo(Exchanged) {
+		return nil, ErrInvalidTransition
+	}
+
+	targetTrip, err := s.findTrip(ctx, exchange.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetTrip.From != currentTrip.From || targetTrip.To != currentTrip.To {
+		return nil, ErrDifferentRoute
+	}
+	if targetTrip.IsInPast(now) {
+		return nil, ErrTripDeparted
+	}
+
+	if exchange.FareClass == "" {
+		exchange.FareClass = ticket.FareClass
+	}
+	targetFareClass, err := targetTrip.FareClass(exchange.FareClass)
+	if err != nil {
+		return nil, ErrFareClassNotFound
+	}
+
+	if targetTrip.ID == currentTrip.ID && targetFareClass.Name == currentFareClass.Name {
+		return nil, ErrSameTrip
+	}
+
+	exchanged := *ticket
+	exchanged.ID = 0
+	exchanged.TripID = targetTrip.ID
+	exchanged.FareClass = targetFareClass.Name
+	exchanged.Seat = exchange.Seat
+	exchanged.Status = Issued
+	exchanged.ExchangedFrom = ticket.ID
+	exchanged.CreatedAt = time.Time{}
+	exchanged.UpdatedAt = time.Time{}
+
+	if exchanged.Category, err = exchanged.ResolveCategory(targetTrip.Date); err != nil {
+		return nil, err
+	}
+	exchanged.OnLap = exchanged.IsOnLap()
+	exchanged.Price = targetFareClass.Price * exchanged.FareRate()
+
+	if err = exchanged.CheckDocument(targetTrip.DocumentRequirement()); err != nil {
+		return nil, err
+	}
+	if exchanged.VehiclePlate != "" && targetTrip.DeckCapacity == 0 {
+		return nil, ErrNoVehicleDeck
+	}
+
+	held, err := s.heldSeats(ctx, targetTrip.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Moving within the same trip gives the old seat back before the new one is taken.
+	tripSeats := exchanged.Seats()
+	if targetTrip.ID == currentTrip.ID && ticket.Seats() <= tripSeats {
+		tripSeats -= ticket.Seats()
+	}
+	if !targetTrip.CheckAvailableSeat(tripSeats+held[targetFareClass.Name]) || !targetFareClass.CheckAvailableSeat(exchanged.Seats()+held[targetFareClass.Name]) {
+		return nil, ErrNoCapacity
+	}
+
+	released := ""
+	if targetTrip.ID == currentTrip.ID {
+		released = ticket.Seat
+	}
+	if err = s.assignSeats(ctx, targetTrip, []*Ticket{&exchanged}, released); err != nil {
+		return nil, err
+	}
+
+	perks, err := s.loyaltyService.Perks(ctx, ticket.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := exchangeReceipt(ticket.Price, exchanged.Price, currentFareClass.ChangeFee, perks.FreeChanges)
+
+	// What is owed is charged before the tickets are swapped and given back
+	// when they cannot be, so the old ticket is kept unless the new one is paid.
+	if receipt.Amount > 0 {
+		if err = s.payment.Transfer(receipt.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.ticketRepo.Exchange(ctx, ticket, &exchanged, claims.UserID); err != nil {
+		if receipt.Amount > 0 {
+			if refundErr := s.payment.Refund(receipt.Amount); refundErr != nil {
+				log.Error(refundErr)
+			}
+		}
+		return nil, err
+	}
+	receipt.Ticket = exchanged
+
+	if receipt.Amount < 0 {
+		s.refundExchange(ctx, ticket, &receipt.Ticket, -receipt.Amount)
+	}
+
+	s.reversePoints(ctx, ticket)
+	if order, err := s.findOrder(ctx, &receipt.Ticket); err != nil {
+		s.pointsFailed(ctx, &receipt.Ticket, err)
+	} else {
+		s.accruePoints(ctx, &receipt.Ticket, targetTrip, order)
+	}
+
+	param := notification.Param{
+		Channel:     notification.Email,
+		To:          ticket.Email,
+		From:        "ticket@company.com",
+		Title:       "Ticket Exchanged",
+		Description: fmt.Sprintf("Dear %s, your ticket for %s-%s has been moved from %s to %s, fare class %s. Amount: %.2f", ticket.FullName, targetTrip.From, targetTrip.To, currentTrip.DepartureAt, targetTrip.DepartureAt, exchanged.FareClass, receipt.Amount),
+		LogMsg:      fmt.Sprintf("Ticket %d has been exchanged from trip %d to trip %d by %s who has %d id", ticket.ID, currentTrip.ID, targetTrip.ID, claims.Username, claims.UserID),
+	}
 
+	if err = s.notificationService.Send(ctx, param); err != nil {
+		log.Error(err)
+	}
+
+	if err = s.waitlistService.SeatsReleased(ctx, currentTrip.ID); err != nil {
+		log.Error(err)
+	}
+
+	return &receipt, nil
+}
+
+// refundExchange gives back what is owed for an exchange by how the order of
+// the old ticket was paid, as Cancel does. The tickets are swapped already, so
+// a refund which cannot be made by card is credited to the wallet instead of
+// being lost, and other failures are only logged.
+func (s *defaultService) refundExchange(ctx context.Context, ticket *Ticket, exchanged *Ticket, amount float64) {
+	shares := RefundShares{Card: amount}
+	if order, err := s.findOrder(ctx, ticket); err != nil {
+		log.Error(err)
+	} else {
+		shares = order.RefundShares(amount)
+	}
+
+	if shares.Card > 0 {
+		if err := s.payment.Refund(shares.Card); err != nil {
+			log.Error(err)
+			shares.Wallet += shares.Card
+		}
+	}
+	if shares.Wallet > 0 {
+		if err := s.walletService.Credit(ctx, exchanged.UserID, shares.Wallet, wallet.Refund, ticketReference(exchanged.ID)); err != nil {
+			log.Error(err)
+		}
+	}
+	// The points are restored on the old ticket, so a later cancellation of
+	// the new one can restore its own.
+	if shares.Points > 0 {
+		if err := s.loyaltyService.RestoreTicket(ctx, ticket.UserID, ticket.ID, shares.Points); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func (s *defaultService) Amend(ctx context.Context, ticketID int, amendment Amendment, claims auth.Claims) (*Ticket, error) {
+	ticket, err := s.findOwnTicket(ctx, ticketID, claims, auth.TicketManageAny)
+	if err != nil {
+		return nil, err
+	}
+
+	requestedTrip, err := s.findTrip(ctx, ticket.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if requestedTrip.IsInPast(time.Now()) {
+		return nil, ErrTripDeparted
+	}
+
+	if !ticket.Status.IsAmendable() {
+		return nil, ErrNotAmendable
+	}
+
+	if !ticket.CanBeAmended() {
+		return nil, ErrExceedAmendments
+	}
+
+	versions, err := s.ticketRepo.FindVersions(ctx, ticket.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	amended, err := amendment.Apply(ticket.Passenger, ticket.IssuedName(versions))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.ticketRepo.Amend(ctx, ticket, amended, claims.UserID); err != nil {
+		return nil, err
+	}
+
+	previousEmail := ticket.Email
+	ticket.Passenger = amended
+	ticket.Version++
+
+	params := []notification.Param{
+		{
+			Channel: notification.SMS,
+			To:      ticket.Phone,
+			From:    "X Ticket Company",
+			Title:   "Ticket Re-issued",
+			Description: fmt.Sprintf(`Your ticket has been re-issued with the corrected passenger details:
+Passenger: %s
+FromTo: %s-%s
+Date: %s
+Fare Class: %s`, ticket.FullName, requestedTrip.From, requestedTrip.To, requestedTrip.DepartureAt, ticket.FareClass),
+			LogMsg: fmt.Sprintf("Ticket %d has been re-issued as version %d by %s who has %d id", ticket.ID, ticket.Version, claims.Username, claims.UserID),
+		},
+		{
+			Channel:     notification.Email,
+			To:          ticket.Email,
+			From:        "ticket@company.com",
+			Title:       "Ticket Re-issued",
+			Description: fmt.Sprintf("Dear %s, your ticket for %s-%s on %s has been re-issued with the corrected passenger details.", ticket.FullName, requestedTrip.From, requestedTrip.To, requestedTrip.DepartureAt),
+			LogMsg:      fmt.Sprintf("Re-issued ticket %d has been e-mailed", ticket.ID),
+		},
+	}
+
+	if previousEmail != ticket.Email {
 		params = append(params, notification.Param{
+			Channel:     notification.Email,
+			To:          previousEmail,
+			From:        "ticket@company.com",
+			Title:       "Ticket Details Changed",
+			Description: fmt.Sprintf("The e-mail address of your ticket for %s-%s on %s has been changed.", requestedTrip.From, requestedTrip.To, requestedTrip.DepartureAt),
+			LogMsg:      fmt.Sprintf("Previous e-mail address of ticket %d has been informed about the amendment", ticket.ID),
+		})
+	}
+
+	// The ticket is re-issued already, so a failed notification does not fail the amendment.
+	for i := range params {
+		if err = s.notificationService.Send(ctx, params[i]); err != nil {
+			log.Error(err)
+		}
+	}
+
+	return ticket, nil
+}
+
+func (s *defaultService) GetVersions(ctx context.Context, ticketID int, claims auth.Claims) ([]TicketVersion, error) {
+	if _, err := s.findOwnTicket(ctx, ticketID, claims, auth.TicketReadAny); err != nil {
+		return nil, err
+	}
+
+	return s.ticketRepo.FindVersions(ctx, ticketID)
+}
+
+func (s *defaultService) List(ctx context.Context, filter ListFilter, claims auth.Claims) ([]Ticket, error) {
+	if !claims.Can(auth.TicketReadAny) {
+		filter.UserID = claims.UserID
+	}
+
+	return s.ticketRepo.List(ctx, filter)
+}
+
+// CheckIn is open to the passenger within CheckInWindow before the departure.
+func (s *defaultService) CheckIn(ctx context.Context, ticketID int, claims auth.Claims) (*Ticket, error) {
+	ticket, err := s.findOwnTicket(ctx, ticketID, claims, auth.TicketManageAny)
+	if err != nil {
+		return nil, err
+	}
+
+	requestedTrip, err := s.findTrip(ctx, ticket.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ticket.Status.CanTransitionTo(CheckedIn) {
+		return nil, ErrInvalidTransition
+	}
+
+	if !IsCheckInOpen(requestedTrip.Date, time.Now()) {
+		return nil, ErrCheckInNotOpen
+	}
+
+	return s.moveTo(ctx, ticket, CheckedIn, claims)
+}
+
+func (s *defaultService) Board(ctx context.Context, ticketID int, claims auth.Claims) (*Ticket, error) {
+	ticket, err := s.findOwnTicket(ctx, ticketID, claims, auth.TicketBoard)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.moveTo(ctx, ticket, Boarded, claims)
+}
+
+func (s *defaultService) GetTransitions(ctx context.Context, ticketID int, claims auth.Claims) ([]TicketTransition, error) {
+	if _, err := s.findOwnTicket(ctx, ticketID, claims, auth.TicketReadAny); err != nil {
+		return nil, err
+	}
+
+	return s.ticketRepo.FindTransitions(ctx, ticketID)
+}
+
+func (s *defaultService) ProcessNoShows(ctx context.Context) error {
+	tickets, err := s.ticketRepo.FindNoShows(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	trips := make(map[int]*trip.Trip)
+	for i := range tickets {
+		ticket := &tickets[i]
+
+		departedTrip, ok := trips[ticket.TripID]
+		if !ok {
+			if departedTrip, err = s.findTrip(ctx, ticket.TripID); err != nil {
+				log.Errorf("no-shows of trip %d could not be processed: %v", ticket.TripID, err)
+			}
+			trips[ticket.TripID] = departedTrip
+		}
+		if departedTrip == nil {
+			continue
+		}
+
+		var credit float64
+		if fareClass, err := departedTrip.FareClass(ticket.FareClass); err == nil {
+			credit = fareClass.NoShowCredit(ticket.Price)
+		}
+
+		if err = s.ticketRepo.MarkNoShow(ctx, ticket, credit); err != nil {
+			continue
+		}
+
+		param := notification.Param{
+			Channel:     notification.Email,
+			To:          ticket.Email,
+			From:        "ticket@company.com",
+			Title:       "Missed Trip",
+			Description: fmt.Sprintf("Dear %s, you did not check in for %s-%s on %s, so your ticket has been marked as no-show. Credit: %.2f", ticket.FullName, departedTrip.From, departedTrip.To, departedTrip.DepartureAt, credit),
+			LogMsg:      fmt.Sprintf("Ticket %d has been marked as no-show with %.2f credit", ticket.ID, credit),
+		}
+
+		if err = s.notificationService.Send(ctx, param); err != nil {
+			log.Error(err)
+		}
+	}
+
+	return s.creditNoShows(ctx)
+}
+
+// creditNoShows books the credits of no-show tickets on the wallets of their
+// users. A credit which fails is booked again on the next run.
+func (s *defaultService) creditNoShows(ctx context.Context) error {
+	tickets, err := s.ticketRepo.FindUncreditedNoShows(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ticket := range tickets {
+		if err = s.walletService.Credit(ctx, ticket.UserID, ticket.NoShowCredit, wallet.NoShowCredit, ticketReference(ticket.ID)); err != nil {
+			log.Errorf("no-show credit of ticket %d could not be booked: %v", ticket.ID, err)
+			continue
+		}
+
+		if err = s.ticketRepo.MarkNoShowCredited(ctx, ticket.ID); err != nil {
+			log.Error(err)
+		}
+	}
+
+	return nil
+}
+
+// settleRefund gives back what is owed to the wallet and the loyalty account
+// for the cancelled ticket and moves it to refunded. What fails is left to be
+// retried with the part which has been given back already taken off.
+func (s *defaultService) settleRefund(ctx context.Context, ticket *Ticket, walletAmount float64, points int, claims auth.Claims) {
+	if walletAmount > 0 {
+		if err := s.walletService.Credit(ctx, ticket.UserID, walletAmount, wallet.Refund, ticketReference(ticket.ID)); err != nil {
+			s.refundFailed(ctx, ticket, walletAmount, points, err)
+			return
+		}
+	}
+
+	if points > 0 {
+		if err := s.loyaltyService.RestoreTicket(ctx, ticket.UserID, ticket.ID, points); err != nil {
+			s.refundFailed(ctx, ticket, 0, points, err)
+			return
+		}
+	}
+
+	if ticket.Status != Refunded {
+		if _, err := s.moveTo(ctx, ticket, Refunded, claims); err != nil {
+			s.refundFailed(ctx, ticket, 0, 0, err)
+			return
+		}
+	}
+
+	if ticket.RefundPending {
+		if err := s.ticketRepo.SetRefundPending(ctx, ticket.ID, false, 0, 0); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// refundFailed leaves the rest of the refund of the ticket to be retried by retryRefunds.
+func (s *defaultService) refundFailed(ctx context.Context, ticket *Ticket, walletAmount float64, points int, err error) {
+	log.Errorf("refund of ticket %d could not be made: %v", ticket.ID, err)
+
+	if err = s.ticketRepo.SetRefundPending(ctx, ticket.ID, true, walletAmount, points); err != nil {
+		log.Error(err)
+	}
+}
+
+// retryRefunds gives back what failed to be given back for cancelled tickets.
+func (s *defaultService) retryRefunds(ctx context.Context) error {
+	tickets, err := s.ticketRepo.FindRefundsPending(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		s.settleRefund(ctx, &tickets[i], tickets[i].PendingWallet, tickets[i].PendingPoints, auth.Claims{})
+	}
+
+	return nil
+}
+
+// Run processes no-shows of departed trips and retries failed loyalty
+// points and refunds until ctx is done.
+func (s *defaultService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ProcessNoShows(ctx); err != nil {
+				log.Error(err)
+			}
+			if err := s.retryPoints(ctx); err != nil {
+				log.Error(err)
+			}
+			if err := s.retryRefunds(ctx); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+func (s *defaultService) moveTo(ctx context.Context, ticket *Ticket, to Status, claims auth.Claims) (*Ticket, error) {
+	tickets := []Ticket{*ticket}
+	if err := s.ticketRepo.UpdateStatus(ctx, tickets, to, claims.UserID); err != nil {
+		return nil, err
+	}
+
+	return &tickets[0], nil
+}
+
+// findOwnTicket returns the ticket when it belongs to the user, or the roles
+// of the user permit anyTicket on the tickets of everyone.
+func (s *defaultService) findOwnTicket(ctx context.Context, ticketID int, claims auth.Claims, anyTicket auth.Permission) (*Ticket, error) {
+	ticket, err := s.ticketRepo.FindByID(ctx, ticketID)
+	if err != nil {
+		if errors.Is(err, ErrTicketNotFound) {
+			return nil, ErrTicketNotExist
+		}
+		return nil, err
+	}
+
+	if ticket.UserID != claims.UserID && !claims.Can(anyTicket) {
+		return nil, ErrTicketNotExist
+	}
+
+	return ticket, nil
+}
+
+// findTravellers adds the saved travellers of the user to the passengers.
+// The returned traveller IDs are in the order of the passengers, zero for
+// the passengers given inline.
+func (s *defaultService) findTravellers(ctx context.Context, userID uint, passengers []Passenger, travellerIDs []uint) ([]Passenger, []uint, error) {
+	all := make([]Passenger, 0, len(passengers)+len(travellerIDs))
+	ids := make([]uint, 0, len(passengers)+len(travellerIDs))
+
+	for i := range passengers {
+		all = append(all, passengers[i])
+		ids = append(ids, 0)
+	}
+
+	for _, travellerID := range travellerIDs {
+		passenger, err := s.travellerFinder.FindPassenger(ctx, userID, travellerID)
+		if err != nil {
+			return nil, nil, err
+		}
+		all = append(all, *passenger)
+		ids = append(ids, travellerID)
+	}
+
+	return all, ids, nil
+}
+
+func (s *defaultService) findTrip(ctx context.Context, tripID int) (*trip.Trip, error) {
+	requestedTrip, err := s.tripRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		if errors.Is(err, trip.ErrTripNotFound) {
+			return nil, ErrTripNotFound
+		}
+		return nil, err
+	}
+
+	return requestedTrip, nil
+}
+
+func (s *defaultService) findJourney(ctx context.Context, tripIDs []int, trips map[int]*trip.Trip) (trip.Journey, error) {
+	legs := make([]trip.Trip, 0, len(tripIDs))
+
+	for _, tripID := range tripIDs {
+		if _, ok := trips[tripID]; ok {
+			return trip.Journey{}, ErrDuplicatedLegTrip
+		}
+
+		requestedTrip, err := s.findTrip(ctx, tripID)
+		if err != nil {
+			return trip.Journey{}, err
+		}
+		trips[tripID] = requestedTrip
+		legs = append(legs, *requestedTrip)
+	}
+
+	journey, err := trip.NewJourney(legs, trip.DefaultMinLayover)
+	if err != nil {
+		return trip.Journey{}, ErrInvalidItinerary
+	}
+
+	return journey, nil
+}
+
+// placeOrder reserves the seats, charges the order total and informs every
+// passenger about the trip their ticket belongs to.
+func (s *defaultService) placeOrder(ctx context.Context, order *Order, trips map[int]*trip.Trip, passengers []Passenger, claims auth.Claims, hold *waitlist.Entry) error {
+	reservations := make(map[int]Reservation)
+	tripPassengers := make(map[int][]Passenger)
+	for i := range order.Tickets {
+		ticket := &order.Tickets[i]
+		requestedTrip := trips[ticket.TripID]
+
+		fareClass, err := requestedTrip.FareClass(ticket.FareClass)
+		if err != nil {
+			return ErrFareClassNotFound
+		}
+
+		if ticket.Category, err = ticket.ResolveCategory(requestedTrip.Date); err != nil {
+			return err
+		}
+		ticket.OnLap = ticket.IsOnLap()
+		ticket.FareClass = fareClass.Name
+		ticket.Price = fareClass.Price * ticket.FareRate()
+
+		if err = ticket.CheckDocument(requestedTrip.DocumentRequirement()); err != nil {
+			return err
+		}
+
+		tripPassengers[ticket.TripID] = append(tripPassengers[ticket.TripID], ticket.Passenger)
+
+		reservation := reservations[ticket.TripID]
+		if reservation.FareClasses == nil {
+			reservation.FareClasses = make(map[trip.FareClassName]int)
+		}
+		reservation.Seats += ticket.Seats()
+		reservation.FareClasses[fareClass.Name] += ticket.Seats()
+		if ticket.VehiclePlate != "" {
+			if requestedTrip.DeckCapacity == 0 {
+				return ErrNoVehicleDeck
+			}
+			reservation.DeckSpaces++
+		}
+		if hold != nil && hold.TripID == ticket.TripID {
+			reservation.Claim = hold.ID
+		}
+		reservations[ticket.TripID] = reservation
+
+		order.TotalPrice += ticket.Price
+	}
+
+	for tripID := range tripPassengers {
+		if err := checkInfantLimit(tripPassengers[tripID]); err != nil {
+			return err
+		}
+	}
+
+	tripTickets := make(map[int][]*Ticket)
+	for i := range order.Tickets {
+		tripTickets[order.Tickets[i].TripID] = append(tripTickets[order.Tickets[i].TripID], &order.Tickets[i])
+	}
+	for tripID, tickets := range tripTickets {
+		if err := s.assignSeats(ctx, trips[tripID], tickets, ""); err != nil {
+			return err
+		}
+	}
+
+	for tripID, reservation := range reservations {
+		held, err := s.heldSeats(ctx, tripID, hold)
+		if err != nil {
+			return err
+		}
+
+		heldSeats := 0
+		for _, seats := range held {
+			heldSeats += seats
+		}
+
+		if ok := trips[tripID].CheckAvailableSeat(reservation.Seats + heldSeats); !ok {
+			return ErrNoCapacity
+		}
+		for name, seats := range reservation.FareClasses {
+			if fareClass, _ := trips[tripID].FareClass(name); !fareClass.CheckAvailableSeat(seats + held[name]) {
+				return ErrNoCapacity
+			}
+		}
+		if ok := trips[tripID].CheckAvailableDeck(reservation.DeckSpaces); !ok {
+			return ErrNoDeckCapacity
+		}
+	}
+
+	// Points are redeemed up to the total, and the wallet pays up to what is left.
+	order.Points = int(math.Min(float64(order.Points), math.Floor(order.TotalPrice/loyalty.PointValue)))
+	if order.Points > 0 {
+		account, err := s.loyaltyService.GetAccount(ctx, claims.UserID)
+		if err != nil {
+			return err
+		}
+		if account.Points < order.Points {
+			return loyalty.ErrInsufficientPoints
+		}
+	}
+
+	order.WalletAmount = math.Min(order.WalletAmount, order.TotalPrice-loyalty.Discount(order.Points))
+	if order.WalletAmount > 0 {
+		balance, err := s.walletService.Balance(ctx, claims.UserID)
+		if err != nil {
+			return err
+		}
+		if balance.Balance < order.WalletAmount {
+			return wallet.ErrInsufficientBalance
+		}
+	}
+
+	if err := s.ticketRepo.CreateOrder(ctx, order, reservations); err != nil {
+		return err
+	}
+
+	// The seats are taken already, so they are given back when the order is not paid.
+	if err := s.pay(ctx, order, claims); err != nil {
+		if cancelErr := s.ticketRepo.UpdateStatus(ctx, order.Tickets, Cancelled, claims.UserID); cancelErr != nil {
+			log.Error(cancelErr)
+		}
+		return err
+	}
+
+	for i := range order.Tickets {
+		s.accruePoints(ctx, &order.Tickets[i], trips[order.Tickets[i].TripID], order)
+	}
+
+	passengersNames := ""
+	for i := range passengers {
+		passengersNames += fmt.Sprintf("%s\n", passengers[i].FullName)
+	}
+
+	for i := range order.Tickets {
+		ticket := order.Tickets[i]
+		requestedTrip := trips[ticket.TripID]
+
+		param := notification.Param{
 			Channel: notification.SMS,
 			To:      ticket.Phone,
 			From:    "X Ticket Company",
@@ -80,72 +1052,268 @@ func (s *defaultService) Purchase(ctx context.Context, tickets []Ticket, claims
 FromTo: %s-%s
 Date: %s
 Vehicle: %s
-Passengers:`, requestedTrip.From, requestedTrip.To, requestedTrip.Date, requestedTrip.Vehicle),
+Fare Class: %s
+Passengers:
+%s`, requestedTrip.From, requestedTrip.To, requestedTrip.DepartureAt, requestedTrip.Vehicle, ticket.FareClass, passengersNames),
 			LogMsg: fmt.Sprintf("The %s who has %d id purchase ticket/s", claims.Username, claims.UserID),
-		})
+		}
 
-		purchasedTicket := Ticket{
-			TripID: requestedTrip.ID,
-			UserID: claims.UserID,
-			Passenger: Passenger{
-				Gender:   ticket.Gender,
-				FullName: ticket.FullName,
-				Email:    ticket.Email,
-				Phone:    ticket.Phone,
-			},
+		// The tickets are paid and issued already, so a failed SMS does not fail the purchase.
+		if err := s.notificationService.Send(ctx, param); err != nil {
+			log.Error(err)
 		}
+	}
 
-		if ok := requestedTrip.CheckAvailableSeat(len(tickets)); !ok {
-			return ErrNoCapacity
+	return nil
+}
+
+// pay charges the order and issues its tickets. The charge is given back
+// when the tickets cannot be issued.
+func (s *defaultService) pay(ctx context.Context, order *Order, claims auth.Claims) error {
+	if err := s.ticketRepo.UpdateStatus(ctx, order.Tickets, PendingPayment, claims.UserID); err != nil {
+		return err
+	}
+
+	if err := s.charge(ctx, order); err != nil {
+		return err
+	}
+
+	if err := s.ticketRepo.UpdateStatus(ctx, order.Tickets, Issued, claims.UserID); err != nil {
+		s.uncharge(ctx, order)
+		return err
+	}
+
+	return nil
+}
+
+// charge redeems the points of the order, takes the wallet amount from the
+// wallet of the user and the rest by card. The points and the wallet are
+// given back when a later step fails.
+func (s *defaultService) charge(ctx context.Context, order *Order) error {
+	if order.Points > 0 {
+		discount, err := s.loyaltyService.Redeem(ctx, order.UserID, order.Points, order.ID)
+		if err != nil {
+			return err
 		}
+		order.Discount = discount
+	}
 
-		if err = s.tripRepo.UpdateAvailableSeat(ctx, requestedTrip.ID, len(tickets)); err != nil {
-			return ErrNoCapacity
+	if order.WalletAmount > 0 {
+		if err := s.walletService.Debit(ctx, order.UserID, order.WalletAmount, wallet.Purchase, orderReference(order.ID)); err != nil {
+			s.restorePoints(ctx, order)
+			return err
 		}
+	}
 
-		if err = s.ticketRepo.CreateTicketWithDetails(ctx, &purchasedTicket); err != nil {
+	if card := order.CardAmount(); card > 0 {
+		if err := s.payment.Transfer(card); err != nil {
+			s.restoreWallet(ctx, order)
+			s.restorePoints(ctx, order)
 			return err
 		}
 	}
 
-	if err := s.payment.Transfer(); err != nil {
+	return nil
+}
+
+// uncharge gives back everything charge took for the order.
+func (s *defaultService) uncharge(ctx context.Context, order *Order) {
+	if card := order.CardAmount(); card > 0 {
+		if err := s.payment.Refund(card); err != nil {
+			log.Error(err)
+		}
+	}
+	s.restoreWallet(ctx, order)
+	s.restorePoints(ctx, order)
+}
+
+func (s *defaultService) restoreWallet(ctx context.Context, order *Order) {
+	if order.WalletAmount <= 0 {
+		return
+	}
+
+	if err := s.walletService.Credit(ctx, order.UserID, order.WalletAmount, wallet.Reversal, orderReference(order.ID)); err != nil {
+		log.Error(err)
+	}
+}
+
+// findOrder returns the order the ticket was paid in. Tickets sold before
+// orders were recorded count as paid by card.
+func (s *defaultService) findOrder(ctx context.Context, ticket *Ticket) (*Order, error) {
+	if ticket.OrderID == 0 {
+		return &Order{TotalPrice: ticket.Price}, nil
+	}
+
+	return s.ticketRepo.FindOrder(ctx, ticket.OrderID)
+}
+
+// accruePoints gives the points of a ticket for the share of its price paid
+// by card. Points paid by points or the wallet do not earn points again.
+func (s *defaultService) accruePoints(ctx context.Context, ticket *Ticket, t *trip.Trip, order *Order) {
+	if err := s.loyaltyService.Accrue(ctx, ticket.UserID, ticket.ID, t, order.CardShare(ticket.Price)); err != nil {
+		s.pointsFailed(ctx, ticket, err)
+	}
+}
+
+func (s *defaultService) reversePoints(ctx context.Context, ticket *Ticket) {
+	if err := s.loyaltyService.Reverse(ctx, ticket.UserID, ticket.ID); err != nil {
+		s.pointsFailed(ctx, ticket, err)
+	}
+}
+
+// pointsFailed leaves the points of the ticket to be retried by retryPoints.
+func (s *defaultService) pointsFailed(ctx context.Context, ticket *Ticket, err error) {
+	log.Errorf("loyalty points of ticket %d could not be recorded: %v", ticket.ID, err)
+
+	if err = s.ticketRepo.SetPointsPending(ctx, ticket.ID, true); err != nil {
+		log.Error(err)
+	}
+}
+
+// retryPoints accrues the points of paid tickets and reverses those of
+// refunded tickets whose points failed to be recorded. Accruals and reversals
+// are recorded once, so a retry of what was recorded already is harmless.
+func (s *defaultService) retryPoints(ctx context.Context) error {
+	tickets, err := s.ticketRepo.FindPointsPending(ctx)
+	if err != nil {
 		return err
 	}
 
-	for i := range params {
-		for k := range passengersNames {
-			params[i].Description += fmt.Sprintf("%s\n", passengersNames[k])
+	for i := range tickets {
+		ticket := &tickets[i]
+
+		switch ticket.Status {
+		case Issued, CheckedIn, Boarded, NoShow:
+			order, err := s.findOrder(ctx, ticket)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			paidTrip, err := s.findTrip(ctx, ticket.TripID)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			err = s.loyaltyService.Accrue(ctx, ticket.UserID, ticket.ID, paidTrip, order.CardShare(ticket.Price))
+		default:
+			err = s.loyaltyService.Reverse(ctx, ticket.UserID, ticket.ID)
+		}
+		if err != nil {
+			log.Errorf("loyalty points of ticket %d could not be recorded: %v", ticket.ID, err)
+			continue
 		}
-	}
 
-	for i := 0; i < len(params); i++ {
-		if err := s.notificationService.Send(ctx, params[i]); err != nil {
-			return err
+		if err = s.ticketRepo.SetPointsPending(ctx, ticket.ID, false); err != nil {
+			log.Error(err)
 		}
 	}
 
 	return nil
 }
 
-func checkIndividualLimit(claims auth.Claims, tickets []Ticket) error {
-	if claims.IsIndividualUser() && len(tickets) > IndividualLimit {
-		return ErrExceedAllowedTicketToPurchase(IndividualLimit)
+func (s *defaultService) restorePoints(ctx context.Context, order *Order) {
+	if order.Points == 0 {
+		return
+	}
+
+	if err := s.loyaltyService.Restore(ctx, order.UserID, order.ID); err != nil {
+		log.Error(err)
+	}
+}
+
+// exchangeReceipt charges the fare difference and the change fee, unless the
+// user has free changes. A negative amount is refunded.
+func exchangeReceipt(previousPrice, price, changeFee float64, freeChanges bool) ExchangeReceipt {
+	receipt := ExchangeReceipt{
+		FareDifference: price - previousPrice,
+		ChangeFee:      changeFee,
+	}
+	if freeChanges {
+		receipt.ChangeFee = 0
+	}
+	receipt.Amount = receipt.FareDifference + receipt.ChangeFee
+
+	return receipt
+}
+
+func orderReference(orderID int) string {
+	return fmt.Sprintf("order:%d", orderID)
+}
+
+func ticketReference(ticketID int) string {
+	return fmt.Sprintf("ticket:%d", ticketID)
+}
+
+// heldSeats returns the seats of the trip held for waitlist offers, except
+// the offer the order is claiming.
+func (s *defaultService) heldSeats(ctx context.Context, tripID int, hold *waitlist.Entry) (map[trip.FareClassName]int, error) {
+	held, err := s.waitlistService.HeldSeats(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hold != nil && hold.TripID == tripID {
+		held[hold.FareClass] -= hold.Seats
+	}
+
+	return held, nil
+}
+
+func newTicket(requestedTrip *trip.Trip, claims auth.Claims, passenger Passenger) Ticket {
+	return Ticket{
+		TripID: requestedTrip.ID,
+		UserID: claims.UserID,
+		Status: Held,
+		Passenger: Passenger{
+			Gender:   passenger.Gender,
+			FullName: passenger.FullName,
+			Email:    passenger.Email,
+			Phone:    passenger.Phone,
+
+			DocumentType:   passenger.DocumentType,
+			DocumentNumber: passenger.DocumentNumber,
+			BirthDate:      passenger.BirthDate,
+			Nationality:    passenger.Nationality,
+
+			Category:   passenger.Category,
+			InfantSeat: passenger.InfantSeat,
+		},
+	}
+}
+
+// checkPurchaseLimits allows bonus tickets more than the limit of the user
+// type, given by the loyalty tier of the user.
+func checkPurchaseLimits(claims auth.Claims, passengers []Passenger, bonus int) error {
+	if err := checkCorporatedLimit(claims, passengers, bonus); err != nil {
+		return err
+	}
+
+	if err := checkIndividualLimit(claims, passengers, bonus); err != nil {
+		return err
+	}
+
+	return checkMaleTicketLimit(passengers, claims)
+}
+
+func checkIndividualLimit(claims auth.Claims, passengers []Passenger, bonus int) error {
+	if claims.IsIndividualUser() && len(passengers) > IndividualLimit+bonus {
+		return &LimitError{Limit: IndividualLimit + bonus}
 	}
 	return nil
 }
 
-func checkCorporatedLimit(claims auth.Claims, tickets []Ticket) error {
-	if claims.IsCorporatedUser() && len(tickets) > CorporatedLimit {
-		return ErrExceedAllowedTicketToPurchase(CorporatedLimit)
+func checkCorporatedLimit(claims auth.Claims, passengers []Passenger, bonus int) error {
+	if claims.IsCorporatedUser() && len(passengers) > CorporatedLimit+bonus {
+		return &LimitError{Limit: CorporatedLimit + bonus}
 	}
 	return nil
 }
 
-func checkMaleTicketLimit(tickets []Ticket, claims auth.Claims) error {
+func checkMaleTicketLimit(passengers []Passenger, claims auth.Claims) error {
 	var maleNum int
 
-	for i := range tickets {
-		if tickets[i].Gender == Male {
+	for i := range passengers {
+		if passengers[i].Gender == Male {
 			maleNum++
 		}
 	}
@@ -156,3 +1324,19 @@ func checkMaleTicketLimit(tickets []Ticket, claims auth.Claims) error {
 
 	return nil
 }
+
+func (s *defaultService) checkEmailVerified(ctx context.Context, claims auth.Claims) error {
+	if s.emailVerifier == nil {
+		return nil
+	}
+
+	verified, err := s.emailVerifier.IsEmailVerified(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
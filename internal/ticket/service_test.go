@@ -0,0 +1,661 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/dilaragorum/online-ticket-project-go/internal/loyalty"
+	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/dilaragorum/online-ticket-project-go/internal/waitlist"
+	"github.com/dilaragorum/online-ticket-project-go/internal/wallet"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeRepository struct {
+	Repository
+	tickets     map[int]*Ticket
+	orders      map[int]*Order
+	exchangeErr error
+	exchanged   []Ticket
+}
+
+func (r *fakeRepository) FindByID(ctx context.Context, id int) (*Ticket, error) {
+	ticket, ok := r.tickets[id]
+	if !ok {
+		return nil, ErrTicketNotFound
+	}
+	copied := *ticket
+	return &copied, nil
+}
+
+func (r *fakeRepository) FindOrder(ctx context.Context, id int) (*Order, error) {
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return order, nil
+}
+
+func (r *fakeRepository) Exchange(ctx context.Context, ticket *Ticket, exchanged *Ticket, userID uint) error {
+	if r.exchangeErr != nil {
+		return r.exchangeErr
+	}
+	exchanged.ID = len(r.tickets) + 1
+	r.exchanged = append(r.exchanged, *exchanged)
+	return nil
+}
+
+func (r *fakeRepository) UpdateStatus(ctx context.Context, tickets []Ticket, to Status, userID uint) error {
+	for i := range tickets {
+		if _, err := tickets[i].MoveTo(to, userID); err != nil {
+			return err
+		}
+		r.tickets[tickets[i].ID].Status = to
+	}
+	return nil
+}
+
+func (r *fakeRepository) FindNoShows(ctx context.Context, departedBefore time.Time) ([]Ticket, error) {
+	var tickets []Ticket
+	for _, ticket := range r.tickets {
+		if ticket.Status == Issued {
+			tickets = append(tickets, *ticket)
+		}
+	}
+	return tickets, nil
+}
+
+func (r *fakeRepository) MarkNoShow(ctx context.Context, ticket *Ticket, credit float64) error {
+	if _, err := ticket.MoveTo(NoShow, 0); err != nil {
+		return err
+	}
+	r.tickets[ticket.ID].Status = NoShow
+	r.tickets[ticket.ID].NoShowCredit = credit
+	return nil
+}
+
+func (r *fakeRepository) FindUncreditedNoShows(ctx context.Context) ([]Ticket, error) {
+	var tickets []Ticket
+	for _, ticket := range r.tickets {
+		if ticket.Status == NoShow && ticket.NoShowCredit > 0 && !ticket.NoShowCredited {
+			tickets = append(tickets, *ticket)
+		}
+	}
+	return tickets, nil
+}
+
+func (r *fakeRepository) MarkNoShowCredited(ctx context.Context, ticketID int) error {
+	r.tickets[ticketID].NoShowCredited = true
+	return nil
+}
+
+func (r *fakeRepository) FindPointsPending(ctx context.Context) ([]Ticket, error) {
+	var tickets []Ticket
+	for _, ticket := range r.tickets {
+		if ticket.PointsPending {
+			tickets = append(tickets, *ticket)
+		}
+	}
+	return tickets, nil
+}
+
+func (r *fakeRepository) SetPointsPending(ctx context.Context, ticketID int, pending bool) error {
+	r.tickets[ticketID].PointsPending = pending
+	return nil
+}
+
+func (r *fakeRepository) FindRefundsPending(ctx context.Context) ([]Ticket, error) {
+	var tickets []Ticket
+	for _, ticket := range r.tickets {
+		if ticket.RefundPending {
+			tickets = append(tickets, *ticket)
+		}
+	}
+	return tickets, nil
+}
+
+func (r *fakeRepository) SetRefundPending(ctx context.Context, ticketID int, pending bool, walletAmount float64, points int) error {
+	ticket := r.tickets[ticketID]
+	ticket.RefundPending, ticket.PendingWallet, ticket.PendingPoints = pending, walletAmount, points
+	return nil
+}
+
+type fakeTripRepository struct {
+	trip.Repository
+	trips map[int]*trip.Trip
+}
+
+func (r *fakeTripRepository) FindByTripID(ctx context.Context, tripID int) (*trip.Trip, error) {
+	t, ok := r.trips[tripID]
+	if !ok {
+		return nil, trip.ErrTripNotFound
+	}
+	return t, nil
+}
+
+type fakePayment struct {
+	transferErr error
+	refundErr   error
+	transfers   []float64
+	refunds     []float64
+}
+
+func (p *fakePayment) Transfer(amount float64) error {
+	if p.transferErr != nil {
+		return p.transferErr
+	}
+	p.transfers = append(p.transfers, amount)
+	return nil
+}
+
+func (p *fakePayment) Refund(amount float64) error {
+	if p.refundErr != nil {
+		return p.refundErr
+	}
+	p.refunds = append(p.refunds, amount)
+	return nil
+}
+
+type fakeLoyalty struct {
+	loyalty.Service
+	perks    loyalty.Perks
+	err      error
+	accrued  map[int]float64
+	reversed []int
+	restored map[int]int
+}
+
+func (l *fakeLoyalty) Perks(ctx context.Context, userID uint) (loyalty.Perks, error) {
+	return l.perks, nil
+}
+
+func (l *fakeLoyalty) Accrue(ctx context.Context, userID uint, ticketID int, t *trip.Trip, price float64) error {
+	if l.err != nil {
+		return l.err
+	}
+	if l.accrued == nil {
+		l.accrued = map[int]float64{}
+	}
+	l.accrued[ticketID] = price
+	return nil
+}
+
+func (l *fakeLoyalty) Reverse(ctx context.Context, userID uint, ticketID int) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.reversed = append(l.reversed, ticketID)
+	return nil
+}
+
+func (l *fakeLoyalty) RestoreTicket(ctx context.Context, userID uint, ticketID int, points int) error {
+	if l.restored == nil {
+		l.restored = map[int]int{}
+	}
+	l.restored[ticketID] = points
+	return nil
+}
+
+type fakeWaitlist struct {
+	waitlist.Service
+	released []int
+}
+
+func (w *fakeWaitlist) HeldSeats(ctx context.Context, tripID int) (map[trip.FareClassName]int, error) {
+	return map[trip.FareClassName]int{}, nil
+}
+
+func (w *fakeWaitlist) SeatsReleased(ctx context.Context, tripID int) error {
+	w.released = append(w.released, tripID)
+	return nil
+}
+
+type fakeWallet struct {
+	wallet.Service
+	creditErr error
+	credits   []float64
+}
+
+func (w *fakeWallet) Credit(ctx context.Context, userID uint, amount float64, reason wallet.Reason, reference string) error {
+	if w.creditErr != nil {
+		return w.creditErr
+	}
+	w.credits = append(w.credits, amount)
+	return nil
+}
+
+type fakeNotifier struct {
+	err error
+}
+
+func (n *fakeNotifier) Send(ctx context.Context, param notification.Param) error {
+	return n.err
+}
+
+type fakeTravellerFinder struct {
+	passengers map[uint]Passenger
+	userID     uint
+}
+
+func (f *fakeTravellerFinder) FindPassenger(ctx context.Context, userID uint, travellerID uint) (*Passenger, error) {
+	passenger, ok := f.passengers[travellerID]
+	if !ok || userID != f.userID {
+		return nil, ErrTravellerNotFound
+	}
+	return &passenger, nil
+}
+
+func TestService_findTravellers(t *testing.T) {
+	s := &defaultService{travellerFinder: &fakeTravellerFinder{
+		userID:     7,
+		passengers: map[uint]Passenger{3: {FullName: "Saved Traveller"}},
+	}}
+
+	tests := []struct {
+		name               string
+		userID             uint
+		passengers         []Passenger
+		travellerIDs       []uint
+		expectedNames      []string
+		expectedTravellers []uint
+		expectedErr        error
+	}{
+		{
+			name:               "inline passengers come first",
+			userID:             7,
+			passengers:         []Passenger{{FullName: "Inline Passenger"}},
+			travellerIDs:       []uint{3},
+			expectedNames:      []string{"Inline Passenger", "Saved Traveller"},
+			expectedTravellers: []uint{0, 3},
+		},
+		{
+			name:               "only saved travellers",
+			userID:             7,
+			travellerIDs:       []uint{3},
+			expectedNames:      []string{"Saved Traveller"},
+			expectedTravellers: []uint{3},
+		},
+		{
+			name:         "traveller of another user",
+			userID:       8,
+			travellerIDs: []uint{3},
+			expectedErr:  ErrTravellerNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passengers, travellerIDs, err := s.findTravellers(context.Background(), tt.userID, tt.passengers, tt.travellerIDs)
+			if err != tt.expectedErr {
+				t.Fatalf("findTravellers() error = %v, want %v", err, tt.expectedErr)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+
+			names := make([]string, 0, len(passengers))
+			for i := range passengers {
+				names = append(names, passengers[i].FullName)
+			}
+			if !reflect.DeepEqual(names, tt.expectedNames) || !reflect.DeepEqual(travellerIDs, tt.expectedTravellers) {
+				t.Errorf("findTravellers() = %v, %v, want %v, %v", names, travellerIDs, tt.expectedNames, tt.expectedTravellers)
+			}
+		})
+	}
+}
+
+func TestService_Exchange(t *testing.T) {
+	departure := time.Now().Add(72 * time.Hour)
+	newTrip := func(id int, price float64) *trip.Trip {
+		return &trip.Trip{ID: id, From: "Istanbul", To: "Ankara", Vehicle: trip.VehicleBus, Date: departure.Add(time.Duration(id) * time.Hour), Capacity: 40, AvailableSeat: 10,
+			FareClasses: []trip.FareClass{{Name: trip.Economy, Capacity: 40, AvailableSeat: 10, Price: price, Changeable: true, ChangeFee: 20}}}
+	}
+	failure := errors.New("failure")
+
+	tests := []struct {
+		name              string
+		targetPrice       float64
+		freeChanges       bool
+		order             Order
+		payment           fakePayment
+		exchangeErr       error
+		notificationErr   error
+		expectedErr       error
+		expectedAmount    float64
+		expectedTransfers []float64
+		expectedRefunds   []float64
+		expectedCredits   []float64
+		expectedExchanged bool
+	}{
+		{
+			name:              "dearer trip charges the difference and the fee",
+			targetPrice:       150,
+			expectedAmount:    70,
+			expectedTransfers: []float64{70},
+			expectedExchanged: true,
+		},
+		{
+			name:              "cheaper trip refunds the difference after the fee",
+			targetPrice:       50,
+			expectedAmount:    -30,
+			expectedRefunds:   []float64{30},
+			expectedExchanged: true,
+		},
+		{
+			name:              "wallet share of the refund goes back to the wallet",
+			targetPrice:       50,
+			order:             Order{ID: 1, TotalPrice: 100, WalletAmount: 40},
+			expectedAmount:    -30,
+			expectedRefunds:   []float64{18},
+			expectedCredits:   []float64{12},
+			expectedExchanged: true,
+		},
+		{
+			name:              "free changes waive the fee",
+			targetPrice:       100,
+			freeChanges:       true,
+			expectedExchanged: true,
+		},
+		{
+			name:        "failed charge keeps the ticket",
+			targetPrice: 150,
+			payment:     fakePayment{transferErr: failure},
+			expectedErr: failure,
+		},
+		{
+			name:              "failed exchange gives the charge back",
+			targetPrice:       150,
+			exchangeErr:       ErrNoCapacity,
+			expectedErr:       ErrNoCapacity,
+			expectedTransfers: []float64{70},
+			expectedRefunds:   []float64{70},
+		},
+		{
+			name:              "failed card refund goes to the wallet",
+			targetPrice:       50,
+			payment:           fakePayment{refundErr: failure},
+			expectedAmount:    -30,
+			expectedCredits:   []float64{30},
+			expectedExchanged: true,
+		},
+		{
+			name:              "failed email does not fail the exchange",
+			targetPrice:       150,
+			notificationErr:   failure,
+			expectedAmount:    70,
+			expectedTransfers: []float64{70},
+			expectedExchanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{
+				tickets:     map[int]*Ticket{1: {ID: 1, OrderID: tt.order.ID, TripID: 1, UserID: 7, FareClass: trip.Economy, Price: 100, Status: Issued}},
+				orders:      map[int]*Order{1: &tt.order},
+				exchangeErr: tt.exchangeErr,
+			}
+			payment := tt.payment
+			waitlistService := &fakeWaitlist{}
+			walletService := &fakeWallet{}
+			s := &defaultService{
+				ticketRepo:          repo,
+				tripRepo:            &fakeTripRepository{trips: map[int]*trip.Trip{1: newTrip(1, 100), 2: newTrip(2, tt.targetPrice)}},
+				payment:             &payment,
+				loyaltyService:      &fakeLoyalty{perks: loyalty.Perks{FreeChanges: tt.freeChanges}},
+				waitlistService:     waitlistService,
+				walletService:       walletService,
+				notificationService: &fakeNotifier{err: tt.notificationErr},
+			}
+
+			receipt, err := s.Exchange(context.Background(), 1, Exchange{TripID: 2}, auth.Claims{UserID: 7})
+			if err != tt.expectedErr {
+				t.Fatalf("Exchange() error = %v, want %v", err, tt.expectedErr)
+			}
+			if err == nil && receipt.Amount != tt.expectedAmount {
+				t.Errorf("Exchange() amount = %v, want %v", receipt.Amount, tt.expectedAmount)
+			}
+			if !reflect.DeepEqual(payment.transfers, tt.expectedTransfers) || !reflect.DeepEqual(payment.refunds, tt.expectedRefunds) {
+				t.Errorf("transfers = %v, refunds = %v, want %v, %v", payment.transfers, payment.refunds, tt.expectedTransfers, tt.expectedRefunds)
+			}
+			if !reflect.DeepEqual(walletService.credits, tt.expectedCredits) {
+				t.Errorf("wallet credits = %v, want %v", walletService.credits, tt.expectedCredits)
+			}
+			if exchanged := len(repo.exchanged) == 1; exchanged != tt.expectedExchanged {
+				t.Fatalf("exchanged = %v, want %v", exchanged, tt.expectedExchanged)
+			}
+			if tt.expectedExchanged && (repo.exchanged[0].Status != Issued || !reflect.DeepEqual(waitlistService.released, []int{1})) {
+				t.Errorf("exchanged status = %v, released = %v, want %v, [1]", repo.exchanged[0].Status, waitlistService.released, Issued)
+			}
+		})
+	}
+}
+
+func TestService_Cancel(t *testing.T) {
+	departure := time.Now().Add(72 * time.Hour)
+
+	tests := []struct {
+		name            string
+		status          Status
+		toWallet        bool
+		walletAmount    float64
+		discount        float64
+		points          int
+		expectedStatus  Status
+		expectedRefunds []float64
+		expectedCredits []float64
+		expectedPoints  int
+	}{
+		{name: "issued ticket is refunded by card", status: Issued, expectedStatus: Refunded, expectedRefunds: []float64{90}},
+		{name: "checked in ticket is refunded to the wallet", status: CheckedIn, toWallet: true, expectedStatus: Refunded, expectedCredits: []float64{90}},
+		{name: "wallet share goes back to the wallet", status: Issued, walletAmount: 50, expectedStatus: Refunded, expectedRefunds: []float64{45}, expectedCredits: []float64{45}},
+		{name: "points share goes back as points", status: Issued, discount: 20, points: 2000, expectedStatus: Refunded, expectedRefunds: []float64{72}, expectedPoints: 1800},
+		{name: "held ticket is not refunded", status: Held, expectedStatus: Cancelled},
+		{name: "pending ticket is not refunded", status: PendingPayment, toWallet: true, expectedStatus: Cancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{
+				tickets: map[int]*Ticket{1: {ID: 1, OrderID: 1, TripID: 1, UserID: 7, FareClass: trip.Economy, Price: 100, Status: tt.status}},
+				orders:  map[int]*Order{1: {ID: 1, UserID: 7, TotalPrice: 100, WalletAmount: tt.walletAmount, Discount: tt.discount, Points: tt.points}},
+			}
+			loyaltyService := &fakeLoyalty{}
+			payment := &fakePayment{}
+			walletService := &fakeWallet{}
+			s := &defaultService{
+				ticketRepo: repo,
+				tripRepo: &fakeTripRepository{trips: map[int]*trip.Trip{1: {ID: 1, Date: departure,
+					FareClasses: []trip.FareClass{{Name: trip.Economy, Price: 100, Refundable: true, RefundFeeRate: 0.1}}}}},
+				payment:             payment,
+				loyaltyService:      loyaltyService,
+				waitlistService:     &fakeWaitlist{},
+				walletService:       walletService,
+				notificationService: &fakeNotifier{},
+			}
+
+			if err := s.Cancel(context.Background(), 1, tt.toWallet, auth.Claims{UserID: 7}); err != nil {
+				t.Fatalf("Cancel() error = %v", err)
+			}
+			if repo.tickets[1].Status != tt.expectedStatus {
+				t.Errorf("status = %v, want %v", repo.tickets[1].Status, tt.expectedStatus)
+			}
+			if !reflect.DeepEqual(payment.refunds, tt.expectedRefunds) || !reflect.DeepEqual(walletService.credits, tt.expectedCredits) {
+				t.Errorf("refunds = %v, credits = %v, want %v, %v", payment.refunds, walletService.credits, tt.expectedRefunds, tt.expectedCredits)
+			}
+			if loyaltyService.restored[1] != tt.expectedPoints {
+				t.Errorf("restored points = %v, want %v", loyaltyService.restored[1], tt.expectedPoints)
+			}
+		})
+	}
+}
+
+func TestService_retryRefunds(t *testing.T) {
+	repo := &fakeRepository{
+		tickets: map[int]*Ticket{1: {ID: 1, OrderID: 1, TripID: 1, UserID: 7, FareClass: trip.Economy, Price: 100, Status: Issued}},
+		orders:  map[int]*Order{1: {ID: 1, UserID: 7, TotalPrice: 100, Discount: 20, Points: 2000}},
+	}
+	failure := errors.New("failure")
+	walletService := &fakeWallet{creditErr: failure}
+	loyaltyService := &fakeLoyalty{}
+	s := &defaultService{
+		ticketRepo: repo,
+		tripRepo: &fakeTripRepository{trips: map[int]*trip.Trip{1: {ID: 1, Date: time.Now().Add(72 * time.Hour),
+			FareClasses: []trip.FareClass{{Name: trip.Economy, Price: 100, Refundable: true, RefundFeeRate: 0.1}}}}},
+		payment:             &fakePayment{refundErr: failure},
+		loyaltyService:      loyaltyService,
+		waitlistService:     &fakeWaitlist{},
+		walletService:       walletService,
+		notificationService: &fakeNotifier{},
+	}
+
+	if err := s.Cancel(context.Background(), 1, false, auth.Claims{UserID: 7}); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	ticket := repo.tickets[1]
+	if ticket.Status != Cancelled || !ticket.RefundPending || ticket.PendingWallet != 72 || ticket.PendingPoints != 1800 {
+		t.Fatalf("ticket = %v pending %v with %v and %v points, want %v pending with the card share 72 and 1800 points",
+			ticket.Status, ticket.RefundPending, ticket.PendingWallet, ticket.PendingPoints, Cancelled)
+	}
+
+	walletService.creditErr = nil
+	if err := s.retryRefunds(context.Background()); err != nil {
+		t.Fatalf("retryRefunds() error = %v", err)
+	}
+	if ticket.Status != Refunded || ticket.RefundPending {
+		t.Errorf("ticket = %v pending %v, want %v and not pending", ticket.Status, ticket.RefundPending, Refunded)
+	}
+	if !reflect.DeepEqual(walletService.credits, []float64{72}) || loyaltyService.restored[1] != 1800 {
+		t.Errorf("credits = %v, restored points = %v, want [72] and 1800", walletService.credits, loyaltyService.restored[1])
+	}
+
+	if err := s.retryRefunds(context.Background()); err != nil {
+		t.Fatalf("retryRefunds() error = %v", err)
+	}
+	if len(walletService.credits) != 1 {
+		t.Errorf("credits = %v, want a settled refund not to be retried", walletService.credits)
+	}
+}
+
+func TestService_retryPoints(t *testing.T) {
+	repo := &fakeRepository{
+		tickets: map[int]*Ticket{
+			1: {ID: 1, OrderID: 1, TripID: 1, UserID: 7, Price: 100, Status: Issued},
+			2: {ID: 2, OrderID: 1, TripID: 1, UserID: 7, Price: 100, Status: Refunded},
+		},
+		orders: map[int]*Order{1: {ID: 1, UserID: 7, TotalPrice: 200, WalletAmount: 50, Discount: 30, Points: 3000}},
+	}
+	loyaltyService := &fakeLoyalty{err: errors.New("loyalty is down")}
+	s := &defaultService{
+		ticketRepo:     repo,
+		tripRepo:       &fakeTripRepository{trips: map[int]*trip.Trip{1: {ID: 1}}},
+		loyaltyService: loyaltyService,
+	}
+
+	order, _ := repo.FindOrder(context.Background(), 1)
+	s.accruePoints(context.Background(), repo.tickets[1], &trip.Trip{ID: 1}, order)
+	s.reversePoints(context.Background(), repo.tickets[2])
+	if !repo.tickets[1].PointsPending || !repo.tickets[2].PointsPending {
+		t.Fatal("failed points are not left to be retried")
+	}
+
+	loyaltyService.err = nil
+	if err := s.retryPoints(context.Background()); err != nil {
+		t.Fatalf("retryPoints() error = %v", err)
+	}
+	if repo.tickets[1].PointsPending || repo.tickets[2].PointsPending {
+		t.Error("retried points are still pending")
+	}
+	if loyaltyService.accrued[1] != 60 {
+		t.Errorf("accrued on %v, want only the card share 60", loyaltyService.accrued[1])
+	}
+	if !reflect.DeepEqual(loyaltyService.reversed, []int{2}) {
+		t.Errorf("reversed = %v, want [2]", loyaltyService.reversed)
+	}
+}
+
+func TestService_ProcessNoShows(t *testing.T) {
+	repo := &fakeRepository{tickets: map[int]*Ticket{
+		1: {ID: 1, TripID: 1, UserID: 7, FareClass: trip.Economy, Price: 100, Status: Issued},
+		2: {ID: 2, TripID: 2, UserID: 7, FareClass: trip.Economy, Price: 100, Status: Issued},
+		3: {ID: 3, TripID: 1, UserID: 8, FareClass: trip.Economy, Price: 40, Status: NoShow, NoShowCredit: 20},
+	}}
+	walletService := &fakeWallet{creditErr: errors.New("wallet is down")}
+	s := &defaultService{
+		ticketRepo: repo,
+		tripRepo: &fakeTripRepository{trips: map[int]*trip.Trip{1: {ID: 1,
+			FareClasses: []trip.FareClass{{Name: trip.Economy, Price: 100, NoShowCreditRate: 0.5}}}}},
+		walletService:       walletService,
+		notificationService: &fakeNotifier{err: errors.New("mail server is down")},
+	}
+
+	if err := s.ProcessNoShows(context.Background()); err != nil {
+		t.Fatalf("ProcessNoShows() error = %v", err)
+	}
+	if repo.tickets[1].Status != NoShow || repo.tickets[1].NoShowCredit != 50 {
+		t.Errorf("ticket 1 = %v with %v credit, want %v with 50 credit", repo.tickets[1].Status, repo.tickets[1].NoShowCredit, NoShow)
+	}
+	if repo.tickets[2].Status != Issued {
+		t.Errorf("ticket of a missing trip = %v, want %v", repo.tickets[2].Status, Issued)
+	}
+	if repo.tickets[1].NoShowCredited || repo.tickets[3].NoShowCredited {
+		t.Error("failed credits are marked as credited")
+	}
+
+	walletService.creditErr = nil
+	if err := s.ProcessNoShows(context.Background()); err != nil {
+		t.Fatalf("ProcessNoShows() error = %v", err)
+	}
+	if !repo.tickets[1].NoShowCredited || !repo.tickets[3].NoShowCredited {
+		t.Error("failed credits are not retried")
+	}
+	if len(walletService.credits) != 2 {
+		t.Errorf("credits = %v, want 2 credits", walletService.credits)
+	}
+}
+
+type fakeEmailVerifier struct {
+	verified map[uint]bool
+}
+
+func (f *fakeEmailVerifier) IsEmailVerified(ctx context.Context, userID uint) (bool, error) {
+	return f.verified[userID], nil
+}
+
+func TestService_checkEmailVerified(t *testing.T) {
+	verifier := &fakeEmailVerifier{verified: map[uint]bool{7: true}}
+
+	tests := []struct {
+		name        string
+		verifier    EmailVerifier
+		userID      uint
+		expectedErr error
+	}{
+		{name: "verified email", verifier: verifier, userID: 7},
+		{name: "unverified email", verifier: verifier, userID: 8, expectedErr: ErrEmailNotVerified},
+		{name: "verification turned off", userID: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &defaultService{ticketRepo: &fakeRepository{}}
+			if tt.verifier != nil {
+				s.emailVerifier = tt.verifier
+			}
+
+			if err := s.checkEmailVerified(context.Background(), auth.Claims{UserID: tt.userID}); err != tt.expectedErr {
+				t.Errorf("checkEmailVerified() error = %v, want %v", err, tt.expectedErr)
+			}
+			if tt.expectedErr == nil {
+				return
+			}
+
+			claims := auth.Claims{UserID: tt.userID}
+			if err := s.Purchase(context.Background(), []Ticket{{TripID: 1}}, Checkout{}, claims); err != tt.expectedErr {
+				t.Errorf("Purchase() error = %v, want %v", err, tt.expectedErr)
+			}
+			if err := s.PurchaseItinerary(context.Background(), ItineraryOrder{OutboundTripIDs: []int{1}}, claims); err != tt.expectedErr {
+				t.Errorf("PurchaseItinerary() error = %v, want %v", err, tt.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package ticket
+
+import (
+	"errors"
+	"time"
+)
+
+type Status string
+
+const (
+	Held           Status = "held"
+	PendingPayment Status = "pending_payment"
+	Issued         Status = "issued"
+	CheckedIn      Status = "checked_in"
+	Boarded        Status = "boarded"
+	Cancelled      Status = "cancelled"
+	Refunded       Status = "refunded"
+	NoShow         Status = "no_show"
+	Exchanged      Status = "exchanged"
+
+	// CheckInWindow is how long before the departure check-in opens.
+	CheckInWindow = 24 * time.Hour
+)
+
+var (
+	ErrInvalidStatus     = errors.New("ticket status is invalid")
+	ErrInvalidTransition = errors.New("ticket cannot move to this status")
+	ErrCheckInNotOpen    = errors.New("check-in is not open for this trip yet")
+)
+
+// transitions lists the statuses a ticket may move to from each status.
+// Statuses without an entry are final.
+var transitions = map[Status][]Status{
+	Held:           {PendingPayment, Cancelled},
+	PendingPayment: {Issued, Cancelled},
+	Issued:         {CheckedIn, Cancelled, Exchanged, NoShow},
+	CheckedIn:      {Boarded, Cancelled, NoShow},
+	Cancelled:      {Refunded},
+}
+
+var statuses = []Status{Held, PendingPayment, Issued, CheckedIn, Boarded, Cancelled, Refunded, NoShow, Exchanged}
+
+// TicketTransition records when a ticket moved from one status to another.
+// The first transition of a ticket has no From status.
+type TicketTransition struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	TicketID  int    `gorm:"not null;index" json:"ticket_id"`
+	From      Status `json:"from,omitempty"`
+	To        Status `gorm:"not null" json:"to"`
+	UserID    uint   `gorm:"not null" json:"user_id"`
+	CreatedAt time.Time
+}
+
+// ListFilter narrows the tickets listed. Empty fields match every ticket.
+type ListFilter struct {
+	UserID   uint
+	TripID   int
+	Statuses []Status
+}
+
+func (s Status) IsValid() bool {
+	for _, status := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Status) CanTransitionTo(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPaid reports whether tickets of the status have been paid for, so that
+// cancelling them is refunded.
+func (s Status) IsPaid() bool {
+	return s == Issued || s == CheckedIn
+}
+
+// ReleasesSeat reports whether tickets of the status gave their seat back to the trip.
+// Such tickets are soft deleted, so they are not counted as sold anymore.
+func (s Status) ReleasesSeat() bool {
+	return s == Cancelled || s == Refunded || s == Exchanged
+}
+
+// IsAmendable reports whether the passenger details of tickets of the status may be corrected.
+func (s Status) IsAmendable() bool {
+	return s == Issued || s == CheckedIn
+}
+
+// MoveTo changes the status of the ticket when the transition is allowed
+// and returns its record.
+func (t *Ticket) MoveTo(to Status, userID uint) (TicketTransition, error) {
+	if !t.Status.CanTransitionTo(to) {
+		return TicketTransition{}, ErrInvalidTransition
+	}
+
+	transition := TicketTransition{TicketID: t.ID, From: t.Status, To: to, UserID: userID}
+	t.Status = to
+
+	return transition, nil
+}
+
+// IsCheckInOpen reports whether the passenger may check in for a trip departing at departure.
+func IsCheckInOpen(departure, now time.Time) bool {
+	return !now.Before(departure.Add(-CheckInWindow)) && now.Before(departure)
+}
@@ -0,0 +1,65 @@
+package ticket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicket_MoveTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     Status
+		to       Status
+		expected error
+	}{
+		{name: "payment started", from: Held, to: PendingPayment, expected: nil},
+		{name: "paid", from: PendingPayment, to: Issued, expected: nil},
+		{name: "check-in", from: Issued, to: CheckedIn, expected: nil},
+		{name: "boarding without check-in", from: Issued, to: Boarded, expected: ErrInvalidTransition},
+		{name: "refund of cancelled ticket", from: Cancelled, to: Refunded, expected: nil},
+		{name: "refund of issued ticket", from: Issued, to: Refunded, expected: ErrInvalidTransition},
+		{name: "cancel after boarding", from: Boarded, to: Cancelled, expected: ErrInvalidTransition},
+		{name: "exchange twice", from: Exchanged, to: Exchanged, expected: ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := Ticket{ID: 1, Status: tt.from}
+
+			transition, err := ticket.MoveTo(tt.to, 7)
+			if err != tt.expected {
+				t.Fatalf("MoveTo() error = %v, want %v", err, tt.expected)
+			}
+			if err != nil {
+				if ticket.Status != tt.from {
+					t.Errorf("Status = %v, want %v", ticket.Status, tt.from)
+				}
+				return
+			}
+			if ticket.Status != tt.to || transition.From != tt.from || transition.To != tt.to {
+				t.Errorf("MoveTo() = %+v, status %v", transition, ticket.Status)
+			}
+		})
+	}
+}
+
+func TestIsCheckInOpen(t *testing.T) {
+	departure := time.Date(2023, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		{name: "two days before", now: departure.Add(-48 * time.Hour), expected: false},
+		{name: "when it opens", now: departure.Add(-CheckInWindow), expected: true},
+		{name: "an hour before", now: departure.Add(-time.Hour), expected: true},
+		{name: "after departure", now: departure, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCheckInOpen(departure, tt.now); got != tt.expected {
+				t.Errorf("IsCheckInOpen() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
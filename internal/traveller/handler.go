@@ -0,0 +1,142 @@
+package traveller
+
+import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/dilaragorum/online-ticket-project-go/internal/ticket"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+var (
+	WarnInvalidID            = "Please enter valid ID"
+	WarnTravellerNotExist    = "This traveller does not exist"
+	WarnExceedTravellerLimit = "You have reached the maximum number of saved travellers"
+	WarnSystemFailure        = "There is something wrong. Please try again later"
+)
+
+type handler struct {
+	service Service
+}
+
+func NewHandler(routes *auth.Routes, service Service) *handler {
+	h := handler{service: service}
+
+	routes.POST("/travellers", h.Create, auth.RequirePermission(auth.AccountManage))
+	routes.GET("/travellers", h.List, auth.RequirePermission(auth.AccountManage))
+	routes.GET("/travellers/:id", h.Get, auth.RequirePermission(auth.AccountManage))
+	routes.PUT("/travellers/:id", h.Update, auth.RequirePermission(auth.AccountManage))
+	routes.DELETE("/travellers/:id", h.Delete, auth.RequirePermission(auth.AccountManage))
+
+	return &h
+}
+
+func (h *handler) Create(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	traveller := new(Traveller)
+	if err := c.Bind(traveller); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	traveller.ID = 0
+	traveller.UserID = claim.UserID
+
+	if msg, ok := validate(traveller); !ok {
+		return c.String(http.StatusBadRequest, msg)
+	}
+
+	if err := h.service.Create(c.Request().Context(), traveller); err != nil {
+		if errors.Is(err, ErrExceedTravellerLimit) {
+			return c.String(http.StatusBadRequest, WarnExceedTravellerLimit)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusCreated, traveller)
+}
+
+func (h *handler) List(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	travellers, err := h.service.List(c.Request().Context(), claim.UserID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, travellers)
+}
+
+func (h *handler) Get(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidID)
+	}
+
+	traveller, err := h.service.Get(c.Request().Context(), uint(id), claim.UserID)
+	if err != nil {
+		return travellerError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, traveller)
+}
+
+func (h *handler) Update(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidID)
+	}
+
+	traveller := new(Traveller)
+	if err = c.Bind(traveller); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	traveller.ID = uint(id)
+	traveller.UserID = claim.UserID
+
+	current, err := h.service.Get(c.Request().Context(), traveller.ID, traveller.UserID)
+	if err != nil {
+		return travellerError(c, err)
+	}
+	traveller.KeepMasked(current.Passenger)
+
+	if msg, ok := validate(traveller); !ok {
+		return c.String(http.StatusBadRequest, msg)
+	}
+
+	if err = h.service.Update(c.Request().Context(), traveller); err != nil {
+		return travellerError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *handler) Delete(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidID)
+	}
+
+	if err = h.service.Delete(c.Request().Context(), uint(id), claim.UserID); err != nil {
+		return travellerError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func validate(traveller *Traveller) (string, bool) {
+	return ticket.ValidatePassenger(&traveller.Passenger)
+}
+
+func travellerError(c echo.Context, err error) error {
+	if errors.Is(err, ErrTravellerNotExist) {
+		return c.String(http.StatusNotFound, WarnTravellerNotExist)
+	}
+	return c.String(http.StatusInternalServerError, WarnSystemFailure)
+}
@@ -0,0 +1,19 @@
+package traveller
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/ticket"
+	"gorm.io/gorm"
+	"time"
+)
+
+const MaxTravellersPerUser = 20
+
+// Traveller is a passenger saved by a user to be reused on later purchases.
+type Traveller struct {
+	ID     uint `gorm:"primarykey" json:"id"`
+	UserID uint `gorm:"not null;index" json:"user_id"`
+	ticket.Passenger
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
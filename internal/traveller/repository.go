@@ -0,0 +1,122 @@
+package traveller
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"time"
+)
+
+var ErrTravellerNotFound = errors.New("there is no traveller with that id")
+
+type Repository interface {
+	Create(ctx context.Context, traveller *Traveller) error
+	Update(ctx context.Context, traveller *Traveller) error
+	Delete(ctx context.Context, id uint, userID uint) error
+	FindByID(ctx context.Context, id uint, userID uint) (*Traveller, error)
+	FindByUser(ctx context.Context, userID uint) ([]Traveller, error)
+	CountByUser(ctx context.Context, userID uint) (int, error)
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+func (r *defaultRepository) Create(ctx context.Context, traveller *Traveller) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Create(traveller).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) Update(ctx context.Context, traveller *Traveller) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result := r.database.WithContext(timeoutCtx).Model(&Traveller{}).
+		Where("id = ? AND user_id = ?", traveller.ID, traveller.UserID).
+		Select("gender", "full_name", "email", "phone", "document_type", "document_number", "birth_date", "nationality", "category", "infant_seat").
+		Updates(traveller)
+	if result.Error != nil {
+		log.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrTravellerNotFound
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) Delete(ctx context.Context, id uint, userID uint) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result := r.database.WithContext(timeoutCtx).Where("user_id = ?", userID).Delete(&Traveller{}, id)
+	if result.Error != nil {
+		log.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrTravellerNotFound
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) FindByID(ctx context.Context, id uint, userID uint) (*Traveller, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var traveller Traveller
+
+	if err := r.database.WithContext(timeoutCtx).Where("user_id = ?", userID).First(&traveller, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTravellerNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &traveller, nil
+}
+
+func (r *defaultRepository) FindByUser(ctx context.Context, userID uint) ([]Traveller, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var travellers []Traveller
+
+	if err := r.database.WithContext(timeoutCtx).Where("user_id = ?", userID).Order("id").Find(&travellers).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return travellers, nil
+}
+
+func (r *defaultRepository) CountByUser(ctx context.Context, userID uint) (int, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var count int64
+
+	if err := r.database.WithContext(timeoutCtx).Model(&Traveller{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		log.Error(err)
+		return -1, err
+	}
+
+	return int(count), nil
+}
@@ -0,0 +1,85 @@
+package traveller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dilaragorum/online-ticket-project-go/internal/ticket"
+)
+
+var (
+	ErrTravellerNotExist    = errors.New("this traveller does not exist")
+	ErrExceedTravellerLimit = fmt.Errorf("a user cannot save more than %d travellers", MaxTravellersPerUser)
+)
+
+// Service manages saved travellers and, as a ticket.TravellerFinder, turns
+// them into passengers on purchase.
+type Service interface {
+	ticket.TravellerFinder
+	Create(ctx context.Context, traveller *Traveller) error
+	Update(ctx context.Context, traveller *Traveller) error
+	Delete(ctx context.Context, id uint, userID uint) error
+	Get(ctx context.Context, id uint, userID uint) (*Traveller, error)
+	List(ctx context.Context, userID uint) ([]Traveller, error)
+}
+
+type defaultService struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &defaultService{repo: repo}
+}
+
+func (s *defaultService) Create(ctx context.Context, traveller *Traveller) error {
+	count, err := s.repo.CountByUser(ctx, traveller.UserID)
+	if err != nil {
+		return err
+	}
+
+	if count >= MaxTravellersPerUser {
+		return ErrExceedTravellerLimit
+	}
+
+	return s.repo.Create(ctx, traveller)
+}
+
+func (s *defaultService) Update(ctx context.Context, traveller *Traveller) error {
+	return notExist(s.repo.Update(ctx, traveller))
+}
+
+func (s *defaultService) Delete(ctx context.Context, id uint, userID uint) error {
+	return notExist(s.repo.Delete(ctx, id, userID))
+}
+
+func (s *defaultService) Get(ctx context.Context, id uint, userID uint) (*Traveller, error) {
+	traveller, err := s.repo.FindByID(ctx, id, userID)
+	if err != nil {
+		return nil, notExist(err)
+	}
+
+	return traveller, nil
+}
+
+func (s *defaultService) List(ctx context.Context, userID uint) ([]Traveller, error) {
+	return s.repo.FindByUser(ctx, userID)
+}
+
+func (s *defaultService) FindPassenger(ctx context.Context, userID uint, travellerID uint) (*ticket.Passenger, error) {
+	traveller, err := s.repo.FindByID(ctx, travellerID, userID)
+	if err != nil {
+		if errors.Is(err, ErrTravellerNotFound) {
+			return nil, ticket.ErrTravellerNotFound
+		}
+		return nil, err
+	}
+
+	return &traveller.Passenger, nil
+}
+
+func notExist(err error) error {
+	if errors.Is(err, ErrTravellerNotFound) {
+		return ErrTravellerNotExist
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package traveller
+
+import (
+	"context"
+	"github.com/dilaragorum/online-ticket-project-go/internal/ticket"
+	"testing"
+)
+
+// fakeRepository scopes every lookup to the user like the database queries do.
+type fakeRepository struct {
+	Repository
+	travellers []Traveller
+}
+
+func (r *fakeRepository) FindByID(ctx context.Context, id uint, userID uint) (*Traveller, error) {
+	for i := range r.travellers {
+		if r.travellers[i].ID == id && r.travellers[i].UserID == userID {
+			return &r.travellers[i], nil
+		}
+	}
+	return nil, ErrTravellerNotFound
+}
+
+func (r *fakeRepository) CountByUser(ctx context.Context, userID uint) (int, error) {
+	count := 0
+	for i := range r.travellers {
+		if r.travellers[i].UserID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeRepository) Create(ctx context.Context, traveller *Traveller) error {
+	traveller.ID = uint(len(r.travellers) + 1)
+	r.travellers = append(r.travellers, *traveller)
+	return nil
+}
+
+func TestService_FindPassenger(t *testing.T) {
+	service := NewService(&fakeRepository{travellers: []Traveller{
+		{ID: 1, UserID: 7, Passenger: ticket.Passenger{FullName: "Dilara Gorum", Gender: ticket.Female, BirthDate: "1990-01-01"}},
+	}})
+
+	tests := []struct {
+		name        string
+		userID      uint
+		travellerID uint
+		expectedErr error
+	}{
+		{name: "own traveller", userID: 7, travellerID: 1},
+		{name: "traveller of another user", userID: 8, travellerID: 1, expectedErr: ticket.ErrTravellerNotFound},
+		{name: "unknown traveller", userID: 7, travellerID: 2, expectedErr: ticket.ErrTravellerNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passenger, err := service.FindPassenger(context.Background(), tt.userID, tt.travellerID)
+			if err != tt.expectedErr {
+				t.Fatalf("FindPassenger() error = %v, want %v", err, tt.expectedErr)
+			}
+			if err == nil && (passenger.FullName != "Dilara Gorum" || passenger.BirthDate != "1990-01-01") {
+				t.Errorf("FindPassenger() = %+v, want the saved passenger details", passenger)
+			}
+		})
+	}
+}
+
+func TestService_Get(t *testing.T) {
+	service := NewService(&fakeRepository{travellers: []Traveller{{ID: 1, UserID: 7}}})
+
+	if _, err := service.Get(context.Background(), 1, 8); err != ErrTravellerNotExist {
+		t.Errorf("Get() of another user error = %v, want %v", err, ErrTravellerNotExist)
+	}
+	if _, err := service.Get(context.Background(), 1, 7); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+}
+
+func TestService_Create(t *testing.T) {
+	repo := &fakeRepository{}
+	for i := 0; i < MaxTravellersPerUser; i++ {
+		repo.travellers = append(repo.travellers, Traveller{ID: uint(i + 1), UserID: 7})
+	}
+	service := NewService(repo)
+
+	if err := service.Create(context.Background(), &Traveller{UserID: 7}); err != ErrExceedTravellerLimit {
+		t.Errorf("Create() error = %v, want %v", err, ErrExceedTravellerLimit)
+	}
+	if err := service.Create(context.Background(), &Traveller{UserID: 8}); err != nil {
+		t.Errorf("Create() error = %v", err)
+	}
+}
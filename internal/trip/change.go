@@ -0,0 +1,119 @@
+package trip
+
+import (
+	"fmt"
+	"time"
+)
+
+// Update holds the fields an admin may change on an existing trip. Nil fields
+// are left untouched. Price is applied to FareClass, economy when empty.
+type Update struct {
+	Date            *time.Time    `json:"date"`
+	ArrivalDuration *Duration     `json:"arrival_duration"`
+	Price           *float64      `json:"price"`
+	FareClass       FareClassName `json:"fare_class"`
+	VehicleID       *uint         `json:"vehicle_id"`
+}
+
+// Change is the audit record of a single field updated on a trip.
+type Change struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	TripID    int    `gorm:"not null;index" json:"trip_id"`
+	UserID    uint   `gorm:"not null" json:"user_id"`
+	Field     string `gorm:"not null" json:"field"`
+	OldValue  string `json:"old_value"`
+	NewValue  string `json:"new_value"`
+	CreatedAt time.Time
+}
+
+// Attendance tells how many ticket holders of a departed trip did not show up.
+type Attendance struct {
+	TripID     int     `json:"trip_id"`
+	Tickets    int     `json:"tickets"`
+	NoShows    int     `json:"no_shows"`
+	NoShowRate float64 `json:"no_show_rate"`
+}
+
+// Contact is how a ticket holder of a trip can be reached.
+type Contact struct {
+	FullName string
+	Email    string
+	Phone    string
+}
+
+func (u *Update) IsEmpty() bool {
+	return u.Date == nil && u.ArrivalDuration == nil && u.Price == nil && u.VehicleID == nil
+}
+
+func (u *Update) IsInvalidPrice() bool {
+	return u.Price != nil && *u.Price <= 0
+}
+
+// Apply changes the trip in place and returns what has been changed.
+func (u *Update) Apply(t *Trip, userID uint) ([]Change, error) {
+	changes := make([]Change, 0)
+	record := func(field string, oldValue, newValue interface{}) {
+		changes = append(changes, Change{
+			TripID:   t.ID,
+			UserID:   userID,
+			Field:    field,
+			OldValue: fmt.Sprint(oldValue),
+			NewValue: fmt.Sprint(newValue),
+		})
+	}
+
+	if u.Date != nil && !u.Date.Equal(t.Date) {
+		record("date", t.Date, u.Date.UTC())
+		t.Date = u.Date.UTC()
+	}
+
+	if u.ArrivalDuration != nil && *u.ArrivalDuration != t.ArrivalDuration {
+		record("arrival_duration", t.ArrivalDuration, *u.ArrivalDuration)
+		t.ArrivalDuration = *u.ArrivalDuration
+	}
+
+	if u.VehicleID != nil && *u.VehicleID != t.VehicleID {
+		record("vehicle_id", t.VehicleID, *u.VehicleID)
+		t.VehicleID = *u.VehicleID
+	}
+
+	if u.Price != nil {
+		fareClass, err := t.FareClass(u.FareClass)
+		if err != nil {
+			return nil, err
+		}
+
+		if fareClass.Price != *u.Price {
+			record(fmt.Sprintf("price:%s", fareClass.Name), fareClass.Price, *u.Price)
+			fareClass.Price = *u.Price
+		}
+
+		t.Price = fareClass.Price
+		for i := range t.FareClasses {
+			if t.FareClasses[i].Price < t.Price {
+				t.Price = t.FareClasses[i].Price
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// IsScheduleChanged reports whether passengers should be told about the changes.
+func IsScheduleChanged(changes []Change) bool {
+	for i := range changes {
+		if changes[i].Field == "date" || changes[i].Field == "arrival_duration" {
+			return true
+		}
+	}
+	return false
+}
+
+func IsVehicleChanged(changes []Change) bool {
+	for i := range changes {
+		if changes[i].Field == "vehicle_id" {
+			return true
+		}
+	}
+	return false
+}
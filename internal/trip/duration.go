@@ -0,0 +1,156 @@
+package trip
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDuration = errors.New("duration should be written like 5h30m")
+
+var (
+	legacyUnitDuration  = regexp.MustCompile(`^(\d+)\s*(hours?|hrs?|minutes?|mins?)$`)
+	legacyClockDuration = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+)
+
+// Duration is a time.Duration which is written as "5h30m" in JSON and in
+// the database.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) IsValid() bool {
+	return d > 0 && time.Duration(d) <= MaxTripDuration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return ErrInvalidDuration
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return ErrInvalidDuration
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan reads durations stored as text. A missing duration is read as zero.
+// Values written before durations were typed are converted by
+// MigrateDurations, so any other value is an error.
+func (d *Duration) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Duration", value)
+	}
+
+	if text == "" {
+		*d = 0
+		return nil
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidDuration, text)
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+// ParseLegacyDuration reads the free text arrival durations stored before
+// durations were typed, like "5 hours", "90 minutes", "5:30" or "5".
+func ParseLegacyDuration(text string) (Duration, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	if duration, err := time.ParseDuration(text); err == nil {
+		return Duration(duration), nil
+	}
+
+	if hours, err := strconv.Atoi(text); err == nil {
+		return Duration(time.Duration(hours) * time.Hour), nil
+	}
+
+	if match := legacyClockDuration.FindStringSubmatch(text); match != nil {
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		return Duration(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute), nil
+	}
+
+	if match := legacyUnitDuration.FindStringSubmatch(text); match != nil {
+		amount, _ := strconv.Atoi(match[1])
+		if strings.HasPrefix(match[2], "h") {
+			return Duration(time.Duration(amount) * time.Hour), nil
+		}
+		return Duration(time.Duration(amount) * time.Minute), nil
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, text)
+}
+
+// MigrateDurations rewrites the legacy arrival durations of trips in the
+// format of Duration. It fails on values it cannot read, naming the trips,
+// so they are corrected by hand instead of being read as zero.
+func MigrateDurations(db *gorm.DB) error {
+	var rows []struct {
+		ID              int
+		ArrivalDuration string
+	}
+	err := db.Unscoped().Model(&Trip{}).
+		Select("id, arrival_duration").
+		Where("arrival_duration IS NOT NULL AND arrival_duration <> ''").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	var invalid []string
+	for _, row := range rows {
+		duration, err := ParseLegacyDuration(row.ArrivalDuration)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("trip %d: %q", row.ID, row.ArrivalDuration))
+			continue
+		}
+		if duration.String() == row.ArrivalDuration {
+			continue
+		}
+
+		err = db.Unscoped().Model(&Trip{}).Where("id = ?", row.ID).UpdateColumn("arrival_duration", duration.String()).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidDuration, strings.Join(invalid, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package trip
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLegacyDuration(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Duration
+		wantErr  bool
+	}{
+		{text: "5h30m", expected: Duration(5*time.Hour + 30*time.Minute)},
+		{text: "5 hours", expected: Duration(5 * time.Hour)},
+		{text: "1 hour", expected: Duration(time.Hour)},
+		{text: "90 minutes", expected: Duration(90 * time.Minute)},
+		{text: "5:30", expected: Duration(5*time.Hour + 30*time.Minute)},
+		{text: " 5 ", expected: Duration(5 * time.Hour)},
+		{text: "about five hours", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseLegacyDuration(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLegacyDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseLegacyDuration() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDuration_Scan(t *testing.T) {
+	var d Duration
+	if err := d.Scan("5h0m0s"); err != nil || d != Duration(5*time.Hour) {
+		t.Errorf("Scan() = %v, %v, want %v", d, err, Duration(5*time.Hour))
+	}
+	if err := d.Scan("5 hours"); !errors.Is(err, ErrInvalidDuration) {
+		t.Errorf("Scan() error = %v, want %v", err, ErrInvalidDuration)
+	}
+}
@@ -0,0 +1,238 @@
+package trip
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+type FareClassName string
+
+const (
+	Economy  FareClassName = "economy"
+	Business FareClassName = "business"
+	First    FareClassName = "first"
+)
+
+// wagonFareClasses tells in which fare class the seats of a train wagon are sold.
+var wagonFareClasses = map[fleet.WagonClass]FareClassName{
+	fleet.SecondClass: Economy,
+	fleet.Sleeper:     Business,
+	fleet.FirstClass:  First,
+}
+
+// FareClassOf returns the fare class a seat is sold in. A seat without a
+// class, as on buses, flights and ferries, can be sold in any fare class.
+func FareClassOf(seat fleet.Seat) (FareClassName, bool) {
+	name, ok := wagonFareClasses[seat.Class]
+	return name, ok
+}
+
+var (
+	ErrFareClassNotFound = errors.New("this fare class is not sold on the trip")
+	ErrInvalidFareClass  = errors.New("fare class is invalid")
+	ErrFareClassCapacity = errors.New("capacity of fare classes does not match the vehicle")
+)
+
+// FareClass is a cabin of a trip with its own seats, price and rules.
+// RefundFeeRate is the share of the price kept when a ticket is refunded,
+// ChangeFee is the fixed amount charged when a ticket is exchanged and
+// NoShowCreditRate is the share of the price credited back when the
+// passenger does not show up, zero meaning the ticket is forfeited.
+// Refundable and Changeable have no column default, since gorm would store
+// the default in place of false.
+type FareClass struct {
+	ID               int           `gorm:"primaryKey" json:"id"`
+	TripID           int           `gorm:"not null;uniqueIndex:idx_trip_fare_class" json:"trip_id"`
+	Name             FareClassName `gorm:"not null;uniqueIndex:idx_trip_fare_class" json:"name"`
+	Capacity         uint          `gorm:"not null;check:capacity>0" json:"capacity"`
+	AvailableSeat    uint          `gorm:"not null;check:available_seat>=0" json:"available_seat"`
+	Price            float64       `gorm:"not null;check:price>0" json:"price"`
+	Refundable       bool          `gorm:"not null" json:"refundable"`
+	RefundFeeRate    float64       `gorm:"not null;default:0" json:"refund_fee_rate"`
+	Changeable       bool          `gorm:"not null" json:"changeable"`
+	ChangeFee        float64       `gorm:"not null;default:0" json:"change_fee"`
+	NoShowCreditRate float64       `gorm:"not null;default:0" json:"no_show_credit_rate"`
+	CreatedAt        time.Time     `json:"-"`
+	UpdatedAt        time.Time     `json:"-"`
+}
+
+// UnmarshalJSON makes a fare class refundable and changeable unless the
+// request says otherwise.
+func (f *FareClass) UnmarshalJSON(data []byte) error {
+	type plain FareClass
+	fareClass := plain{Refundable: true, Changeable: true}
+	if err := json.Unmarshal(data, &fareClass); err != nil {
+		return err
+	}
+
+	*f = FareClass(fareClass)
+	return nil
+}
+
+func (f *FareClass) BeforeCreate(tx *gorm.DB) error {
+	f.AvailableSeat = f.Capacity
+
+	return nil
+}
+
+func (f *FareClass) CheckAvailableSeat(numberOfTickets int) bool {
+	return f.AvailableSeat >= uint(numberOfTickets)
+}
+
+// RefundAmount is the part of the paid price given back on a refund.
+func (f *FareClass) RefundAmount(paid float64) float64 {
+	if !f.Refundable {
+		return 0
+	}
+	return paid * (1 - f.RefundFeeRate)
+}
+
+// NoShowCredit is the part of the paid price credited back for a no-show.
+func (f *FareClass) NoShowCredit(paid float64) float64 {
+	return paid * f.NoShowCreditRate
+}
+
+func (f *FareClass) IsValid() bool {
+	switch f.Name {
+	case Economy, Business, First:
+	default:
+		return false
+	}
+
+	return f.Capacity > 0 && f.Price > 0 && f.RefundFeeRate >= 0 && f.RefundFeeRate <= 1 && f.ChangeFee >= 0 &&
+		f.NoShowCreditRate >= 0 && f.NoShowCreditRate <= 1
+}
+
+// FareClass finds the cabin of the trip. An empty name stands for economy.
+func (t *Trip) FareClass(name FareClassName) (*FareClass, error) {
+	if name == "" {
+		name = Economy
+	}
+
+	for i := range t.FareClasses {
+		if t.FareClasses[i].Name == name {
+			return &t.FareClasses[i], nil
+		}
+	}
+
+	return nil, ErrFareClassNotFound
+}
+
+// PrepareFareClasses splits the capacity of the trip into its fare classes.
+// A trip created without fare classes sells every seat as economy at its price.
+// When the seats of the vehicle are split into wagon classes, each fare class
+// should have the seats of its wagon class, and by default every wagon class
+// is sold at the price of the trip.
+func (t *Trip) PrepareFareClasses(classes map[fleet.WagonClass]uint) error {
+	if len(t.FareClasses) == 0 && len(classes) > 0 {
+		for class, capacity := range classes {
+			t.FareClasses = append(t.FareClasses, FareClass{Name: wagonFareClasses[class], Capacity: capacity, Price: t.Price, Refundable: true, Changeable: true})
+		}
+		sort.Slice(t.FareClasses, func(i, j int) bool { return t.FareClasses[i].Name < t.FareClasses[j].Name })
+		return nil
+	}
+
+	if len(t.FareClasses) == 0 {
+		t.FareClasses = []FareClass{{Name: Economy, Capacity: t.Capacity, Price: t.Price, Refundable: true, Changeable: true}}
+		return nil
+	}
+
+	capacities := make(map[FareClassName]uint, len(classes))
+	for class, capacity := range classes {
+		capacities[wagonFareClasses[class]] = capacity
+	}
+
+	var capacity uint
+	names := make(map[FareClassName]bool, len(t.FareClasses))
+	cheapest := t.FareClasses[0].Price
+
+	for i := range t.FareClasses {
+		fareClass := t.FareClasses[i]
+		if !fareClass.IsValid() || names[fareClass.Name] {
+			return ErrInvalidFareClass
+		}
+		names[fareClass.Name] = true
+
+		if len(capacities) > 0 && capacities[fareClass.Name] != fareClass.Capacity {
+			return ErrFareClassCapacity
+		}
+
+		capacity += fareClass.Capacity
+		if fareClass.Price < cheapest {
+			cheapest = fareClass.Price
+		}
+	}
+
+	if capacity != t.Capacity {
+		return ErrFareClassCapacity
+	}
+
+	t.Price = cheapest
+
+	return nil
+}
+
+// ResizeFareClasses fits the fare classes of the trip to the seats of another
+// vehicle, keeping the seats sold in each of them. When the seats of the
+// vehicle are split into wagon classes, each fare class gets the seats of its
+// wagon class. Otherwise the cheapest fare class absorbs the difference in
+// capacity.
+func (t *Trip) ResizeFareClasses(capacity uint, classes map[fleet.WagonClass]uint) error {
+	if len(t.FareClasses) == 0 {
+		return nil
+	}
+
+	if len(classes) == 0 {
+		var total uint
+		cheapest := &t.FareClasses[0]
+		for i := range t.FareClasses {
+			total += t.FareClasses[i].Capacity
+			if t.FareClasses[i].Price < cheapest.Price {
+				cheapest = &t.FareClasses[i]
+			}
+		}
+
+		resized := int(cheapest.Capacity) + int(capacity) - int(total)
+		if resized < int(cheapest.Capacity-cheapest.AvailableSeat) {
+			return ErrSoldSeat
+		}
+		if resized == 0 {
+			return ErrFareClassCapacity
+		}
+
+		cheapest.AvailableSeat = uint(resized) - (cheapest.Capacity - cheapest.AvailableSeat)
+		cheapest.Capacity = uint(resized)
+		return nil
+	}
+
+	capacities := make(map[FareClassName]uint, len(classes))
+	for class, classCapacity := range classes {
+		capacities[wagonFareClasses[class]] = classCapacity
+	}
+	if len(capacities) != len(t.FareClasses) {
+		return ErrFareClassCapacity
+	}
+
+	for i := range t.FareClasses {
+		fareClass := &t.FareClasses[i]
+
+		classCapacity, ok := capacities[fareClass.Name]
+		if !ok {
+			return ErrFareClassCapacity
+		}
+
+		sold := fareClass.Capacity - fareClass.AvailableSeat
+		if sold > classCapacity {
+			return ErrSoldSeat
+		}
+
+		fareClass.Capacity = classCapacity
+		fareClass.AvailableSeat = classCapacity - sold
+	}
+
+	return nil
+}
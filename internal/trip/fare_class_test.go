@@ -0,0 +1,183 @@
+package trip
+
+import (
+	"encoding/json"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestTrip_PrepareFareClasses(t *testing.T) {
+	tests := []struct {
+		name          string
+		trip          Trip
+		classes       map[fleet.WagonClass]uint
+		expectedErr   error
+		expectedPrice float64
+	}{
+		{
+			name:          "default economy class",
+			trip:          Trip{Capacity: 45, Price: 300},
+			expectedPrice: 300,
+		},
+		{
+			name: "economy and business",
+			trip: Trip{Capacity: 189, FareClasses: []FareClass{
+				{Name: Business, Capacity: 24, Price: 2500},
+				{Name: Economy, Capacity: 165, Price: 900},
+			}},
+			expectedPrice: 900,
+		},
+		{
+			name: "capacity does not match",
+			trip: Trip{Capacity: 189, Price: 900, FareClasses: []FareClass{
+				{Name: Economy, Capacity: 100, Price: 900},
+			}},
+			expectedErr:   ErrFareClassCapacity,
+			expectedPrice: 900,
+		},
+		{
+			name: "duplicated class",
+			trip: Trip{Capacity: 45, Price: 300, FareClasses: []FareClass{
+				{Name: Economy, Capacity: 20, Price: 300},
+				{Name: Economy, Capacity: 25, Price: 300},
+			}},
+			expectedErr:   ErrInvalidFareClass,
+			expectedPrice: 300,
+		},
+		{
+			name:          "default classes of wagons",
+			trip:          Trip{Capacity: 100, Price: 400},
+			classes:       map[fleet.WagonClass]uint{fleet.FirstClass: 20, fleet.SecondClass: 80},
+			expectedPrice: 400,
+		},
+		{
+			name: "fare class does not match its wagons",
+			trip: Trip{Capacity: 100, Price: 400, FareClasses: []FareClass{
+				{Name: First, Capacity: 30, Price: 900},
+				{Name: Economy, Capacity: 70, Price: 400},
+			}},
+			classes:       map[fleet.WagonClass]uint{fleet.FirstClass: 20, fleet.SecondClass: 80},
+			expectedErr:   ErrFareClassCapacity,
+			expectedPrice: 400,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.trip.PrepareFareClasses(tt.classes); err != tt.expectedErr {
+				t.Fatalf("PrepareFareClasses() = %v, want %v", err, tt.expectedErr)
+			}
+			if tt.trip.Price != tt.expectedPrice {
+				t.Errorf("PrepareFareClasses() price = %v, want %v", tt.trip.Price, tt.expectedPrice)
+			}
+		})
+	}
+}
+
+func TestTrip_ResizeFareClasses(t *testing.T) {
+	train := func() []FareClass {
+		return []FareClass{
+			{Name: Economy, Capacity: 40, AvailableSeat: 30, Price: 100},
+			{Name: First, Capacity: 10, AvailableSeat: 4, Price: 300},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		fareClasses []FareClass
+		capacity    uint
+		classes     map[fleet.WagonClass]uint
+		expectedErr error
+		expected    map[FareClassName][2]uint
+	}{
+		{
+			name:        "cheapest class absorbs the difference",
+			fareClasses: train(),
+			capacity:    60,
+			expected:    map[FareClassName][2]uint{Economy: {50, 40}, First: {10, 4}},
+		},
+		{
+			name:        "cheapest class cannot lose sold seats",
+			fareClasses: train(),
+			capacity:    15,
+			expectedErr: ErrSoldSeat,
+		},
+		{
+			name:        "classes of the wagons",
+			fareClasses: train(),
+			capacity:    50,
+			classes:     map[fleet.WagonClass]uint{fleet.SecondClass: 30, fleet.FirstClass: 20},
+			expected:    map[FareClassName][2]uint{Economy: {30, 20}, First: {20, 14}},
+		},
+		{
+			name:        "class has fewer seats than sold",
+			fareClasses: train(),
+			capacity:    50,
+			classes:     map[fleet.WagonClass]uint{fleet.SecondClass: 45, fleet.FirstClass: 5},
+			expectedErr: ErrSoldSeat,
+		},
+		{
+			name:        "wagon class the trip does not sell",
+			fareClasses: train(),
+			capacity:    50,
+			classes:     map[fleet.WagonClass]uint{fleet.SecondClass: 30, fleet.Sleeper: 20},
+			expectedErr: ErrFareClassCapacity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trip := Trip{FareClasses: tt.fareClasses}
+			if err := trip.ResizeFareClasses(tt.capacity, tt.classes); err != tt.expectedErr {
+				t.Fatalf("ResizeFareClasses() error = %v, want %v", err, tt.expectedErr)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+			for _, fareClass := range trip.FareClasses {
+				if got := [2]uint{fareClass.Capacity, fareClass.AvailableSeat}; got != tt.expected[fareClass.Name] {
+					t.Errorf("%s capacity and available seats = %v, want %v", fareClass.Name, got, tt.expected[fareClass.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestFareClass_Create(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		request  string
+		expected bool
+	}{
+		{name: "rules not given", request: `{"name": "economy", "capacity": 10, "price": 100}`, expected: true},
+		{name: "refundable and changeable", request: `{"name": "economy", "capacity": 10, "price": 100, "refundable": true, "changeable": true}`, expected: true},
+		{name: "neither refundable nor changeable", request: `{"name": "economy", "capacity": 10, "price": 100, "refundable": false, "changeable": false}`, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fareClass FareClass
+			if err := json.Unmarshal([]byte(tt.request), &fareClass); err != nil {
+				t.Fatal(err)
+			}
+
+			statement := db.Create(&fareClass).Statement
+			sql := statement.SQL.String()
+			columns := strings.Split(sql[strings.Index(sql, "(")+1:strings.Index(sql, ")")], ",")
+
+			for i, column := range columns {
+				if column != `"refundable"` && column != `"changeable"` {
+					continue
+				}
+				if statement.Vars[i] != tt.expected {
+					t.Errorf("stored %s = %v, want %v", column, statement.Vars[i], tt.expected)
+				}
+			}
+		})
+	}
+}
@@ -13,29 +13,53 @@ const (
 	WarnNoTripMeetConditions = "There is no trip which meet your conditions."
 	WarnInternalError        = "Somethings go wrong. Please try later again"
 
+	WarnMessageWhenInvalidLayover = "Minimum layover cannot be longer than maximum layover"
+
 	WarnAlreadyCreatedTrip            = "This trip is already created. Please create another trip."
 	WarnMessageWhenThereAreEmptyBlank = "Please fill required area"
 	WarnMessageWhenInvalidVehicle     = "Please enter valid Vehicle Type"
 	WarnMessageWhenInvalidPrice       = "Please enter valid price"
 
+	WarnMessageWhenInvalidArrival        = "Please enter valid arrival duration, e.g. 5h30m"
+	WarnMessageWhenTripInPast            = "Trip cannot depart in the past"
+	WarnMessageWhenInvalidLocalDate      = "Please enter valid local date, e.g. 2023-03-01 08:30"
+	WarnMessageWhenStationNotFound       = "This station does not exist. Please create the station first."
+	WarnMessageWhenInvalidTimeZone       = "Please enter valid time zone, e.g. Europe/Istanbul"
+	WarnMessageWhenDuplicateStation      = "This station is already created"
+	WarnMessageWhenVehicleNotExist       = "This vehicle does not exist. Please check fleet information."
+	WarnMessageWhenVehicleTypeMismatch   = "Vehicle type of the assigned vehicle does not match the trip"
+	WarnMessageWhenVehicleUnavailable    = "This vehicle is already assigned to another trip at the same time"
+	WarnMessageWhenSoldSeatExceeded      = "Capacity of this vehicle is less than the sold seats of the trip"
+	WarnMessageWhenInvalidFareClass      = "Please enter valid and unique fare classes with capacity and price"
+	WarnMessageWhenFareClassCapacity     = "Total capacity of fare classes should be equal to the capacity of the vehicle"
+	WarnMessageWhenNothingToUpdate       = "Please enter at least one field to update"
+	WarnMessageWhenFareClassNotFound     = "This fare class is not sold on the trip"
 	WarnMessageWhenInvalidID             = "Please enter valid ID"
 	WarnMessageWhenTripNotExistForDelete = "This trip does not exist or it is deleted already. "
+	WarnMessageWhenTripNotExist          = "This trip does not exist"
 )
 
 type handler struct {
 	tripService Service
 }
 
-func Handler(e *echo.Echo, tripService Service) *handler {
+func Handler(routes *auth.Routes, tripService Service) *handler {
 	h := handler{
 		tripService: tripService,
 	}
 
-	e.GET("/trips", h.FilterTrips)
-	e.POST("/trips", h.CreateTrip, auth.AdminMiddleware)
-	e.DELETE("/trips/:id", h.CancelTrip, auth.AdminMiddleware)
-	e.GET("/trips/sold/:id", h.GetSoldTicketNumber, auth.AdminMiddleware)
-	e.GET("/trips/revenue/:id", h.GetTotalRevenueForSpecificTrip, auth.AdminMiddleware)
+	routes.GET("/trips", h.FilterTrips, auth.Public)
+	routes.GET("/itineraries", h.SearchItineraries, auth.Public)
+	routes.POST("/trips", h.CreateTrip, auth.RequirePermission(auth.TripCreate))
+	routes.DELETE("/trips/:id", h.CancelTrip, auth.RequirePermission(auth.TripCancel))
+	routes.PATCH("/trips/:id", h.UpdateTrip, auth.RequirePermission(auth.TripUpdate))
+	routes.PUT("/trips/:id/vehicle", h.AssignVehicle, auth.RequirePermission(auth.TripUpdate))
+	routes.GET("/trips/:id/changes", h.GetChanges, auth.RequirePermission(auth.TripHistory))
+	routes.GET("/trips/sold/:id", h.GetSoldTicketNumber, auth.RequirePermission(auth.ReportSales))
+	routes.GET("/trips/revenue/:id", h.GetTotalRevenueForSpecificTrip, auth.RequirePermission(auth.ReportRevenue))
+	routes.GET("/trips/no-show/:id", h.GetAttendance, auth.RequirePermission(auth.ReportAttendance))
+	routes.POST("/stations", h.CreateStation, auth.RequirePermission(auth.StationCreate))
+	routes.GET("/stations", h.ListStations, auth.Public)
 
 	return &h
 }
@@ -57,6 +81,31 @@ func (t *handler) FilterTrips(c echo.Context) error {
 	return c.JSON(http.StatusOK, trips)
 }
 
+func (t *handler) SearchItineraries(c echo.Context) error {
+	filter := ItineraryFilter{}
+	if err := c.Bind(&filter); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if filter.CheckFieldsEmpty() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenThereAreEmptyBlank)
+	}
+
+	if filter.IsInvalidLayover() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidLayover)
+	}
+
+	itineraries, err := t.tripService.SearchItineraries(c.Request().Context(), &filter)
+	if err != nil {
+		if errors.Is(err, user.ErrThereIsNoTrip) {
+			return c.String(http.StatusBadRequest, WarnNoTripMeetConditions)
+		}
+		return c.String(http.StatusInternalServerError, user.WarnInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, itineraries)
+}
+
 func (t *handler) CreateTrip(c echo.Context) error {
 	trip := new(Trip)
 	if err := c.Bind(&trip); err != nil {
@@ -75,13 +124,17 @@ func (t *handler) CreateTrip(c echo.Context) error {
 		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidPrice)
 	}
 
+	if _, err := trip.ArrivalTime(); err != nil {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidArrival)
+	}
+
 	requestCtx := c.Request().Context()
 
 	if err := t.tripService.CreateTrip(requestCtx, trip); err != nil {
 		if errors.Is(err, ErrAlreadyCreatedTrip) {
 			return c.String(http.StatusBadRequest, WarnAlreadyCreatedTrip)
 		}
-		return c.String(http.StatusInternalServerError, WarnInternalError)
+		return vehicleError(c, err)
 	}
 
 	return c.NoContent(http.StatusCreated)
@@ -108,6 +161,152 @@ func (t *handler) CancelTrip(c echo.Context) error {
 	return c.NoContent(http.StatusNoContent)
 }
 
+func (t *handler) UpdateTrip(c echo.Context) error {
+	claim, _ := c.Get("claim").(auth.Claims)
+
+	tripID, _ := strconv.Atoi(c.Param("id"))
+	if IsInvalidID(tripID) {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidID)
+	}
+
+	update := new(Update)
+	if err := c.Bind(update); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if update.IsEmpty() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenNothingToUpdate)
+	}
+
+	if update.IsInvalidPrice() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidPrice)
+	}
+
+	if update.Date != nil && update.Date.IsZero() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenThereAreEmptyBlank)
+	}
+
+	trip, err := t.tripService.UpdateTrip(c.Request().Context(), tripID, update, claim.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTripNotExist), errors.Is(err, ErrTripNotFound):
+			return c.String(http.StatusBadRequest, WarnMessageWhenTripNotExistForDelete)
+		case errors.Is(err, ErrAlreadyCreatedTrip):
+			return c.String(http.StatusBadRequest, WarnAlreadyCreatedTrip)
+		case errors.Is(err, ErrFareClassNotFound):
+			return c.String(http.StatusBadRequest, WarnMessageWhenFareClassNotFound)
+		}
+		return vehicleError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, trip)
+}
+
+func (t *handler) GetChanges(c echo.Context) error {
+	tripID, _ := strconv.Atoi(c.Param("id"))
+	if IsInvalidID(tripID) {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidID)
+	}
+
+	changes, err := t.tripService.GetChanges(c.Request().Context(), tripID)
+	if err != nil {
+		if errors.Is(err, ErrTripNotExist) {
+			return c.String(http.StatusBadRequest, WarnMessageWhenTripNotExistForDelete)
+		}
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.JSON(http.StatusOK, changes)
+}
+
+func (t *handler) AssignVehicle(c echo.Context) error {
+	tripID, _ := strconv.Atoi(c.Param("id"))
+	if IsInvalidID(tripID) {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidID)
+	}
+
+	var body struct {
+		VehicleID uint `json:"vehicle_id"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if body.VehicleID == 0 {
+		return c.String(http.StatusBadRequest, WarnMessageWhenThereAreEmptyBlank)
+	}
+
+	if err := t.tripService.AssignVehicle(c.Request().Context(), tripID, body.VehicleID); err != nil {
+		if errors.Is(err, ErrTripNotExist) || errors.Is(err, ErrTripNotFound) {
+			return c.String(http.StatusBadRequest, WarnMessageWhenTripNotExistForDelete)
+		}
+		return vehicleError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func vehicleError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrVehicleNotExist):
+		return c.String(http.StatusBadRequest, WarnMessageWhenVehicleNotExist)
+	case errors.Is(err, ErrVehicleTypeMismatch):
+		return c.String(http.StatusBadRequest, WarnMessageWhenVehicleTypeMismatch)
+	case errors.Is(err, ErrVehicleUnavailable):
+		return c.String(http.StatusConflict, WarnMessageWhenVehicleUnavailable)
+	case errors.Is(err, ErrSoldSeat):
+		return c.String(http.StatusBadRequest, WarnMessageWhenSoldSeatExceeded)
+	case errors.Is(err, ErrInvalidFareClass):
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidFareClass)
+	case errors.Is(err, ErrFareClassCapacity):
+		return c.String(http.StatusBadRequest, WarnMessageWhenFareClassCapacity)
+	case errors.Is(err, ErrTripInPast):
+		return c.String(http.StatusBadRequest, WarnMessageWhenTripInPast)
+	case errors.Is(err, ErrInvalidLocalDate):
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidLocalDate)
+	case errors.Is(err, ErrStationNotFound):
+		return c.String(http.StatusBadRequest, WarnMessageWhenStationNotFound)
+	case errors.Is(err, ErrInvalidTimeZone):
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidTimeZone)
+	case errors.Is(err, ErrInvalidArrivalDuration):
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidArrival)
+	default:
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+}
+
+func (t *handler) CreateStation(c echo.Context) error {
+	station := new(Station)
+	if err := c.Bind(station); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if station.CheckFieldsEmpty() {
+		return c.String(http.StatusBadRequest, WarnMessageWhenThereAreEmptyBlank)
+	}
+
+	if err := t.tripService.CreateStation(c.Request().Context(), station); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidTimeZone):
+			return c.String(http.StatusBadRequest, WarnMessageWhenInvalidTimeZone)
+		case errors.Is(err, ErrDuplicateStation):
+			return c.String(http.StatusBadRequest, WarnMessageWhenDuplicateStation)
+		}
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.JSON(http.StatusCreated, station)
+}
+
+func (t *handler) ListStations(c echo.Context) error {
+	stations, err := t.tripService.ListStations(c.Request().Context())
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.JSON(http.StatusOK, stations)
+}
+
 func (t *handler) GetSoldTicketNumber(c echo.Context) error {
 	p := c.Param("id")
 	id, err := strconv.Atoi(p)
@@ -137,3 +336,21 @@ func (t *handler) GetTotalRevenueForSpecificTrip(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, revenue)
 }
+
+func (t *handler) GetAttendance(c echo.Context) error {
+	p := c.Param("id")
+	id, err := strconv.Atoi(p)
+	if err != nil {
+		return c.String(http.StatusBadRequest, WarnMessageWhenInvalidID)
+	}
+
+	attendance, err := t.tripService.GetAttendance(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return c.String(http.StatusNotFound, WarnMessageWhenTripNotExist)
+		}
+		return c.String(http.StatusInternalServerError, WarnInternalError)
+	}
+
+	return c.JSON(http.StatusOK, attendance)
+}
@@ -0,0 +1,187 @@
+package trip
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultMinLayover = 30 * time.Minute
+	DefaultMaxLayover = 12 * time.Hour
+
+	MaxItineraries = 50
+)
+
+var (
+	ErrDisconnectedLegs = errors.New("legs of the journey are not connected")
+	ErrLayoverTooShort  = errors.New("layover between legs is shorter than allowed")
+	ErrEmptyJourney     = errors.New("journey should have at least one leg")
+	ErrReturnBeforeTrip = errors.New("return journey departs before outbound journey arrives")
+)
+
+type ItineraryFilter struct {
+	From              string    `json:"from"`
+	To                string    `json:"to"`
+	Via               string    `json:"via"`
+	Vehicle           Vehicle   `json:"vehicle"`
+	Date              time.Time `json:"date"`
+	ReturnDate        time.Time `json:"return_date"`
+	MinLayoverMinutes int       `json:"min_layover_minutes"`
+	MaxLayoverMinutes int       `json:"max_layover_minutes"`
+}
+
+// Journey is a chain of trips which takes the passenger from the departure
+// of the first leg to the arrival of the last leg.
+type Journey struct {
+	Legs        []Trip    `json:"legs"`
+	DepartureAt time.Time `json:"departure_at"`
+	ArrivalAt   time.Time `json:"arrival_at"`
+	Price       float64   `json:"price"`
+}
+
+type Itinerary struct {
+	Outbound   Journey  `json:"outbound"`
+	Return     *Journey `json:"return,omitempty"`
+	TotalPrice float64  `json:"total_price"`
+}
+
+func (f *ItineraryFilter) CheckFieldsEmpty() bool {
+	return f.From == "" || f.To == "" || f.Date.IsZero()
+}
+
+func (f *ItineraryFilter) IsRoundTrip() bool {
+	return !f.ReturnDate.IsZero()
+}
+
+func (f *ItineraryFilter) MinLayover() time.Duration {
+	if f.MinLayoverMinutes <= 0 {
+		return DefaultMinLayover
+	}
+	return time.Duration(f.MinLayoverMinutes) * time.Minute
+}
+
+func (f *ItineraryFilter) MaxLayover() time.Duration {
+	if f.MaxLayoverMinutes <= 0 {
+		return DefaultMaxLayover
+	}
+	return time.Duration(f.MaxLayoverMinutes) * time.Minute
+}
+
+func (f *ItineraryFilter) IsInvalidLayover() bool {
+	return f.MinLayover() > f.MaxLayover()
+}
+
+// NewJourney validates that every leg departs from where the previous one
+// arrives, leaving at least minLayover to change vehicles.
+func NewJourney(legs []Trip, minLayover time.Duration) (Journey, error) {
+	if len(legs) == 0 {
+		return Journey{}, ErrEmptyJourney
+	}
+
+	journey := Journey{Legs: legs, DepartureAt: legs[0].Date}
+
+	for i := range legs {
+		arrivalAt, err := legs[i].ArrivalTime()
+		if err != nil {
+			return Journey{}, err
+		}
+
+		if i > 0 {
+			if legs[i-1].To != legs[i].From {
+				return Journey{}, ErrDisconnectedLegs
+			}
+			if legs[i].Date.Sub(journey.ArrivalAt) < minLayover {
+				return Journey{}, ErrLayoverTooShort
+			}
+		}
+
+		journey.ArrivalAt = arrivalAt
+		journey.Price += legs[i].Price
+	}
+
+	return journey, nil
+}
+
+// NewItinerary pairs an outbound journey with an optional return journey.
+func NewItinerary(outbound Journey, ret *Journey) (Itinerary, error) {
+	itinerary := Itinerary{Outbound: outbound, TotalPrice: outbound.Price}
+	if ret == nil {
+		return itinerary, nil
+	}
+
+	if ret.DepartureAt.Before(outbound.ArrivalAt) {
+		return Itinerary{}, ErrReturnBeforeTrip
+	}
+
+	itinerary.Return = ret
+	itinerary.TotalPrice += ret.Price
+
+	return itinerary, nil
+}
+
+// connect builds two-leg journeys out of first legs and the trips departing
+// from their destination within the allowed layover window.
+func connect(firstLegs, secondLegs []Trip, to string, minLayover, maxLayover time.Duration) []Journey {
+	journeys := make([]Journey, 0)
+
+	for i := range firstLegs {
+		arrivalAt, err := firstLegs[i].ArrivalTime()
+		if err != nil {
+			continue
+		}
+
+		for k := range secondLegs {
+			second := secondLegs[k]
+			if second.From != firstLegs[i].To || second.To != to {
+				continue
+			}
+
+			layover := second.Date.Sub(arrivalAt)
+			if layover < minLayover || layover > maxLayover {
+				continue
+			}
+
+			journey, err := NewJourney([]Trip{firstLegs[i], second}, minLayover)
+			if err != nil {
+				continue
+			}
+			journeys = append(journeys, journey)
+		}
+	}
+
+	return journeys
+}
+
+// combine pairs every outbound journey with every return journey departing
+// after it arrives, cheapest first.
+func combine(outbounds, returns []Journey, roundTrip bool) []Itinerary {
+	itineraries := make([]Itinerary, 0)
+
+	for i := range outbounds {
+		if !roundTrip {
+			itinerary, _ := NewItinerary(outbounds[i], nil)
+			itineraries = append(itineraries, itinerary)
+			continue
+		}
+
+		for k := range returns {
+			ret := returns[k]
+			itinerary, err := NewItinerary(outbounds[i], &ret)
+			if err != nil {
+				continue
+			}
+			itineraries = append(itineraries, itinerary)
+		}
+	}
+
+	sort.SliceStable(itineraries, func(i, j int) bool {
+		return itineraries[i].TotalPrice < itineraries[j].TotalPrice
+	})
+
+	if len(itineraries) > MaxItineraries {
+		itineraries = itineraries[:MaxItineraries]
+	}
+
+	return itineraries
+}
@@ -0,0 +1,75 @@
+package trip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewJourney(t *testing.T) {
+	departure := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	istanbulAnkara := Trip{ID: 1, From: "Istanbul", To: "Ankara", Date: departure, ArrivalDuration: Duration(5 * time.Hour), Price: 100}
+	ankaraIzmir := Trip{ID: 2, From: "Ankara", To: "Izmir", Date: departure.Add(6 * time.Hour), ArrivalDuration: Duration(7 * time.Hour), Price: 150}
+	ankaraIzmirEarly := Trip{ID: 3, From: "Ankara", To: "Izmir", Date: departure.Add(5*time.Hour + 10*time.Minute), ArrivalDuration: Duration(7 * time.Hour), Price: 150}
+	izmirAntalya := Trip{ID: 4, From: "Izmir", To: "Antalya", Date: departure.Add(6 * time.Hour), ArrivalDuration: Duration(6 * time.Hour), Price: 90}
+
+	tests := []struct {
+		name          string
+		legs          []Trip
+		expectedErr   error
+		expectedPrice float64
+	}{
+		{
+			name:          "connected legs",
+			legs:          []Trip{istanbulAnkara, ankaraIzmir},
+			expectedPrice: 250,
+		},
+		{
+			name:        "layover too short",
+			legs:        []Trip{istanbulAnkara, ankaraIzmirEarly},
+			expectedErr: ErrLayoverTooShort,
+		},
+		{
+			name:        "disconnected legs",
+			legs:        []Trip{istanbulAnkara, izmirAntalya},
+			expectedErr: ErrDisconnectedLegs,
+		},
+		{
+			name:        "no legs",
+			expectedErr: ErrEmptyJourney,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journey, err := NewJourney(tt.legs, DefaultMinLayover)
+			if err != tt.expectedErr {
+				t.Fatalf("NewJourney() error = %v, want %v", err, tt.expectedErr)
+			}
+			if journey.Price != tt.expectedPrice {
+				t.Errorf("NewJourney() price = %v, want %v", journey.Price, tt.expectedPrice)
+			}
+		})
+	}
+}
+
+func TestConnect(t *testing.T) {
+	departure := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	firstLegs := []Trip{
+		{ID: 1, From: "Istanbul", To: "Ankara", Date: departure, ArrivalDuration: Duration(5 * time.Hour), Price: 100},
+	}
+	secondLegs := []Trip{
+		{ID: 2, From: "Ankara", To: "Izmir", Date: departure.Add(6 * time.Hour), ArrivalDuration: Duration(7 * time.Hour), Price: 150},
+		{ID: 3, From: "Ankara", To: "Izmir", Date: departure.Add(5*time.Hour + 10*time.Minute), ArrivalDuration: Duration(7 * time.Hour), Price: 120},
+		{ID: 4, From: "Ankara", To: "Izmir", Date: departure.Add(20 * time.Hour), ArrivalDuration: Duration(7 * time.Hour), Price: 80},
+		{ID: 5, From: "Ankara", To: "Antalya", Date: departure.Add(6 * time.Hour), ArrivalDuration: Duration(7 * time.Hour), Price: 80},
+	}
+
+	journeys := connect(firstLegs, secondLegs, "Izmir", DefaultMinLayover, DefaultMaxLayover)
+	if len(journeys) != 1 {
+		t.Fatalf("connect() returned %d journeys, want 1", len(journeys))
+	}
+	if journeys[0].Legs[1].ID != 2 {
+		t.Errorf("connect() second leg = %d, want 2", journeys[0].Legs[1].ID)
+	}
+}
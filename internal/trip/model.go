@@ -1,42 +1,75 @@
 package trip
 
 import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
 	"gorm.io/gorm"
 	"time"
 )
 
-type Vehicle string
+type Vehicle = fleet.Type
 
 const (
-	VehicleBus    Vehicle = "Bus"
-	VehicleFlight Vehicle = "Flight"
+	VehicleBus    = fleet.Bus
+	VehicleFlight = fleet.Flight
+	VehicleTrain  = fleet.Train
+	VehicleFerry  = fleet.Ferry
+
+	// MaxTripDuration bounds how far back to look for trips of a vehicle
+	// which may still be on the road when a new trip departs.
+	MaxTripDuration = 48 * time.Hour
+)
+
+const (
+	LocalDateLayout = "2006-01-02 15:04"
 
-	CapacityOfBus    = 45
-	CapacityOfFlight = 189
-	DefaultCapacity  = 0
+	SortByDeparture = "departure"
+	SortByArrival   = "arrival"
+	SortByDuration  = "duration"
+	SortByPrice     = "price"
 )
 
+var (
+	ErrInvalidArrivalDuration = errors.New("arrival duration is invalid")
+	ErrTripInPast             = errors.New("trip cannot depart in the past")
+	ErrInvalidLocalDate       = errors.New("local date should be written like 2006-01-02 15:04")
+)
+
+// Filter finds trips departing on the calendar day of Date, in the time zone
+// Date is given in.
 type Filter struct {
 	TripID  int       `json:"trip_id"`
 	From    string    `json:"from"`
 	To      string    `json:"to"`
 	Vehicle Vehicle   `json:"vehicle"`
 	Date    time.Time `json:"date"`
+	SortBy  string    `json:"sort_by"`
 }
 
 type Trip struct {
-	ID              int       `gorm:"primaryKey" json:"id"`
-	From            string    `gorm:"not null;index:,unique,composite:idx_member" json:"from"`
-	To              string    `gorm:"not null;index:,unique,composite:idx_member" json:"to"`
-	Vehicle         Vehicle   `gorm:"not null;index:,unique,composite:idx_member" json:"vehicle"`
-	Date            time.Time `gorm:"not null;index:,unique,composite:idx_member" json:"date"`
-	ArrivalDuration string    `json:"arrival_duration"`
-	Capacity        uint      `gorm:"not null;check:capacity>0" json:"capacity"`
-	AvailableSeat   uint      `gorm:"not null;check:available_seat>=0" json:"available_seat"`
-	Price           float64   `gorm:"not null;check:price>0" json:"price"`
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
-	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	ID              int         `gorm:"primaryKey" json:"id"`
+	From            string      `gorm:"not null;index:,unique,composite:idx_member" json:"from"`
+	To              string      `gorm:"not null;index:,unique,composite:idx_member" json:"to"`
+	Vehicle         Vehicle     `gorm:"not null;index:,unique,composite:idx_member" json:"vehicle"`
+	VehicleID       uint        `gorm:"index" json:"vehicle_id"`
+	Date            time.Time   `gorm:"not null;index:,unique,composite:idx_member" json:"date"`
+	ArrivalDuration Duration    `json:"arrival_duration"`
+	Capacity        uint        `gorm:"not null;check:capacity>0" json:"capacity"`
+	AvailableSeat   uint        `gorm:"not null;check:available_seat>=0" json:"available_seat"`
+	DeckCapacity    uint        `gorm:"not null;default:0" json:"deck_capacity"`
+	AvailableDeck   uint        `gorm:"not null;default:0;check:available_deck>=0" json:"available_deck"`
+	Price           float64     `gorm:"not null;check:price>0" json:"price"`
+	FareClasses     []FareClass `json:"fare_classes"`
+	// Date is the departure instant. LocalDate may be given instead on
+	// creation, as the wall clock time at the departure station.
+	LocalDate         string    `gorm:"-" json:"local_date,omitempty"`
+	DepartureTimeZone string    `json:"departure_time_zone"`
+	ArrivalTimeZone   string    `json:"arrival_time_zone"`
+	DepartureAt       time.Time `gorm:"-" json:"departure_at"`
+	ArrivalAt         time.Time `gorm:"-" json:"arrival_at"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
 }
 
 func (t *Trip) CheckAvailableSeat(numberOfTickets int) bool {
@@ -46,23 +79,115 @@ func (t *Trip) CheckAvailableSeat(numberOfTickets int) bool {
 	return true
 }
 
-func (t *Trip) BeforeCreate(tx *gorm.DB) error {
-	switch t.Vehicle {
-	case VehicleFlight:
-		t.Capacity = CapacityOfFlight
-	case VehicleBus:
-		t.Capacity = CapacityOfBus
-	default:
-		t.Capacity = DefaultCapacity
+// ArrivalTime is the departure date shifted by the arrival duration.
+func (t *Trip) ArrivalTime() (time.Time, error) {
+	if !t.ArrivalDuration.IsValid() {
+		return time.Time{}, ErrInvalidArrivalDuration
+	}
+	return t.Date.Add(time.Duration(t.ArrivalDuration)), nil
+}
+
+// SetStations ties the trip to the time zones of its stations and resolves
+// LocalDate into the departure instant.
+func (t *Trip) SetStations(from, to *Station) error {
+	departureLocation, err := from.Location()
+	if err != nil {
+		return err
+	}
+
+	if _, err = to.Location(); err != nil {
+		return err
+	}
+
+	t.DepartureTimeZone = from.TimeZone
+	t.ArrivalTimeZone = to.TimeZone
+
+	if t.LocalDate != "" {
+		date, err := time.ParseInLocation(LocalDateLayout, t.LocalDate, departureLocation)
+		if err != nil {
+			return ErrInvalidLocalDate
+		}
+		t.Date = date
+		t.LocalDate = ""
 	}
 
+	t.Date = t.Date.UTC()
+
+	return nil
+}
+
+// localize fills the departure and arrival times shown at the stations.
+func (t *Trip) localize() {
+	t.DepartureAt = t.Date.In(loadLocation(t.DepartureTimeZone))
+
+	if arrival, err := t.ArrivalTime(); err == nil {
+		t.ArrivalAt = arrival.In(loadLocation(t.ArrivalTimeZone))
+	}
+}
+
+func (t *Trip) IsInPast(now time.Time) bool {
+	return t.Date.Before(now)
+}
+
+func (t *Trip) AfterFind(tx *gorm.DB) error {
+	t.localize()
+
+	return nil
+}
+
+func (t *Trip) AfterCreate(tx *gorm.DB) error {
+	t.localize()
+
+	return nil
+}
+
+// Overlaps reports whether both trips keep their vehicle on the road at the same time.
+func (t *Trip) Overlaps(other *Trip) bool {
+	arrival, err := t.ArrivalTime()
+	if err != nil {
+		return false
+	}
+
+	otherArrival, err := other.ArrivalTime()
+	if err != nil {
+		return false
+	}
+
+	return t.Date.Before(otherArrival) && other.Date.Before(arrival)
+}
+
+// SoldSeat is the number of seats which are not available anymore.
+func (t *Trip) SoldSeat() uint {
+	return t.Capacity - t.AvailableSeat
+}
+
+func (t *Trip) CheckAvailableDeck(numberOfVehicles int) bool {
+	return t.AvailableDeck >= uint(numberOfVehicles)
+}
+
+// RequiresDocument reports whether passengers should present an identity
+// document, depending on the vehicle type of the trip.
+func (t *Trip) RequiresDocument() bool {
+	return fleet.RequiresDocument(t.Vehicle)
+}
+
+func (t *Trip) DocumentRequirement() fleet.DocumentRequirement {
+	return fleet.DocumentsOf(t.Vehicle)
+}
+
+func (t *Trip) BeforeCreate(tx *gorm.DB) error {
 	t.AvailableSeat = t.Capacity
+	t.AvailableDeck = t.DeckCapacity
 
 	return nil
 }
 
 func (t *Trip) CheckFieldsEmpty() bool {
-	return t.IsStartingPlaceEmpty() || t.IsDestinationPlaceEmpty() || t.IsDateEmpty()
+	return t.IsStartingPlaceEmpty() || t.IsDestinationPlaceEmpty() || (t.IsDateEmpty() && t.LocalDate == "") || t.IsVehicleIDEmpty()
+}
+
+func (t *Trip) IsVehicleIDEmpty() bool {
+	return t.VehicleID == 0
 }
 
 func (t *Trip) IsStartingPlaceEmpty() bool {
@@ -78,7 +203,7 @@ func (t *Trip) IsInvalidVehicle() bool {
 }
 
 func (t *Trip) IsValidVehicle() bool {
-	return t.Vehicle == VehicleFlight || t.Vehicle == VehicleBus
+	return fleet.IsValidType(t.Vehicle)
 }
 
 func (t *Trip) IsDateEmpty() bool {
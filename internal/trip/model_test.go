@@ -0,0 +1,83 @@
+package trip
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrip_SetStations(t *testing.T) {
+	istanbul := &Station{Name: "Istanbul", TimeZone: "Europe/Istanbul"}
+	london := &Station{Name: "London", TimeZone: "Europe/London"}
+
+	tests := []struct {
+		name            string
+		trip            Trip
+		to              *Station
+		expectedErr     error
+		expectedDate    time.Time
+		expectedArrival string
+	}{
+		{
+			name:            "local date in departure time zone",
+			trip:            Trip{LocalDate: "2023-03-01 08:30", ArrivalDuration: Duration(4 * time.Hour)},
+			to:              london,
+			expectedDate:    time.Date(2023, 3, 1, 5, 30, 0, 0, time.UTC),
+			expectedArrival: "2023-03-01 09:30",
+		},
+		{
+			name:        "invalid local date",
+			trip:        Trip{LocalDate: "01/03/2023 08:30"},
+			to:          london,
+			expectedErr: ErrInvalidLocalDate,
+		},
+		{
+			name:        "invalid time zone",
+			trip:        Trip{LocalDate: "2023-03-01 08:30"},
+			to:          &Station{Name: "Nowhere", TimeZone: "Mars/Olympus"},
+			expectedErr: ErrInvalidTimeZone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.trip.SetStations(istanbul, tt.to)
+			if err != tt.expectedErr {
+				t.Fatalf("SetStations() = %v, want %v", err, tt.expectedErr)
+			}
+			if err != nil {
+				return
+			}
+			if !tt.trip.Date.Equal(tt.expectedDate) {
+				t.Errorf("SetStations() date = %v, want %v", tt.trip.Date, tt.expectedDate)
+			}
+
+			tt.trip.localize()
+			if got := tt.trip.ArrivalAt.Format(LocalDateLayout); got != tt.expectedArrival {
+				t.Errorf("localize() arrival = %v, want %v", got, tt.expectedArrival)
+			}
+		})
+	}
+}
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expected    Duration
+		expectedErr error
+	}{
+		{name: "hours and minutes", data: `"5h30m"`, expected: Duration(5*time.Hour + 30*time.Minute)},
+		{name: "invalid text", data: `"five hours"`, expectedErr: ErrInvalidDuration},
+		{name: "number", data: `300`, expectedErr: ErrInvalidDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Duration
+			if err := d.UnmarshalJSON([]byte(tt.data)); err != tt.expectedErr {
+				t.Fatalf("UnmarshalJSON() = %v, want %v", err, tt.expectedErr)
+			}
+			if d != tt.expected {
+				t.Errorf("UnmarshalJSON() = %v, want %v", d, tt.expected)
+			}
+		})
+	}
+}
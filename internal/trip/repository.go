@@ -3,15 +3,27 @@ package trip
 import (
 	"context"
 	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
 	"github.com/dilaragorum/online-ticket-project-go/internal/user"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/labstack/gommon/log"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
+// noShowStatus is the status the ticket package gives to tickets whose
+// passenger did not show up.
+const noShowStatus = "no_show"
+
+// soldStatuses are the statuses of the ticket package for tickets which have
+// been paid and not given back. Held and pending tickets are not sold yet.
+var soldStatuses = []string{"issued", "checked_in", "boarded", noShowStatus}
+
 var (
 	ErrDuplicateIdx = errors.New(`ERROR: duplicate key value violates unique constraint "idx_trips_idx_member" (SQLSTATE 23505)`)
 	ErrTripNotFound = errors.New("this trip is not available")
+	ErrSoldSeat     = errors.New("capacity cannot be less than the sold seats")
 )
 
 type Repository interface {
@@ -19,7 +31,17 @@ type Repository interface {
 	Delete(ctx context.Context, id int) error
 	FindByFilter(ctx context.Context, trip *Filter) ([]Trip, error)
 	FindByTripID(ctx context.Context, tripID int) (*Trip, error)
+	FindDepartures(ctx context.Context, from string, vehicle Vehicle, start, end time.Time) ([]Trip, error)
+	AssignVehicle(ctx context.Context, tripID int, vehicleID uint) error
+	Update(ctx context.Context, trip *Trip, changes []Change) error
+	FindChanges(ctx context.Context, tripID int) ([]Change, error)
+	FindContacts(ctx context.Context, tripID int) ([]Contact, error)
+	CreateStation(ctx context.Context, station *Station) error
+	FindStations(ctx context.Context) ([]Station, error)
+	FindStationByName(ctx context.Context, name string) (*Station, error)
 	GetSoldTicketNumber(ctx context.Context, tripID int) (int, error)
+	GetRevenue(ctx context.Context, tripID int) (float64, error)
+	GetAttendance(ctx context.Context, tripID int) (*Attendance, error)
 	UpdateAvailableSeat(ctx context.Context, tripID int, ticketNum int) error
 }
 
@@ -35,16 +57,25 @@ func (t *defaultRepository) Create(ctx context.Context, trip *Trip) error {
 	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	if err := t.database.WithContext(timeoutCtx).Model(&Trip{}).Create(trip).Error; err != nil {
-		if err.Error() == ErrDuplicateIdx.Error() {
-			return ErrDuplicateIdx
+	err := t.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if _, err := claimVehicle(tx, trip); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Trip{}).Create(trip).Error; err != nil {
+			if err.Error() == ErrDuplicateIdx.Error() {
+				return ErrDuplicateIdx
+			}
+			return err
 		}
 
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrDuplicateIdx) && !errors.Is(err, ErrVehicleUnavailable) && !errors.Is(err, ErrVehicleNotExist) {
 		log.Error(err)
-		return err
 	}
 
-	return nil
+	return err
 }
 
 func (t *defaultRepository) Delete(ctx context.Context, id int) error {
@@ -70,13 +101,20 @@ func (t *defaultRepository) FindByFilter(ctx context.Context, filter *Filter) ([
 
 	var trips []Trip
 
-	if err := t.database.WithContext(timeoutCtx).Where(&Trip{
+	query := t.database.WithContext(timeoutCtx).Preload("FareClasses").Where(&Trip{
 		ID:      filter.TripID,
 		From:    filter.From,
 		To:      filter.To,
 		Vehicle: filter.Vehicle,
-		Date:    filter.Date,
-	}).Find(&trips).Error; err != nil {
+	})
+
+	if !filter.Date.IsZero() {
+		year, month, day := filter.Date.Date()
+		start := time.Date(year, month, day, 0, 0, 0, 0, filter.Date.Location())
+		query = query.Where("date >= ? AND date < ?", start, start.AddDate(0, 0, 1))
+	}
+
+	if err := query.Order("date").Find(&trips).Error; err != nil {
 		log.Error(err)
 		return nil, err
 	}
@@ -90,7 +128,7 @@ func (t *defaultRepository) FindByTripID(ctx context.Context, tripID int) (*Trip
 
 	var trip Trip
 
-	if err := t.database.WithContext(timeoutCtx).First(&trip, tripID).Error; err != nil {
+	if err := t.database.WithContext(timeoutCtx).Preload("FareClasses").First(&trip, tripID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrTripNotFound
 		}
@@ -101,12 +139,275 @@ func (t *defaultRepository) FindByTripID(ctx context.Context, tripID int) (*Trip
 	return &trip, nil
 }
 
+func (t *defaultRepository) FindDepartures(ctx context.Context, from string, vehicle Vehicle, start, end time.Time) ([]Trip, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var trips []Trip
+
+	if err := t.database.WithContext(timeoutCtx).Preload("FareClasses").
+		Where(&Trip{From: from, Vehicle: vehicle}).
+		Where("date >= ? AND date < ? AND available_seat > 0", start, end).
+		Order("date").
+		Find(&trips).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return trips, nil
+}
+
+// AssignVehicle moves the trip to another vehicle keeping the sold seats and
+// deck spaces, as long as the new vehicle is big enough for them.
+func (t *defaultRepository) AssignVehicle(ctx context.Context, tripID int, vehicleID uint) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := t.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		var trip Trip
+		if err := tx.First(&trip, tripID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTripNotFound
+			}
+			return err
+		}
+
+		trip.VehicleID = vehicleID
+		vehicle, err := claimVehicle(tx, &trip)
+		if err != nil {
+			return err
+		}
+
+		return assignVehicle(tx, tripID, vehicle)
+	})
+	if err != nil && !errors.Is(err, ErrSoldSeat) && !errors.Is(err, ErrFareClassCapacity) && !errors.Is(err, ErrTripNotFound) && !errors.Is(err, ErrVehicleUnavailable) && !errors.Is(err, ErrVehicleNotExist) {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// Update saves the schedule, prices and vehicle of the trip together with the
+// audit records of the changes.
+func (t *defaultRepository) Update(ctx context.Context, trip *Trip, changes []Change) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	err := t.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if IsScheduleChanged(changes) || IsVehicleChanged(changes) {
+			vehicle, err := claimVehicle(tx, trip)
+			if err != nil {
+				return err
+			}
+
+			if IsVehicleChanged(changes) {
+				if err = assignVehicle(tx, trip.ID, vehicle); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Model(&Trip{ID: trip.ID}).Updates(map[string]interface{}{
+			"date":             trip.Date,
+			"arrival_duration": trip.ArrivalDuration,
+			"price":            trip.Price,
+		}).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrDuplicateIdx
+			}
+			return err
+		}
+
+		for i := range trip.FareClasses {
+			fareClass := trip.FareClasses[i]
+			if err := tx.Model(&FareClass{ID: fareClass.ID}).Update("price", fareClass.Price).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		return tx.Create(&changes).Error
+	})
+	if err != nil && !errors.Is(err, ErrSoldSeat) && !errors.Is(err, ErrFareClassCapacity) && !errors.Is(err, ErrDuplicateIdx) && !errors.Is(err, ErrVehicleUnavailable) && !errors.Is(err, ErrVehicleNotExist) {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func (t *defaultRepository) FindChanges(ctx context.Context, tripID int) ([]Change, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var changes []Change
+
+	if err := t.database.WithContext(timeoutCtx).Where("trip_id = ?", tripID).Order("id").Find(&changes).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// FindContacts returns how to reach the passengers holding a ticket of the trip.
+func (t *defaultRepository) FindContacts(ctx context.Context, tripID int) ([]Contact, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var contacts []Contact
+
+	if err := t.database.WithContext(timeoutCtx).Table("tickets").
+		Select("full_name, email, phone").
+		Where("trip_id = ? AND deleted_at IS NULL", tripID).
+		Scan(&contacts).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+func (t *defaultRepository) CreateStation(ctx context.Context, station *Station) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := t.database.WithContext(timeoutCtx).Create(station).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrDuplicateStation
+		}
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *defaultRepository) FindStations(ctx context.Context) ([]Station, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var stations []Station
+
+	if err := t.database.WithContext(timeoutCtx).Order("name").Find(&stations).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return stations, nil
+}
+
+func (t *defaultRepository) FindStationByName(ctx context.Context, name string) (*Station, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var station Station
+
+	if err := t.database.WithContext(timeoutCtx).First(&station, "name = ?", name).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStationNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &station, nil
+}
+
+// claimVehicle locks the vehicle of the trip and fails with
+// ErrVehicleUnavailable when the vehicle serves another trip at the same
+// time. Holding the lock until the trip is written keeps two trips from
+// taking the vehicle at once, and keeps the vehicle from being deleted.
+func claimVehicle(tx *gorm.DB, trip *Trip) (*fleet.Vehicle, error) {
+	var vehicle fleet.Vehicle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&vehicle, trip.VehicleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVehicleNotExist
+		}
+		return nil, err
+	}
+
+	arrival, err := trip.ArrivalTime()
+	if err != nil {
+		return nil, err
+	}
+
+	var trips []Trip
+	if err = tx.Where("vehicle_id = ? AND id <> ? AND date >= ? AND date < ?", vehicle.ID, trip.ID, trip.Date.Add(-MaxTripDuration), arrival).
+		Find(&trips).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range trips {
+		if trips[i].Overlaps(trip) {
+			return nil, ErrVehicleUnavailable
+		}
+	}
+
+	return &vehicle, nil
+}
+
+// assignVehicle is AssignVehicle within a transaction. The fare classes are
+// resized to the seats of the vehicle by ResizeFareClasses.
+func assignVehicle(tx *gorm.DB, tripID int, vehicle *fleet.Vehicle) error {
+	var trip Trip
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&trip, tripID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTripNotFound
+		}
+		return err
+	}
+
+	capacity, deckCapacity := vehicle.Capacity(), vehicle.DeckCapacity
+
+	if trip.SoldSeat() > capacity || trip.DeckCapacity-trip.AvailableDeck > deckCapacity {
+		return ErrSoldSeat
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("trip_id = ?", tripID).Find(&trip.FareClasses).Error; err != nil {
+		return err
+	}
+
+	if err := trip.ResizeFareClasses(capacity, vehicle.ClassCapacities()); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&trip).Updates(map[string]interface{}{
+		"vehicle_id":     vehicle.ID,
+		"available_seat": capacity - trip.SoldSeat(),
+		"capacity":       capacity,
+		"available_deck": deckCapacity - (trip.DeckCapacity - trip.AvailableDeck),
+		"deck_capacity":  deckCapacity,
+	}).Error; err != nil {
+		return err
+	}
+
+	for i := range trip.FareClasses {
+		fareClass := trip.FareClasses[i]
+		if err := tx.Model(&FareClass{ID: fareClass.ID}).Updates(map[string]interface{}{
+			"capacity":       fareClass.Capacity,
+			"available_seat": fareClass.AvailableSeat,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetSoldTicketNumber counts the seats sold on the trip. Infants on lap do
+// not take a seat.
 func (t *defaultRepository) GetSoldTicketNumber(ctx context.Context, tripID int) (int, error) {
 	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var soldTicketNumber int64
-	if err := t.database.WithContext(timeoutCtx).Model(&Trip{}).Joins("inner join tickets on trips.id = tickets.trip_id").Count(&soldTicketNumber); err.Error != nil {
+	if err := t.database.WithContext(timeoutCtx).Model(&Trip{}).Joins("inner join tickets on trips.id = tickets.trip_id").
+		Where("trips.id = ? AND tickets.deleted_at IS NULL AND tickets.status IN ? AND NOT tickets.on_lap", tripID, soldStatuses).Count(&soldTicketNumber); err.Error != nil {
 		log.Error(err.Error)
 		return -1, err.Error
 	}
@@ -114,6 +415,45 @@ func (t *defaultRepository) GetSoldTicketNumber(ctx context.Context, tripID int)
 	return int(soldTicketNumber), nil
 }
 
+// GetRevenue sums what was paid for the sold tickets of the trip, including
+// the fares of infants on lap.
+func (t *defaultRepository) GetRevenue(ctx context.Context, tripID int) (float64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var revenue float64
+	if err := t.database.WithContext(timeoutCtx).Table("tickets").
+		Where("trip_id = ? AND deleted_at IS NULL AND status IN ?", tripID, soldStatuses).
+		Select("COALESCE(SUM(price), 0)").Scan(&revenue).Error; err != nil {
+		log.Error(err)
+		return -1, err
+	}
+
+	return revenue, nil
+}
+
+// GetAttendance counts the sold tickets of the trip and those marked as
+// no-show by the ticket package.
+func (t *defaultRepository) GetAttendance(ctx context.Context, tripID int) (*Attendance, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	attendance := Attendance{TripID: tripID}
+	if err := t.database.WithContext(timeoutCtx).Table("tickets").
+		Where("trip_id = ? AND deleted_at IS NULL AND status IN ?", tripID, soldStatuses).
+		Select("COUNT(*) AS tickets, COUNT(*) FILTER (WHERE status = ?) AS no_shows", noShowStatus).
+		Scan(&attendance).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	if attendance.Tickets > 0 {
+		attendance.NoShowRate = float64(attendance.NoShows) / float64(attendance.Tickets)
+	}
+
+	return &attendance, nil
+}
+
 func (t *defaultRepository) UpdateAvailableSeat(ctx context.Context, tripID int, ticketNum int) error {
 	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Second)
 	defer cancel()
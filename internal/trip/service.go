@@ -3,28 +3,55 @@ package trip
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
 	"github.com/dilaragorum/online-ticket-project-go/internal/user"
+	"github.com/labstack/gommon/log"
+	"sort"
+	"time"
 )
 
 var (
 	ErrAlreadyCreatedTrip = errors.New("this trip is already created")
 	ErrTripNotExist       = errors.New("this trip does not exist")
+
+	ErrVehicleNotExist     = errors.New("this vehicle does not exist")
+	ErrVehicleTypeMismatch = errors.New("vehicle type does not match the trip")
+	ErrVehicleUnavailable  = errors.New("vehicle is assigned to another trip at the same time")
 )
 
 type Service interface {
 	FilterTrips(ctx context.Context, trip *Filter) ([]Trip, error)
+	SearchItineraries(ctx context.Context, filter *ItineraryFilter) ([]Itinerary, error)
 	CreateTrip(ctx context.Context, trip *Trip) error
 	CancelTrip(ctx context.Context, id int) error
+	AssignVehicle(ctx context.Context, tripID int, vehicleID uint) error
+	UpdateTrip(ctx context.Context, tripID int, update *Update, userID uint) (*Trip, error)
+	GetChanges(ctx context.Context, tripID int) ([]Change, error)
+	CreateStation(ctx context.Context, station *Station) error
+	ListStations(ctx context.Context) ([]Station, error)
 	GetSoldTicketNumber(ctx context.Context, tripID int) (int, error)
 	GetTotalRevenueForSpecificTrip(ctx context.Context, tripID int) (float64, error)
+	GetAttendance(ctx context.Context, tripID int) (*Attendance, error)
+}
+
+// Listener is told about trips customers may be waiting for. It should
+// handle its own errors, so a trip is never rejected because of a listener.
+type Listener interface {
+	TripCreated(ctx context.Context, t *Trip)
+	TripPriceChanged(ctx context.Context, t *Trip)
 }
 
 type defaultService struct {
-	tripRepo Repository
+	tripRepo            Repository
+	fleetRepo           fleet.Repository
+	notificationService notification.Service
+	listeners           []Listener
 }
 
-func NewTripService(tripRepo Repository) Service {
-	return &defaultService{tripRepo: tripRepo}
+func NewTripService(tripRepo Repository, fleetRepo fleet.Repository, notificationService notification.Service, listeners ...Listener) Service {
+	return &defaultService{tripRepo: tripRepo, fleetRepo: fleetRepo, notificationService: notificationService, listeners: listeners}
 }
 
 func (s *defaultService) FilterTrips(ctx context.Context, filter *Filter) ([]Trip, error) {
@@ -37,17 +64,155 @@ func (s *defaultService) FilterTrips(ctx context.Context, filter *Filter) ([]Tri
 		return nil, user.ErrThereIsNoTrip
 	}
 
+	sortTrips(trips, filter.SortBy)
+
 	return trips, err
 }
 
+func sortTrips(trips []Trip, sortBy string) {
+	sort.SliceStable(trips, func(i, j int) bool {
+		switch sortBy {
+		case SortByArrival:
+			return trips[i].ArrivalAt.Before(trips[j].ArrivalAt)
+		case SortByDuration:
+			return trips[i].ArrivalDuration < trips[j].ArrivalDuration
+		case SortByPrice:
+			return trips[i].Price < trips[j].Price
+		default:
+			return trips[i].Date.Before(trips[j].Date)
+		}
+	})
+}
+
+func (s *defaultService) SearchItineraries(ctx context.Context, filter *ItineraryFilter) ([]Itinerary, error) {
+	outbounds, err := s.findJourneys(ctx, filter.From, filter.To, filter.Via, filter.Vehicle, filter.Date, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var returns []Journey
+	if filter.IsRoundTrip() {
+		returns, err = s.findJourneys(ctx, filter.To, filter.From, filter.Via, filter.Vehicle, filter.ReturnDate, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	itineraries := combine(outbounds, returns, filter.IsRoundTrip())
+	if len(itineraries) == 0 {
+		return nil, user.ErrThereIsNoTrip
+	}
+
+	return itineraries, nil
+}
+
+// findJourneys returns direct and one-stop journeys departing on the given day.
+// When via is set only connections through that place are considered.
+func (s *defaultService) findJourneys(ctx context.Context, from, to, via string, vehicle Vehicle, date time.Time, filter *ItineraryFilter) ([]Journey, error) {
+	firstLegs, err := s.tripRepo.FindDepartures(ctx, from, vehicle, date, date.Add(24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	journeys := make([]Journey, 0)
+	connections := make(map[string][]Trip)
+
+	for i := range firstLegs {
+		leg := firstLegs[i]
+
+		if leg.To == to {
+			if via != "" {
+				continue
+			}
+			journey, err := NewJourney([]Trip{leg}, 0)
+			if err != nil {
+				continue
+			}
+			journeys = append(journeys, journey)
+			continue
+		}
+
+		if via != "" && leg.To != via {
+			continue
+		}
+		connections[leg.To] = append(connections[leg.To], leg)
+	}
+
+	for hub, legs := range connections {
+		earliest, latest, ok := arrivalWindow(legs)
+		if !ok {
+			continue
+		}
+
+		secondLegs, err := s.tripRepo.FindDepartures(ctx, hub, vehicle, earliest.Add(filter.MinLayover()), latest.Add(filter.MaxLayover()))
+		if err != nil {
+			return nil, err
+		}
+
+		journeys = append(journeys, connect(legs, secondLegs, to, filter.MinLayover(), filter.MaxLayover())...)
+	}
+
+	return journeys, nil
+}
+
+func arrivalWindow(trips []Trip) (time.Time, time.Time, bool) {
+	var earliest, latest time.Time
+
+	for i := range trips {
+		arrivalAt, err := trips[i].ArrivalTime()
+		if err != nil {
+			continue
+		}
+		if earliest.IsZero() || arrivalAt.Before(earliest) {
+			earliest = arrivalAt
+		}
+		if arrivalAt.After(latest) {
+			latest = arrivalAt
+		}
+	}
+
+	return earliest, latest, !earliest.IsZero()
+}
+
 func (s *defaultService) CreateTrip(ctx context.Context, t *Trip) error {
-	if err := s.tripRepo.Create(ctx, t); err != nil {
+	from, err := s.tripRepo.FindStationByName(ctx, t.From)
+	if err != nil {
+		return err
+	}
+
+	to, err := s.tripRepo.FindStationByName(ctx, t.To)
+	if err != nil {
+		return err
+	}
+
+	if err = t.SetStations(from, to); err != nil {
+		return err
+	}
+
+	if t.IsInPast(time.Now()) {
+		return ErrTripInPast
+	}
+
+	vehicle, err := s.findVehicle(ctx, t, t.VehicleID)
+	if err != nil {
+		return err
+	}
+	t.Capacity = vehicle.Capacity()
+	t.DeckCapacity = vehicle.DeckCapacity
+
+	if err = t.PrepareFareClasses(vehicle.ClassCapacities()); err != nil {
+		return err
+	}
+
+	if err = s.tripRepo.Create(ctx, t); err != nil {
 		if errors.Is(err, ErrDuplicateIdx) {
 			return ErrAlreadyCreatedTrip
 		}
 		return err
 	}
 
+	s.notifyListeners(Listener.TripCreated, t)
+
 	return nil
 }
 
@@ -63,6 +228,186 @@ func (s *defaultService) CancelTrip(ctx context.Context, id int) error {
 	return nil
 }
 
+func (s *defaultService) AssignVehicle(ctx context.Context, tripID int, vehicleID uint) error {
+	t, err := s.tripRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return ErrTripNotExist
+		}
+		return err
+	}
+
+	vehicle, err := s.findVehicle(ctx, t, vehicleID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.tripRepo.AssignVehicle(ctx, tripID, vehicle.ID); err != nil {
+		return err
+	}
+
+	if t.VehicleID != vehicle.ID {
+		s.notifyVehicleChange(ctx, t, vehicle)
+	}
+
+	return nil
+}
+
+func (s *defaultService) UpdateTrip(ctx context.Context, tripID int, update *Update, userID uint) (*Trip, error) {
+	t, err := s.tripRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return nil, ErrTripNotExist
+		}
+		return nil, err
+	}
+
+	oldPrice := t.Price
+
+	changes, err := update.Apply(t, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(changes) == 0 {
+		return t, nil
+	}
+
+	if IsScheduleChanged(changes) && t.IsInPast(time.Now()) {
+		return nil, ErrTripInPast
+	}
+
+	var vehicle *fleet.Vehicle
+	if IsScheduleChanged(changes) || IsVehicleChanged(changes) {
+		if vehicle, err = s.findVehicle(ctx, t, t.VehicleID); err != nil {
+			return nil, err
+		}
+		t.Capacity = vehicle.Capacity()
+		t.DeckCapacity = vehicle.DeckCapacity
+	}
+
+	if err = s.tripRepo.Update(ctx, t, changes); err != nil {
+		if errors.Is(err, ErrDuplicateIdx) {
+			return nil, ErrAlreadyCreatedTrip
+		}
+		return nil, err
+	}
+
+	// The trip is changed already, so a failed email does not fail the update.
+	// Passengers get a single email when both the schedule and the vehicle change.
+	switch {
+	case IsScheduleChanged(changes) && IsVehicleChanged(changes):
+		s.notifyPassengers(ctx, t, "Schedule and Vehicle Change", fmt.Sprintf("the schedule of your trip has been changed and it will be operated by %s %s", vehicle.Model, vehicle.Registration))
+	case IsScheduleChanged(changes):
+		s.notifyPassengers(ctx, t, "Schedule Change", "the schedule of your trip has been changed")
+	case IsVehicleChanged(changes):
+		s.notifyVehicleChange(ctx, t, vehicle)
+	}
+
+	updated, err := s.tripRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Price < oldPrice {
+		s.notifyListeners(Listener.TripPriceChanged, updated)
+	}
+
+	return updated, nil
+}
+
+func (s *defaultService) GetChanges(ctx context.Context, tripID int) ([]Change, error) {
+	if _, err := s.tripRepo.FindByTripID(ctx, tripID); err != nil {
+		if errors.Is(err, ErrTripNotFound) {
+			return nil, ErrTripNotExist
+		}
+		return nil, err
+	}
+
+	return s.tripRepo.FindChanges(ctx, tripID)
+}
+
+func (s *defaultService) CreateStation(ctx context.Context, station *Station) error {
+	if _, err := station.Location(); err != nil {
+		return err
+	}
+
+	return s.tripRepo.CreateStation(ctx, station)
+}
+
+func (s *defaultService) ListStations(ctx context.Context) ([]Station, error) {
+	return s.tripRepo.FindStations(ctx)
+}
+
+func (s *defaultService) notifyVehicleChange(ctx context.Context, t *Trip, vehicle *fleet.Vehicle) {
+	s.notifyPassengers(ctx, t, "Vehicle Change", fmt.Sprintf("your trip will be operated by %s %s", vehicle.Model, vehicle.Registration))
+}
+
+// notifyListeners tells the listeners about a copy of the trip in the
+// background, so the admin does not wait for every matching watch to be
+// emailed. The request context is not passed on, since it ends with the response.
+func (s *defaultService) notifyListeners(notify func(Listener, context.Context, *Trip), t *Trip) {
+	if len(s.listeners) == 0 {
+		return
+	}
+
+	changed := *t
+	go func() {
+		for _, listener := range s.listeners {
+			notify(listener, context.Background(), &changed)
+		}
+	}()
+}
+
+// notifyPassengers emails the passengers of the trip about a change of the
+// trip. Failures are only logged, since the change has been made already.
+func (s *defaultService) notifyPassengers(ctx context.Context, t *Trip, title, change string) {
+	contacts, err := s.tripRepo.FindContacts(ctx, t.ID)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	t.localize()
+
+	for i := range contacts {
+		param := notification.Param{
+			Channel: notification.Email,
+			To:      contacts[i].Email,
+			From:    "ticket@company.com",
+			Title:   title,
+			Description: fmt.Sprintf(`Dear %s, %s:
+FromTo: %s-%s
+Departure: %s
+Arrival: %s
+Vehicle: %s`, contacts[i].FullName, change, t.From, t.To, t.DepartureAt, t.ArrivalAt, t.Vehicle),
+			LogMsg: fmt.Sprintf("%s of trip %d has been sent to %s", title, t.ID, contacts[i].Email),
+		}
+
+		if err = s.notificationService.Send(ctx, param); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// findVehicle loads the vehicle and makes sure it is of the vehicle type of
+// the trip. The repository checks it is free when the trip is written.
+func (s *defaultService) findVehicle(ctx context.Context, t *Trip, vehicleID uint) (*fleet.Vehicle, error) {
+	vehicle, err := s.fleetRepo.FindByID(ctx, vehicleID)
+	if err != nil {
+		if errors.Is(err, fleet.ErrVehicleNotFound) {
+			return nil, ErrVehicleNotExist
+		}
+		return nil, err
+	}
+
+	if vehicle.Type != t.Vehicle {
+		return nil, ErrVehicleTypeMismatch
+	}
+
+	return vehicle, nil
+}
+
 func (s *defaultService) GetSoldTicketNumber(ctx context.Context, tripID int) (int, error) {
 	number, err := s.tripRepo.GetSoldTicketNumber(ctx, tripID)
 	if err != nil {
@@ -73,17 +418,17 @@ func (s *defaultService) GetSoldTicketNumber(ctx context.Context, tripID int) (i
 }
 
 func (s *defaultService) GetTotalRevenueForSpecificTrip(ctx context.Context, tripID int) (float64, error) {
-	trip, err := s.tripRepo.FindByTripID(ctx, tripID)
-	if err != nil {
+	if _, err := s.tripRepo.FindByTripID(ctx, tripID); err != nil {
 		return -1, err
 	}
 
-	soldTicketNumber, err := s.tripRepo.GetSoldTicketNumber(ctx, tripID)
-	if err != nil {
-		return -1, err
-	}
+	return s.tripRepo.GetRevenue(ctx, tripID)
+}
 
-	total := trip.Price * float64(soldTicketNumber)
+func (s *defaultService) GetAttendance(ctx context.Context, tripID int) (*Attendance, error) {
+	if _, err := s.tripRepo.FindByTripID(ctx, tripID); err != nil {
+		return nil, err
+	}
 
-	return total, nil
+	return s.tripRepo.GetAttendance(ctx, tripID)
 }
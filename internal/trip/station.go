@@ -0,0 +1,48 @@
+package trip
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrStationNotFound  = errors.New("there is no station with that name")
+	ErrInvalidTimeZone  = errors.New("time zone of the station is invalid")
+	ErrDuplicateStation = errors.New("this station is already created")
+)
+
+// Station is a place trips depart from and arrive to. TimeZone is an IANA
+// name such as "Europe/Istanbul" used to show local departure and arrival times.
+type Station struct {
+	ID        uint   `gorm:"primarykey" json:"id"`
+	Name      string `gorm:"not null;unique" json:"name"`
+	TimeZone  string `gorm:"not null" json:"time_zone"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (s *Station) CheckFieldsEmpty() bool {
+	return s.Name == "" || s.TimeZone == ""
+}
+
+func (s *Station) Location() (*time.Location, error) {
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+	return location, nil
+}
+
+// loadLocation falls back to UTC for trips created before stations had time zones.
+func loadLocation(timeZone string) *time.Location {
+	if timeZone == "" {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
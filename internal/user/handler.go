@@ -1,13 +1,20 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
 	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/dilaragorum/online-ticket-project-go/internal/role"
+	"github.com/dilaragorum/online-ticket-project-go/internal/session"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"html/template"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,24 +32,94 @@ var (
 	WarnWhenUsernameNotFound = "Invalid username, please enter valid user name"
 	WarnEmailCouldNotSent    = "Email could not be sent"
 	SuccessLoginMessage      = "Congratulations, you have successfully logged into the system."
+
+	WarnRegistrationNotAllowed = "Only individual users and invited corporate users can register"
+	WarnInvalidInvitation      = "Invitation is invalid or expired. Please ask for a new one"
+	WarnUserDeactivated        = "Your account has been deactivated"
+	WarnInvalidUserID          = "Please enter valid user ID"
+	WarnUserNotFound           = "This user does not exist"
+	WarnRoleNotFound           = "One of the roles does not exist"
+	WarnCannotDeactivateSelf   = "You cannot deactivate your own account"
+
+	WarnInvalidVerificationToken = "Verification link is invalid or expired. Please ask for a new one"
+	WarnEmailAlreadyVerified     = "Your email address has already been verified"
+	SuccessEmailVerified         = "Your email address has been verified"
+	SuccessVerificationSent      = "A verification email has been sent"
+
+	WarnInvalidResetToken    = "Password reset link is invalid or expired. Please ask for a new one"
+	WarnTooManyRequests      = "Too many requests. Please try again later"
+	SuccessPasswordResetSent = "If an account with this email exists, a password reset email has been sent"
+	SuccessPasswordReset     = "Your password has been changed. Please login again"
 )
 
+type invitationRequest struct {
+	Email string `json:"email"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// resetPasswordRequest is sent as JSON or by the form of resetPasswordPage.
+type resetPasswordRequest struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+var resetPasswordPage = template.Must(template.New("reset").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Password Reset</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<label>New password <input type="password" name="password" minlength="5" maxlength="12" required></label>
+<button type="submit">Set password</button>
+</form>
+</body>
+</html>
+`))
+
+// loginResponse is the JSON body of a login. It used to be the plain text
+// SuccessLoginMessage, clients reading it as text should read Message now.
+type loginResponse struct {
+	Message string `json:"message"`
+	*session.Tokens
+}
+
 type handler struct {
 	userService         Service
 	notificationService notification.Service
-	JwtSecretKey        string
+	sessionService      session.Service
+	baseURL             string
+	passwordLimiter     *attemptLimiter
+	emailLimiter        *attemptLimiter
 }
 
-func NewHandler(e *echo.Echo, userService Service, notificationService notification.Service, jwtSecretKey string) *handler {
+func NewHandler(routes *auth.Routes, userService Service, notificationService notification.Service, sessionService session.Service, baseURL string) *handler {
 	h := handler{
 		userService:         userService,
 		notificationService: notificationService,
-		JwtSecretKey:        jwtSecretKey,
+		sessionService:      sessionService,
+		baseURL:             baseURL,
+		passwordLimiter:     newAttemptLimiter(MaxPasswordRequests, PasswordRequestWindow),
+		emailLimiter:        newAttemptLimiter(MaxPasswordResets, PasswordResetTTL),
 	}
 
-	e.POST("/register", h.Register)
-	e.POST("/login", h.Login)
-	e.GET("/logout", h.Logout)
+	routes.POST("/register", h.Register, auth.Public)
+	routes.POST("/login", h.Login, auth.Public)
+	routes.GET("/logout", h.Logout, auth.Authenticated)
+	routes.GET("/verify-email", h.VerifyEmail, auth.Public)
+	routes.POST("/verify-email/resend", h.ResendVerification, auth.Authenticated)
+	routes.POST("/password/forgot", h.limitPasswordRequests(h.ForgotPassword), auth.Public)
+	routes.GET("/password/reset", h.ResetPasswordForm, auth.Public)
+	routes.POST("/password/reset", h.limitPasswordRequests(h.ResetPassword), auth.Public)
+
+	routes.POST("/admin/users", h.CreateUser, auth.RequirePermission(auth.UserManage))
+	routes.GET("/admin/users", h.ListUsers, auth.RequirePermission(auth.UserManage))
+	routes.PUT("/admin/users/:id/roles", h.AssignRoles, auth.RequirePermission(auth.UserManage))
+	routes.POST("/admin/users/:id/deactivate", h.Deactivate, auth.RequirePermission(auth.UserManage))
+	routes.POST("/admin/users/:id/activate", h.Activate, auth.RequirePermission(auth.UserManage))
+	routes.POST("/admin/invitations", h.Invite, auth.RequirePermission(auth.UserManage))
 
 	return &h
 }
@@ -54,50 +131,46 @@ func (h *handler) Register(c echo.Context) error {
 		return c.String(http.StatusBadRequest, err.Error())
 	}
 
-	if user.IsNameEmpty() {
-		return c.String(http.StatusBadRequest, WarnEmptyUserName)
-	}
-
-	if user.IsUserTypeInvalid() {
-		return c.String(http.StatusBadRequest, WarnInvalidUserType)
-	}
-
-	if user.IsAuthTypeInvalid() {
-		return c.String(http.StatusBadRequest, WarnInvalidAuthType)
-	}
-
-	if user.IsEmailInvalid() {
-		return c.String(http.StatusBadRequest, WarnInvalidEmail)
-	}
-
-	if user.IsPasswordInvalid() {
-		return c.String(http.StatusBadRequest, WarnPasswordLength)
+	if user.UserType == "" {
+		user.UserType = auth.IndividualUser
 	}
 
-	password, err := user.HashPassword()
-	if err != nil {
-		return c.String(http.StatusBadRequest, err.Error())
+	if msg, ok := validateUser(user); !ok {
+		return c.String(http.StatusBadRequest, msg)
 	}
-	user.Password = password
 
 	requestCtx := c.Request().Context()
 
-	err = h.userService.Register(requestCtx, user)
+	err := h.userService.Register(requestCtx, user)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrDuplicatedValue):
 			return c.String(http.StatusBadRequest, WarnWhenEmailOrUsernameIsNotUnique)
+		case errors.Is(err, ErrRegistrationNotAllowed):
+			return c.String(http.StatusForbidden, WarnRegistrationNotAllowed)
+		case errors.Is(err, ErrInvalidInvitation):
+			return c.String(http.StatusBadRequest, WarnInvalidInvitation)
 		default:
 			return c.String(http.StatusInternalServerError, WarnInternalServerError)
 		}
 	}
 
+	description := fmt.Sprintf("Dear %s, welcome to our platform", user.UserName)
+
+	if !user.EmailVerified {
+		token, _, err := h.userService.VerificationToken(requestCtx, user.ID)
+		if err != nil {
+			return c.String(http.StatusInternalServerError, WarnInternalServerError)
+		}
+		description += fmt.Sprintf(".\nPlease verify your email address here: %s", h.verificationLink(token))
+	}
+
 	param := notification.Param{
 		Channel:     notification.Email,
 		To:          user.Email,
 		From:        "ticket@company.com",
 		Title:       "Welcome Mail",
-		Description: fmt.Sprintf("Dear %s, welcome to our platform", user.UserName),
+		Description: description,
 		LogMsg:      fmt.Sprintf("A welcome e-mail has been sent to %s, who has just registered.", user.Email),
 	}
 
@@ -121,49 +194,354 @@ func (h *handler) Login(c echo.Context) error {
 			return c.String(http.StatusNotFound, WarnWhenUsernameNotFound)
 		case errors.Is(err, ErrUsernameOrPasswordInvalid):
 			return c.String(http.StatusUnauthorized, WarnNonValidCredentials)
+		case errors.Is(err, ErrUserDeactivated):
+			return c.String(http.StatusForbidden, WarnUserDeactivated)
 		default:
 			return c.String(http.StatusInternalServerError, WarnInternalServerError)
 		}
 	}
 
-	// Declare the expiration time of the token
-
-	expirationTime := &jwt.NumericDate{Time: time.Now().Add(time.Hour)}
-	claims := auth.Claims{
-		Username: user.UserName,
-		UserID:   user.ID,
-		UserType: user.UserType,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: expirationTime,
-		},
-	}
+	client := session.Client{UserAgent: c.Request().UserAgent(), IP: c.RealIP()}
 
-	// Declare the token with the algorithm used for signing, and the claims
-	// Header(algorithm + JWT) + Payload(Claim)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	// Create the JWT string = secretkey + Header + Claim
-
-	tokenString, err := token.SignedString([]byte(h.JwtSecretKey))
+	tokens, err := h.sessionService.Start(c.Request().Context(), user.ID, user.UserName, user.UserType, client)
 	if err != nil {
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	// Finally, we set the client cookie for "token" as the JWT we just generated
-	// we also set an expiry time which is the same as the token itself
-	cookie := new(http.Cookie)
-	cookie.Name = "token"
-	cookie.Value = tokenString
-	cookie.Expires = expirationTime.Time
-	c.SetCookie(cookie)
+	// Browsers use the cookies, API clients the tokens in the body.
+	session.SetTokenCookies(c, tokens)
 
-	return c.String(http.StatusOK, SuccessLoginMessage)
+	return c.JSON(http.StatusOK, loginResponse{Message: SuccessLoginMessage, Tokens: tokens})
 }
 
+// Logout ends the session of the token, so its refresh token cannot be used anymore.
 func (h *handler) Logout(c echo.Context) error {
-	cookie := new(http.Cookie)
-	cookie.Name = "token"
-	cookie.Value = ""
-	cookie.MaxAge = 0
-	c.SetCookie(cookie)
+	claim := c.Get("claim").(auth.Claims)
+
+	if err := h.sessionService.Revoke(c.Request().Context(), claim.SessionID, claim); err != nil {
+		return c.String(http.StatusInternalServerError, WarnInternalServerError)
+	}
+
+	session.ClearTokenCookies(c)
 	return c.String(http.StatusOK, "You have successfully logout")
 }
+
+func (h *handler) VerifyEmail(c echo.Context) error {
+	if err := h.userService.VerifyEmail(c.Request().Context(), c.QueryParam("token")); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidVerificationToken):
+			return c.String(http.StatusBadRequest, WarnInvalidVerificationToken)
+		default:
+			return c.String(http.StatusInternalServerError, WarnInternalServerError)
+		}
+	}
+
+	return c.String(http.StatusOK, SuccessEmailVerified)
+}
+
+// ResendVerification sends a new verification email, e.g. when the link of
+// the welcome mail has expired.
+func (h *handler) ResendVerification(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+	requestCtx := c.Request().Context()
+
+	token, user, err := h.userService.VerificationToken(requestCtx, claim.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrEmailAlreadyVerified):
+			return c.String(http.StatusBadRequest, WarnEmailAlreadyVerified)
+		default:
+			return c.String(http.StatusInternalServerError, WarnInternalServerError)
+		}
+	}
+
+	if err = h.sendVerification(requestCtx, token, user); err != nil {
+		return c.String(http.StatusInternalServerError, WarnEmailCouldNotSent)
+	}
+
+	return c.String(http.StatusOK, SuccessVerificationSent)
+}
+
+func (h *handler) sendVerification(ctx context.Context, token string, user *User) error {
+	param := notification.Param{
+		Channel:     notification.Email,
+		To:          user.Email,
+		From:        "ticket@company.com",
+		Title:       "Email Verification",
+		Description: fmt.Sprintf("Dear %s, please verify your email address here: %s", user.UserName, h.verificationLink(token)),
+		LogMsg:      fmt.Sprintf("A verification e-mail has been sent to %s", user.Email),
+	}
+
+	return h.notificationService.Send(ctx, param)
+}
+
+// ForgotPassword emails a password reset link. It answers the same whether
+// or not the email belongs to a user, so it cannot be used to find accounts.
+func (h *handler) ForgotPassword(c echo.Context) error {
+	var request forgotPasswordRequest
+	if err := c.Bind(&request); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if (&User{Email: request.Email}).IsEmailInvalid() {
+		return c.String(http.StatusBadRequest, WarnInvalidEmail)
+	}
+
+	// Requests from many IPs cannot flood one address either. It answers the
+	// same whether or not the email belongs to a user.
+	if !h.emailLimiter.Allow(strings.ToLower(request.Email), time.Now()) {
+		return c.String(http.StatusTooManyRequests, WarnTooManyRequests)
+	}
+
+	requestCtx := c.Request().Context()
+
+	token, user, err := h.userService.ForgotPassword(requestCtx, request.Email)
+	if err != nil {
+		if !errors.Is(err, ErrUserNotFound) && !errors.Is(err, ErrUserDeactivated) && !errors.Is(err, ErrTooManyPasswordResets) {
+			log.Error(err)
+		}
+		return c.String(http.StatusAccepted, SuccessPasswordResetSent)
+	}
+
+	param := notification.Param{
+		Channel:     notification.Email,
+		To:          user.Email,
+		From:        "ticket@company.com",
+		Title:       "Password Reset",
+		Description: fmt.Sprintf("Dear %s, you can set a new password here until %s: %s/password/reset?token=%s\nIf you did not ask for it, you can ignore this email.", user.UserName, time.Now().Add(PasswordResetTTL).Format(time.RFC1123), h.baseURL, url.QueryEscape(token)),
+		LogMsg:      fmt.Sprintf("A password reset e-mail has been sent to %s", user.Email),
+	}
+
+	if err = h.notificationService.Send(requestCtx, param); err != nil {
+		log.Error(err)
+	}
+
+	return c.String(http.StatusAccepted, SuccessPasswordResetSent)
+}
+
+// ResetPasswordForm is the page the link of a password reset email opens. Its
+// form sends the token with the new password to ResetPassword.
+func (h *handler) ResetPasswordForm(c echo.Context) error {
+	var page strings.Builder
+
+	err := resetPasswordPage.Execute(&page, map[string]string{
+		"Action": h.baseURL + "/password/reset",
+		"Token":  c.QueryParam("token"),
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnInternalServerError)
+	}
+
+	return c.HTML(http.StatusOK, page.String())
+}
+
+func (h *handler) ResetPassword(c echo.Context) error {
+	var request resetPasswordRequest
+	if err := c.Bind(&request); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	user := User{Password: request.Password}
+	if user.IsPasswordInvalid() {
+		return c.String(http.StatusBadRequest, WarnPasswordLength)
+	}
+
+	password, err := user.HashPassword()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnInternalServerError)
+	}
+
+	if err = h.userService.ResetPassword(c.Request().Context(), request.Token, password); err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidResetToken):
+			return c.String(http.StatusBadRequest, WarnInvalidResetToken)
+		default:
+			return c.String(http.StatusInternalServerError, WarnInternalServerError)
+		}
+	}
+
+	session.ClearTokenCookies(c)
+	return c.String(http.StatusOK, SuccessPasswordReset)
+}
+
+// limitPasswordRequests limits the requests of a client IP. The IP comes from
+// the IPExtractor of echo, which only believes proxies on private networks.
+func (h *handler) limitPasswordRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !h.passwordLimiter.Allow(c.RealIP(), time.Now()) {
+			return c.String(http.StatusTooManyRequests, WarnTooManyRequests)
+		}
+		return next(c)
+	}
+}
+
+func (h *handler) verificationLink(token string) string {
+	return fmt.Sprintf("%s/verify-email?token=%s", h.baseURL, url.QueryEscape(token))
+}
+
+func (h *handler) CreateUser(c echo.Context) error {
+	account := new(NewAccount)
+
+	if err := c.Bind(account); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if msg, ok := validateUser(&account.User); !ok {
+		return c.String(http.StatusBadRequest, msg)
+	}
+
+	requestCtx := c.Request().Context()
+
+	if err := h.userService.CreateUser(requestCtx, account); err != nil {
+		switch {
+		case errors.Is(err, ErrDuplicatedValue):
+			return c.String(http.StatusBadRequest, WarnWhenEmailOrUsernameIsNotUnique)
+		case errors.Is(err, role.ErrRoleNotFound):
+			return c.String(http.StatusBadRequest, WarnRoleNotFound)
+		default:
+			return c.String(http.StatusInternalServerError, WarnInternalServerError)
+		}
+	}
+
+	// The user is created already, so a failed email is only logged. The user
+	// can ask for a new one after logging in.
+	token, user, err := h.userService.VerificationToken(requestCtx, account.ID)
+	if err == nil {
+		err = h.sendVerification(requestCtx, token, user)
+	}
+	if err != nil {
+		log.Error(err)
+	}
+
+	return c.JSON(http.StatusCreated, account.Profile())
+}
+
+func (h *handler) ListUsers(c echo.Context) error {
+	profiles, err := h.userService.List(c.Request().Context())
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, profiles)
+}
+
+func (h *handler) AssignRoles(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidUserID)
+	}
+
+	var roles []auth.Role
+	if err = c.Bind(&roles); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if err = h.userService.AssignRoles(c.Request().Context(), uint(userID), roles); err != nil {
+		return adminError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *handler) Deactivate(c echo.Context) error {
+	return h.setActive(c, false)
+}
+
+func (h *handler) Activate(c echo.Context) error {
+	return h.setActive(c, true)
+}
+
+func (h *handler) setActive(c echo.Context, active bool) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidUserID)
+	}
+
+	if err = h.userService.SetActive(c.Request().Context(), uint(userID), active, claim); err != nil {
+		return adminError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Invite emails an invitation to register as a corporate user.
+func (h *handler) Invite(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	var request invitationRequest
+	if err := c.Bind(&request); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if (&User{Email: request.Email}).IsEmailInvalid() {
+		return c.String(http.StatusBadRequest, WarnInvalidEmail)
+	}
+
+	requestCtx := c.Request().Context()
+
+	token, invitation, err := h.userService.Invite(requestCtx, request.Email, claim)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnInternalServerError)
+	}
+
+	param := notification.Param{
+		Channel:     notification.Email,
+		To:          invitation.Email,
+		From:        "ticket@company.com",
+		Title:       "Invitation",
+		Description: fmt.Sprintf("You have been invited to register as a corporate user. Please register with this email address and the invitation code %s before %s", token, invitation.ExpiresAt.Format(time.RFC1123)),
+		LogMsg:      fmt.Sprintf("%s who has %d id invited %s to register as a corporate user", claim.Username, claim.UserID, invitation.Email),
+	}
+
+	if err = h.notificationService.Send(requestCtx, param); err != nil {
+		return c.String(http.StatusInternalServerError, WarnEmailCouldNotSent)
+	}
+
+	return c.JSON(http.StatusCreated, invitation)
+}
+
+func adminError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return c.String(http.StatusNotFound, WarnUserNotFound)
+	case errors.Is(err, role.ErrRoleNotFound):
+		return c.String(http.StatusBadRequest, WarnRoleNotFound)
+	case errors.Is(err, ErrCannotDeactivateSelf):
+		return c.String(http.StatusBadRequest, WarnCannotDeactivateSelf)
+	default:
+		return c.String(http.StatusInternalServerError, WarnInternalServerError)
+	}
+}
+
+// validateUser checks the fields of a user and hashes the password.
+func validateUser(user *User) (string, bool) {
+	if user.IsNameEmpty() {
+		return WarnEmptyUserName, false
+	}
+
+	if user.IsUserTypeInvalid() {
+		return WarnInvalidUserType, false
+	}
+
+	if user.IsAuthTypeInvalid() {
+		return WarnInvalidAuthType, false
+	}
+
+	if user.IsEmailInvalid() {
+		return WarnInvalidEmail, false
+	}
+
+	if user.IsPasswordInvalid() {
+		return WarnPasswordLength, false
+	}
+
+	password, err := user.HashPassword()
+	if err != nil {
+		return err.Error(), false
+	}
+	user.Password = password
+
+	return "", true
+}
@@ -0,0 +1,64 @@
+package user
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// MaxPasswordRequests is how many password requests a client may send
+	// within PasswordRequestWindow, so emails cannot be probed or flooded.
+	MaxPasswordRequests   = 10
+	PasswordRequestWindow = 15 * time.Minute
+)
+
+// attemptLimiter allows every key at most max attempts within the window.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	attempts map[string][]time.Time
+	sweptAt  time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{max: max, window: window, attempts: make(map[string][]time.Time)}
+}
+
+func (l *attemptLimiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.sweptAt) > l.window {
+		l.sweep(now)
+	}
+
+	recent := l.recent(key, now)
+	if len(recent) >= l.max {
+		l.attempts[key] = recent
+		return false
+	}
+
+	l.attempts[key] = append(recent, now)
+	return true
+}
+
+func (l *attemptLimiter) recent(key string, now time.Time) []time.Time {
+	var recent []time.Time
+	for _, at := range l.attempts[key] {
+		if now.Sub(at) < l.window {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+// sweep forgets the keys without recent attempts, so the map does not grow forever.
+func (l *attemptLimiter) sweep(now time.Time) {
+	for key := range l.attempts {
+		if len(l.recent(key, now)) == 0 {
+			delete(l.attempts, key)
+		}
+	}
+	l.sweptAt = now
+}
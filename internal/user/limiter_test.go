@@ -0,0 +1,56 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter_Allow(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		attempts []time.Duration
+		at       time.Duration
+		expected bool
+	}{
+		{
+			name:     "first attempt",
+			at:       0,
+			expected: true,
+		},
+		{
+			name:     "below limit",
+			attempts: []time.Duration{0},
+			at:       time.Minute,
+			expected: true,
+		},
+		{
+			name:     "limit reached",
+			attempts: []time.Duration{0, time.Minute},
+			at:       2 * time.Minute,
+			expected: false,
+		},
+		{
+			name:     "window passed",
+			attempts: []time.Duration{0, time.Minute},
+			at:       PasswordRequestWindow + time.Minute,
+			expected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newAttemptLimiter(2, PasswordRequestWindow)
+			for _, at := range tt.attempts {
+				limiter.Allow("127.0.0.1", now.Add(at))
+			}
+
+			if got := limiter.Allow("127.0.0.1", now.Add(tt.at)); got != tt.expected {
+				t.Errorf("Allow() = %v, want %v", got, tt.expected)
+			}
+			if !limiter.Allow("10.0.0.1", now.Add(tt.at)) {
+				t.Errorf("Allow() of another key = false, want true")
+			}
+		})
+	}
+}
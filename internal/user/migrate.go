@@ -0,0 +1,49 @@
+package user
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"gorm.io/gorm"
+	"strings"
+)
+
+const userTypeCheck = "chk_users_user_type"
+
+// MigrateUserTypes recreates the check constraint of the user type when it
+// does not allow staff users yet. AutoMigrate creates missing constraints but
+// leaves existing ones as they are.
+func MigrateUserTypes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var definition string
+		err := tx.Raw("SELECT pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = ? AND conrelid = 'users'::regclass", userTypeCheck).
+			Scan(&definition).Error
+		if err != nil {
+			return err
+		}
+		if strings.Contains(definition, "'"+string(auth.Staff)+"'") {
+			return nil
+		}
+
+		if err = tx.Exec("ALTER TABLE users DROP CONSTRAINT IF EXISTS " + userTypeCheck).Error; err != nil {
+			return err
+		}
+
+		return tx.Migrator().CreateConstraint(&User{}, userTypeCheck)
+	})
+}
+
+// MigrateEmailVerified adds the email_verified column to an existing users
+// table with every user verified, since they registered before emails were
+// verified. It runs before AutoMigrate, which would add it as not verified.
+func MigrateEmailVerified(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&User{}) || db.Migrator().HasColumn(&User{}, "EmailVerified") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE users ADD COLUMN email_verified boolean NOT NULL DEFAULT true").Error; err != nil {
+			return err
+		}
+
+		return tx.Exec("ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT false").Error
+	})
+}
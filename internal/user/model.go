@@ -1,6 +1,8 @@
 package user
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -11,17 +13,75 @@ import (
 const (
 	MinPasswordLen int = 5
 	MaxPasswordLen int = 12
+
+	// InvitationTTL is how long a corporate user may register with an invitation.
+	InvitationTTL = 7 * 24 * time.Hour
+
+	// PasswordResetTTL is how long the link of a password reset email works.
+	PasswordResetTTL = 30 * time.Minute
+	// MaxPasswordResets is how many reset emails an account gets within PasswordResetTTL.
+	MaxPasswordResets = 3
 )
 
 type User struct {
-	ID        uint          `gorm:"primarykey"`
-	UserName  string        `gorm:"not null;unique" json:"user_name"`
-	Password  string        `gorm:"not null" json:"password"`
-	UserType  auth.UserType `gorm:"check: user_type in('admin','individual','corporate')" json:"user_type"`
-	Email     string        `gorm:"unique" json:"email"`
+	ID       uint          `gorm:"primarykey"`
+	UserName string        `gorm:"not null;unique" json:"user_name"`
+	Password string        `gorm:"not null" json:"password"`
+	UserType auth.UserType `gorm:"check: user_type in('admin','staff','individual','corporate')" json:"user_type"`
+	Email    string        `gorm:"unique" json:"email"`
+	// Active is false for deactivated users, who cannot login anymore.
+	Active bool `gorm:"not null;default:true" json:"-"`
+	// EmailVerified is set once the user opens the link of the verification email.
+	EmailVerified bool `gorm:"not null;default:false" json:"-"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+
+	// InvitationToken is required to register as a corporate user.
+	InvitationToken string `gorm:"-" json:"invitation_token,omitempty"`
+}
+
+// NewAccount is a user created by an admin, with the roles assigned to them.
+type NewAccount struct {
+	User
+	Roles []auth.Role `json:"roles"`
+}
+
+// Profile is what admins see of a user.
+type Profile struct {
+	ID            uint          `json:"id"`
+	UserName      string        `json:"user_name"`
+	Email         string        `json:"email"`
+	UserType      auth.UserType `json:"user_type"`
+	Active        bool          `json:"active"`
+	EmailVerified bool          `json:"email_verified"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// Invitation lets a corporate user register with the invited email address once.
+type Invitation struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	Email     string     `gorm:"not null;index" json:"email"`
+	TokenHash string     `gorm:"not null;unique" json:"-"`
+	InvitedBy uint       `gorm:"not null" json:"invited_by"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// PasswordReset lets the user set a new password once before it expires.
+type PasswordReset struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"not null;unique"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// IsUsable reports whether the reset has neither been used nor expired.
+func (r *PasswordReset) IsUsable(now time.Time) bool {
+	return r.UsedAt == nil && now.Before(r.ExpiresAt)
 }
 
 func (u *User) IsNameEmpty() bool {
@@ -38,6 +98,8 @@ func (u *User) IsUserTypeValid() bool {
 		fallthrough
 	case auth.Admin:
 		fallthrough
+	case auth.Staff:
+		fallthrough
 	case auth.IndividualUser:
 		return true
 	default:
@@ -45,6 +107,24 @@ func (u *User) IsUserTypeValid() bool {
 	}
 }
 
+// CanSelfRegister reports whether users of the type may register on their
+// own. Admin and staff accounts are only created by admins.
+func (u *User) CanSelfRegister() bool {
+	return u.UserType == auth.IndividualUser || u.UserType == auth.CorporateUser
+}
+
+func (u *User) Profile() Profile {
+	return Profile{
+		ID:            u.ID,
+		UserName:      u.UserName,
+		Email:         u.Email,
+		UserType:      u.UserType,
+		Active:        u.Active,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
 func (u *User) IsUserTypeInvalid() bool {
 	return !u.IsUserTypeValid()
 }
@@ -71,3 +151,8 @@ func (u *User) HashPassword() (string, error) {
 	passwordBytes, err := bcrypt.GenerateFromPassword([]byte(u.Password), 14)
 	return string(passwordBytes), err
 }
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
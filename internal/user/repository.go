@@ -4,20 +4,42 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/dilaragorum/online-ticket-project-go/internal/role"
+	"github.com/dilaragorum/online-ticket-project-go/internal/session"
 	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
 var (
-	ErrNoRecord         = errors.New("there is no record in DB with that username")
-	ErrUniqueViolation  = errors.New("UNIQUE constraint failed")
-	DuplicateEntryError = &pgconn.PgError{Code: "23505"}
+	ErrNoRecord          = errors.New("there is no record in DB with that username")
+	ErrUniqueViolation   = errors.New("UNIQUE constraint failed")
+	ErrUserNotFound      = errors.New("there is no user with that id")
+	ErrInvalidInvitation = errors.New("invitation is unknown, used or expired")
+	ErrInvalidResetToken = errors.New("password reset token is unknown, used or expired")
 )
 
 type Repository interface {
 	Create(ctx context.Context, user *User) error
+	// CreateWithRoles creates the user and assigns the roles in one transaction.
+	CreateWithRoles(ctx context.Context, user *User, roles []auth.Role) error
+	// CreateInvited uses up the invitation of the email of the user and creates the user.
+	CreateInvited(ctx context.Context, user *User, invitationHash string, now time.Time) error
 	GetByUserName(ctx context.Context, username string) (*User, error)
+	GetByID(ctx context.Context, userID uint) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	List(ctx context.Context) ([]User, error)
+	SetActive(ctx context.Context, userID uint, active bool) error
+	// VerifyEmail marks the email of the user verified if it is still the given one.
+	VerifyEmail(ctx context.Context, userID uint, email string) error
+	CreateInvitation(ctx context.Context, invitation *Invitation) error
+	CreatePasswordReset(ctx context.Context, reset *PasswordReset) error
+	CountPasswordResets(ctx context.Context, userID uint, since time.Time) (int64, error)
+	// ResetPassword uses up the reset token, with every other one of its user,
+	// sets the password of the user and ends every session of the user.
+	ResetPassword(ctx context.Context, tokenHash string, password string, now time.Time) error
 }
 
 type defaultRepository struct {
@@ -51,13 +73,202 @@ func (r *defaultRepository) Create(ctx context.Context, user *User) error {
 	defer cancel()
 
 	if err := r.database.WithContext(timeoutCtx).Model(&User{}).Create(user).Error; err != nil {
-		switch {
-		case errors.As(err, &DuplicateEntryError):
+		if isUniqueViolation(err) {
 			return ErrDuplicatedValue
-		default:
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) CreateWithRoles(ctx context.Context, user *User, roles []auth.Role) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			if isUniqueViolation(err) {
+				return ErrDuplicatedValue
+			}
 			return err
 		}
+
+		return role.SetUserRolesIn(tx, user.ID, roles)
+	})
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func (r *defaultRepository) CreateInvited(ctx context.Context, user *User, invitationHash string, now time.Time) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Invitation{}).
+			Where("token_hash = ? AND email = ? AND used_at IS NULL AND expires_at > ?", invitationHash, user.Email, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidInvitation
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			if isUniqueViolation(err) {
+				return ErrDuplicatedValue
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (r *defaultRepository) GetByID(ctx context.Context, userID uint) (*User, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var user User
+
+	if err := r.database.WithContext(timeoutCtx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *defaultRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var user User
+
+	if err := r.database.WithContext(timeoutCtx).Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *defaultRepository) List(ctx context.Context) ([]User, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var users []User
+
+	if err := r.database.WithContext(timeoutCtx).Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *defaultRepository) SetActive(ctx context.Context, userID uint, active bool) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result := r.database.WithContext(timeoutCtx).Model(&User{}).Where("id = ?", userID).Update("active", active)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
 	}
 
 	return nil
 }
+
+func (r *defaultRepository) VerifyEmail(ctx context.Context, userID uint, email string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result := r.database.WithContext(timeoutCtx).Model(&User{}).
+		Where("id = ? AND email = ?", userID, email).
+		Update("email_verified", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) CreateInvitation(ctx context.Context, invitation *Invitation) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return r.database.WithContext(timeoutCtx).Create(invitation).Error
+}
+
+func (r *defaultRepository) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return r.database.WithContext(timeoutCtx).Create(reset).Error
+}
+
+func (r *defaultRepository) CountPasswordResets(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var count int64
+
+	err := r.database.WithContext(timeoutCtx).Model(&PasswordReset{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count).Error
+
+	return count, err
+}
+
+func (r *defaultRepository) ResetPassword(ctx context.Context, tokenHash string, password string, now time.Time) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	return r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		var reset PasswordReset
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", tokenHash).
+			First(&reset).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		if !reset.IsUsable(now) {
+			return ErrInvalidResetToken
+		}
+
+		err = tx.Model(&PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&User{}).Where("id = ?", reset.UserID).Update("password", password)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		// Whoever knew the old password should not stay logged in.
+		return session.RevokeAllIn(tx, reset.UserID, now)
+	})
+}
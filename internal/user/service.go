@@ -2,33 +2,83 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
 	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
 var (
 	ErrDuplicatedValue           = errors.New("username or email should be unique")
 	ErrUsernameNotFound          = errors.New("there is no that username in record")
 	ErrUsernameOrPasswordInvalid = errors.New("invalid username or password")
+	ErrRegistrationNotAllowed    = errors.New("users of this type cannot register on their own")
+	ErrUserDeactivated           = errors.New("user has been deactivated")
+	ErrCannotDeactivateSelf      = errors.New("users cannot deactivate themselves")
+	ErrEmailAlreadyVerified      = errors.New("email has already been verified")
+	ErrTooManyPasswordResets     = errors.New("too many password reset emails have been sent")
 
 	ErrThereIsNoTrip = errors.New("there is no trip which meet these conditions")
 )
 
 type Service interface {
+	// Register creates individual users, and corporate users with an invitation.
 	Register(ctx context.Context, user *User) error
 	Login(ctx context.Context, credentials auth.Credentials) (*User, error)
+	// CreateUser creates a user of any type, e.g. an admin or a staff member.
+	CreateUser(ctx context.Context, account *NewAccount) error
+	List(ctx context.Context) ([]Profile, error)
+	AssignRoles(ctx context.Context, userID uint, roles []auth.Role) error
+	// SetActive deactivates a user, ending every session, or activates them again.
+	SetActive(ctx context.Context, userID uint, active bool, claims auth.Claims) error
+	// Invite returns the token a corporate user registers with.
+	Invite(ctx context.Context, email string, claims auth.Claims) (string, *Invitation, error)
+	// VerificationToken returns the token of the verification email of the user.
+	VerificationToken(ctx context.Context, userID uint) (string, *User, error)
+	VerifyEmail(ctx context.Context, token string) error
+	IsEmailVerified(ctx context.Context, userID uint) (bool, error)
+	// ForgotPassword returns the token of the password reset email of the
+	// active user with the email address.
+	ForgotPassword(ctx context.Context, email string) (string, *User, error)
+	// ResetPassword sets the hashed password and ends every session of the user.
+	ResetPassword(ctx context.Context, token string, password string) error
+}
+
+// SessionRevoker ends every session of a user.
+type SessionRevoker interface {
+	RevokeAll(ctx context.Context, userID uint) error
+}
+
+// RoleAssigner replaces the roles assigned to a user.
+type RoleAssigner interface {
+	CheckRoles(ctx context.Context, roles []auth.Role) error
+	SetUserRoles(ctx context.Context, userID uint, roles []auth.Role) error
 }
 
 type defaultService struct {
 	userRepo Repository
+	sessions SessionRevoker
+	roles    RoleAssigner
+	verifier *Verifier
 }
 
-func NewUserService(repository Repository) Service {
-	return &defaultService{userRepo: repository}
+func NewUserService(repository Repository, sessions SessionRevoker, roles RoleAssigner, verifier *Verifier) Service {
+	return &defaultService{userRepo: repository, sessions: sessions, roles: roles, verifier: verifier}
 }
 
 func (s *defaultService) Register(ctx context.Context, user *User) error {
+	if !user.CanSelfRegister() {
+		return ErrRegistrationNotAllowed
+	}
+
+	if user.UserType == auth.CorporateUser {
+		// The invitation has been sent to the email, so it is verified already.
+		user.EmailVerified = true
+		return s.userRepo.CreateInvited(ctx, user, hashToken(user.InvitationToken), time.Now())
+	}
+
 	err := s.userRepo.Create(ctx, user)
 	if err != nil {
 		switch {
@@ -54,9 +104,170 @@ func (s *defaultService) Login(ctx context.Context, credentials auth.Credentials
 		return nil, ErrUsernameOrPasswordInvalid
 	}
 
+	if !user.Active {
+		return nil, ErrUserDeactivated
+	}
+
 	return user, nil
 }
 
+func (s *defaultService) CreateUser(ctx context.Context, account *NewAccount) error {
+	// Roles are checked first, so a typo does not leave a user without them.
+	if err := s.roles.CheckRoles(ctx, account.Roles); err != nil {
+		return err
+	}
+
+	return s.userRepo.CreateWithRoles(ctx, &account.User, account.Roles)
+}
+
+func (s *defaultService) List(ctx context.Context) ([]Profile, error) {
+	users, err := s.userRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]Profile, 0, len(users))
+	for i := range users {
+		profiles = append(profiles, users[i].Profile())
+	}
+
+	return profiles, nil
+}
+
+func (s *defaultService) AssignRoles(ctx context.Context, userID uint, roles []auth.Role) error {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.roles.SetUserRoles(ctx, userID, roles); err != nil {
+		return err
+	}
+
+	// Tokens carry the permissions, so the user logs in again to get the new ones.
+	return s.sessions.RevokeAll(ctx, userID)
+}
+
+func (s *defaultService) SetActive(ctx context.Context, userID uint, active bool, claims auth.Claims) error {
+	if !active && userID == claims.UserID {
+		return ErrCannotDeactivateSelf
+	}
+
+	if err := s.userRepo.SetActive(ctx, userID, active); err != nil {
+		return err
+	}
+
+	if active {
+		return nil
+	}
+
+	return s.sessions.RevokeAll(ctx, userID)
+}
+
+func (s *defaultService) Invite(ctx context.Context, email string, claims auth.Claims) (string, *Invitation, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	invitation := Invitation{
+		Email:     email,
+		TokenHash: hashToken(token),
+		InvitedBy: claims.UserID,
+		ExpiresAt: time.Now().Add(InvitationTTL),
+	}
+
+	if err = s.userRepo.CreateInvitation(ctx, &invitation); err != nil {
+		return "", nil, err
+	}
+
+	return token, &invitation, nil
+}
+
+func (s *defaultService) VerificationToken(ctx context.Context, userID uint) (string, *User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if user.EmailVerified {
+		return "", nil, ErrEmailAlreadyVerified
+	}
+
+	token, err := s.verifier.Sign(user, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, user, nil
+}
+
+func (s *defaultService) VerifyEmail(ctx context.Context, token string) error {
+	userID, email, err := s.verifier.Verify(token)
+	if err != nil {
+		return err
+	}
+
+	if err = s.userRepo.VerifyEmail(ctx, userID, email); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *defaultService) IsEmailVerified(ctx context.Context, userID uint) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return user.EmailVerified, nil
+}
+
+func (s *defaultService) ForgotPassword(ctx context.Context, email string) (string, *User, error) {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !user.Active {
+		return "", nil, ErrUserDeactivated
+	}
+
+	now := time.Now()
+
+	sent, err := s.userRepo.CountPasswordResets(ctx, user.ID, now.Add(-PasswordResetTTL))
+	if err != nil {
+		return "", nil, err
+	}
+	if sent >= MaxPasswordResets {
+		return "", nil, ErrTooManyPasswordResets
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	reset := PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(PasswordResetTTL),
+	}
+
+	if err = s.userRepo.CreatePasswordReset(ctx, &reset); err != nil {
+		return "", nil, err
+	}
+
+	return token, user, nil
+}
+
+func (s *defaultService) ResetPassword(ctx context.Context, token string, password string) error {
+	return s.userRepo.ResetPassword(ctx, hashToken(token), password, time.Now())
+}
+
 func (s *defaultService) isNotEqualHashAndPassword(hashPassword string, password string) bool {
 	return !s.isEqualHashAndPassword(hashPassword, password)
 }
@@ -67,3 +278,11 @@ func (s *defaultService) isEqualHashAndPassword(hashPassword string, password st
 	}
 	return true
 }
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,7 +1,11 @@
 package user
 
 import (
+	"context"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/dilaragorum/online-ticket-project-go/internal/role"
 	"testing"
+	"time"
 )
 
 func TestUser_IsEmailValid(t *testing.T) {
@@ -30,3 +34,269 @@ func TestUser_IsEmailValid(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_CanSelfRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		userType auth.UserType
+		expected bool
+	}{
+		{name: "individual", userType: auth.IndividualUser, expected: true},
+		{name: "corporate", userType: auth.CorporateUser, expected: true},
+		{name: "admin", userType: auth.Admin, expected: false},
+		{name: "staff", userType: auth.Staff, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &User{UserType: tt.userType}
+			if got := u.CanSelfRegister(); got != tt.expected {
+				t.Errorf("CanSelfRegister() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+type fakeRepository struct {
+	Repository
+	users       map[uint]*User
+	invitations map[string]string
+	resets      map[string]*PasswordReset
+	roles       map[uint][]auth.Role
+	revoked     []uint
+}
+
+func (r *fakeRepository) Create(ctx context.Context, user *User) error {
+	user.ID = uint(len(r.users) + 1)
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeRepository) CreateWithRoles(ctx context.Context, user *User, roles []auth.Role) error {
+	if err := r.Create(ctx, user); err != nil {
+		return err
+	}
+	r.roles[user.ID] = roles
+	return nil
+}
+
+func (r *fakeRepository) CreateInvited(ctx context.Context, user *User, invitationHash string, now time.Time) error {
+	if email, ok := r.invitations[invitationHash]; !ok || email != user.Email {
+		return ErrInvalidInvitation
+	}
+	delete(r.invitations, invitationHash)
+	return r.Create(ctx, user)
+}
+
+func (r *fakeRepository) GetByID(ctx context.Context, userID uint) (*User, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeRepository) SetActive(ctx context.Context, userID uint, active bool) error {
+	user, ok := r.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Active = active
+	return nil
+}
+
+func (r *fakeRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r *fakeRepository) CreatePasswordReset(ctx context.Context, reset *PasswordReset) error {
+	r.resets[reset.TokenHash] = reset
+	return nil
+}
+
+func (r *fakeRepository) CountPasswordResets(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeRepository) ResetPassword(ctx context.Context, tokenHash string, password string, now time.Time) error {
+	reset, ok := r.resets[tokenHash]
+	if !ok || !reset.IsUsable(now) {
+		return ErrInvalidResetToken
+	}
+	for _, other := range r.resets {
+		if other.UserID == reset.UserID && other.UsedAt == nil {
+			other.UsedAt = &now
+		}
+	}
+	r.users[reset.UserID].Password = password
+	r.revoked = append(r.revoked, reset.UserID)
+	return nil
+}
+
+type fakeSessionRevoker struct {
+	revoked []uint
+}
+
+func (f *fakeSessionRevoker) RevokeAll(ctx context.Context, userID uint) error {
+	f.revoked = append(f.revoked, userID)
+	return nil
+}
+
+type fakeRoleAssigner struct {
+	known    map[auth.Role]bool
+	assigned map[uint][]auth.Role
+}
+
+func (f *fakeRoleAssigner) CheckRoles(ctx context.Context, roles []auth.Role) error {
+	for _, r := range roles {
+		if !f.known[r] {
+			return role.ErrRoleNotFound
+		}
+	}
+	return nil
+}
+
+func (f *fakeRoleAssigner) SetUserRoles(ctx context.Context, userID uint, roles []auth.Role) error {
+	if err := f.CheckRoles(ctx, roles); err != nil {
+		return err
+	}
+	f.assigned[userID] = roles
+	return nil
+}
+
+func newTestService() (Service, *fakeRepository, *fakeSessionRevoker, *fakeRoleAssigner) {
+	repo := &fakeRepository{users: map[uint]*User{}, invitations: map[string]string{}, resets: map[string]*PasswordReset{}, roles: map[uint][]auth.Role{}}
+	sessions := &fakeSessionRevoker{}
+	roles := &fakeRoleAssigner{known: map[auth.Role]bool{auth.SupportRole: true}, assigned: map[uint][]auth.Role{}}
+
+	return NewUserService(repo, sessions, roles, nil), repo, sessions, roles
+}
+
+func TestService_Register_Corporate(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		token       string
+		expectedErr error
+	}{
+		{name: "invited email", email: "buyer@company.com", token: "invitation"},
+		{name: "unknown token", email: "buyer@company.com", token: "guess", expectedErr: ErrInvalidInvitation},
+		{name: "token of another email", email: "other@company.com", token: "invitation", expectedErr: ErrInvalidInvitation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo, _, _ := newTestService()
+			repo.invitations[hashToken("invitation")] = "buyer@company.com"
+
+			user := &User{UserName: "buyer", UserType: auth.CorporateUser, Email: tt.email, InvitationToken: tt.token}
+			if err := service.Register(context.Background(), user); err != tt.expectedErr {
+				t.Fatalf("Register() error = %v, want %v", err, tt.expectedErr)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+			if !user.EmailVerified {
+				t.Error("email of an invited user is not verified")
+			}
+			if len(repo.invitations) != 0 {
+				t.Error("invitation is not used up")
+			}
+		})
+	}
+}
+
+func TestService_CreateUser(t *testing.T) {
+	service, repo, _, _ := newTestService()
+
+	account := &NewAccount{User: User{UserName: "agent", UserType: auth.Staff}, Roles: []auth.Role{auth.SupportRole, "suport"}}
+	if err := service.CreateUser(context.Background(), account); err != role.ErrRoleNotFound {
+		t.Fatalf("CreateUser() error = %v, want %v", err, role.ErrRoleNotFound)
+	}
+	if len(repo.users) != 0 {
+		t.Error("user is created with an unknown role")
+	}
+
+	account.Roles = []auth.Role{auth.SupportRole}
+	if err := service.CreateUser(context.Background(), account); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if len(repo.roles[account.ID]) != 1 {
+		t.Errorf("roles = %v, want the user created with its roles", repo.roles[account.ID])
+	}
+}
+
+func TestService_RevokesSessions(t *testing.T) {
+	service, repo, sessions, roles := newTestService()
+	repo.users[7] = &User{ID: 7, Active: true}
+
+	if err := service.AssignRoles(context.Background(), 7, []auth.Role{auth.SupportRole}); err != nil {
+		t.Fatalf("AssignRoles() error = %v", err)
+	}
+	if len(roles.assigned[7]) != 1 || len(sessions.revoked) != 1 {
+		t.Errorf("roles = %v, revoked = %v, want the roles assigned and the sessions revoked", roles.assigned[7], sessions.revoked)
+	}
+
+	if err := service.SetActive(context.Background(), 7, false, auth.Claims{UserID: 1}); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	if repo.users[7].Active || len(sessions.revoked) != 2 {
+		t.Errorf("active = %v, revoked = %v, want the user deactivated and the sessions revoked", repo.users[7].Active, sessions.revoked)
+	}
+
+	if err := service.SetActive(context.Background(), 7, true, auth.Claims{UserID: 1}); err != nil {
+		t.Fatalf("SetActive() error = %v", err)
+	}
+	if len(sessions.revoked) != 2 {
+		t.Errorf("revoked = %v, want no sessions revoked on activation", sessions.revoked)
+	}
+
+	if err := service.SetActive(context.Background(), 1, false, auth.Claims{UserID: 1}); err != ErrCannotDeactivateSelf {
+		t.Errorf("SetActive() of self error = %v, want %v", err, ErrCannotDeactivateSelf)
+	}
+}
+
+func TestService_ResetPassword(t *testing.T) {
+	service, repo, _, _ := newTestService()
+	repo.users[7] = &User{ID: 7, Email: "user@gmail.com", Password: "old", Active: true}
+
+	token, _, err := service.ForgotPassword(context.Background(), "user@gmail.com")
+	if err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+	earlier, _, err := service.ForgotPassword(context.Background(), "user@gmail.com")
+	if err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+	repo.resets[hashToken("expired")] = &PasswordReset{UserID: 7, TokenHash: hashToken("expired"), ExpiresAt: time.Now().Add(-time.Minute)}
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr error
+	}{
+		{name: "expired token", token: "expired", expectedErr: ErrInvalidResetToken},
+		{name: "valid token", token: token},
+		{name: "used token", token: token, expectedErr: ErrInvalidResetToken},
+		{name: "other token of the user", token: earlier, expectedErr: ErrInvalidResetToken},
+		{name: "unknown token", token: "guess", expectedErr: ErrInvalidResetToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password := "new-" + tt.name
+			if err := service.ResetPassword(context.Background(), tt.token, password); err != tt.expectedErr {
+				t.Fatalf("ResetPassword() error = %v, want %v", err, tt.expectedErr)
+			}
+			if changed := repo.users[7].Password == password; changed != (tt.expectedErr == nil) {
+				t.Errorf("password changed = %v, want %v", changed, tt.expectedErr == nil)
+			}
+		})
+	}
+
+	if len(repo.revoked) != 1 {
+		t.Errorf("revoked = %v, want the sessions revoked once", repo.revoked)
+	}
+}
@@ -0,0 +1,29 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/session"
+)
+
+type sessionAccounts struct {
+	repository Repository
+}
+
+// NewSessionAccounts lets sessions load the current name and type of their
+// users when they are refreshed.
+func NewSessionAccounts(repository Repository) session.AccountFinder {
+	return &sessionAccounts{repository: repository}
+}
+
+func (a *sessionAccounts) FindAccount(ctx context.Context, userID uint) (*session.Account, error) {
+	user, err := a.repository.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return &session.Account{}, nil
+		}
+		return nil, err
+	}
+
+	return &session.Account{UserName: user.UserName, UserType: user.UserType, Active: user.Active}, nil
+}
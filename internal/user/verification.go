@@ -0,0 +1,83 @@
+package user
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/spf13/viper"
+	"strconv"
+	"time"
+)
+
+const (
+	// VerificationTTL is how long the link of a verification email works.
+	VerificationTTL = 24 * time.Hour
+
+	verificationAudience = "email-verification"
+)
+
+var (
+	ErrNoVerificationKey        = errors.New("ONLINE_TICKET_GO_EMAIL_VERIFICATION_KEY is not set")
+	ErrInvalidVerificationToken = errors.New("verification token is invalid or expired")
+)
+
+type verificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// Verifier signs the tokens of verification emails. A token carries the email
+// address it was sent to, so it stops working when the user changes it.
+type Verifier struct {
+	secret []byte
+}
+
+func NewVerifier(secret []byte) *Verifier {
+	return &Verifier{secret: secret}
+}
+
+// NewVerifierFromConfig signs with ONLINE_TICKET_GO_EMAIL_VERIFICATION_KEY.
+func NewVerifierFromConfig() (*Verifier, error) {
+	secret := viper.GetString("ONLINE_TICKET_GO_EMAIL_VERIFICATION_KEY")
+	if secret == "" {
+		return nil, ErrNoVerificationKey
+	}
+
+	return NewVerifier([]byte(secret)), nil
+}
+
+func (v *Verifier) Sign(user *User, now time.Time) (string, error) {
+	claims := verificationClaims{
+		Email: user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  jwt.ClaimStrings{verificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(VerificationTTL)),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(v.secret)
+}
+
+// Verify returns the user ID and email address the token was signed for.
+func (v *Verifier) Verify(tokenString string) (uint, string, error) {
+	claims := verificationClaims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(*jwt.Token) (interface{}, error) {
+		return v.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	if !claims.VerifyAudience(verificationAudience, true) || claims.Email == "" {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil || userID == 0 {
+		return 0, "", ErrInvalidVerificationToken
+	}
+
+	return uint(userID), claims.Email, nil
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	verifier := NewVerifier([]byte("secret"))
+	user := &User{ID: 7, Email: "dilaragorum@gmail.com"}
+
+	sign := func(v *Verifier, now time.Time) string {
+		token, err := v.Sign(user, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:  "valid token",
+			token: sign(verifier, time.Now()),
+		},
+		{
+			name:    "expired token",
+			token:   sign(verifier, time.Now().Add(-2*VerificationTTL)),
+			wantErr: true,
+		},
+		{
+			name:    "signed with another secret",
+			token:   sign(NewVerifier([]byte("another")), time.Now()),
+			wantErr: true,
+		},
+		{
+			name:    "malformed token",
+			token:   "token",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, email, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				if err != ErrInvalidVerificationToken {
+					t.Errorf("Verify() error = %v, want %v", err, ErrInvalidVerificationToken)
+				}
+				return
+			}
+			if err != nil || userID != user.ID || email != user.Email {
+				t.Errorf("Verify() = %d, %s, %v, want %d, %s", userID, email, err, user.ID, user.Email)
+			}
+		})
+	}
+}
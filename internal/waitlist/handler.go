@@ -0,0 +1,126 @@
+package waitlist
+
+import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+var (
+	WarnInvalidID         = "Please enter valid ID"
+	WarnInvalidSeats      = "You can wait for between 1 and 5 seats"
+	WarnInvalidEmail      = "Please enter valid email address"
+	WarnTripNotExist      = "This trip does not exist. Please check trip information."
+	WarnFareClassNotFound = "This fare class is not sold on the trip"
+	WarnSeatsAvailable    = "There are available seats on this trip, you can purchase them now"
+	WarnAlreadyWaiting    = "You are already on the waitlist of this trip"
+	WarnEntryNotExist     = "This waitlist entry does not exist"
+	WarnInvalidClaim      = "This claim link is invalid or expired"
+	WarnSystemFailure     = "There is something wrong. Please try again later"
+	SuccessJoinedWaitlist = "You have joined the waitlist. We will let you know when seats are released."
+	SuccessLeftWaitlist   = "You have left the waitlist"
+)
+
+type handler struct {
+	service Service
+}
+
+func NewHandler(routes *auth.Routes, service Service) *handler {
+	h := handler{service: service}
+
+	routes.POST("/waitlist/:tripID", h.Join, auth.RequirePermission(auth.TicketPurchase))
+	routes.GET("/waitlist", h.List, auth.RequirePermission(auth.TicketManage))
+	routes.DELETE("/waitlist/:id", h.Leave, auth.RequirePermission(auth.TicketManage))
+	routes.GET("/waitlist/claim/:token", h.FindOffer, auth.RequirePermission(auth.TicketPurchase))
+
+	return &h
+}
+
+func (h *handler) Join(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	tripID, err := strconv.Atoi(c.Param("tripID"))
+	if err != nil || tripID <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidID)
+	}
+
+	entry := new(Entry)
+	if err = c.Bind(entry); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	entry.TripID = tripID
+	entry.UserID = claim.UserID
+
+	if entry.IsInvalidSeats() {
+		return c.String(http.StatusBadRequest, WarnInvalidSeats)
+	}
+
+	if entry.IsEmailInvalid() {
+		return c.String(http.StatusBadRequest, WarnInvalidEmail)
+	}
+
+	if err = h.service.Join(c.Request().Context(), entry); err != nil {
+		switch {
+		case errors.Is(err, ErrTripNotExist):
+			return c.String(http.StatusBadRequest, WarnTripNotExist)
+		case errors.Is(err, ErrFareClassNotFound):
+			return c.String(http.StatusBadRequest, WarnFareClassNotFound)
+		case errors.Is(err, ErrSeatsAvailable):
+			return c.String(http.StatusBadRequest, WarnSeatsAvailable)
+		case errors.Is(err, ErrAlreadyWaiting):
+			return c.String(http.StatusBadRequest, WarnAlreadyWaiting)
+		default:
+			return c.String(http.StatusInternalServerError, WarnSystemFailure)
+		}
+	}
+
+	return c.String(http.StatusCreated, SuccessJoinedWaitlist)
+}
+
+func (h *handler) List(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	entries, err := h.service.List(c.Request().Context(), claim.UserID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// FindOffer is where the offer email links to. The held seats are bought by
+// purchasing tickets for the trip of the offer with its token as the claim
+// query parameter.
+func (h *handler) FindOffer(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	entry, err := h.service.FindOffer(c.Request().Context(), c.Param("token"), claim.UserID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidClaim) {
+			return c.String(http.StatusNotFound, WarnInvalidClaim)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, entry)
+}
+
+func (h *handler) Leave(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidID)
+	}
+
+	if err = h.service.Leave(c.Request().Context(), uint(id), claim.UserID); err != nil {
+		if errors.Is(err, ErrEntryNotExist) {
+			return c.String(http.StatusNotFound, WarnEntryNotExist)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.String(http.StatusOK, SuccessLeftWaitlist)
+}
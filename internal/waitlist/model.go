@@ -0,0 +1,56 @@
+package waitlist
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"gorm.io/gorm"
+	"net/mail"
+	"time"
+)
+
+type Status string
+
+const (
+	Waiting Status = "waiting"
+	Offered Status = "offered"
+	Claimed Status = "claimed"
+	Expired Status = "expired"
+	Left    Status = "left"
+
+	ClaimWindow = 30 * time.Minute
+	MaxSeats    = 5
+)
+
+// Entry is a place in the queue of a sold-out trip. Once seats are released
+// the entry is offered them and holds them until OfferExpiresAt. A user has
+// at most one active entry per trip.
+type Entry struct {
+	ID             uint               `gorm:"primarykey" json:"id"`
+	TripID         int                `gorm:"not null;index;uniqueIndex:idx_waitlist_active_user,where:(status = 'waiting' OR status = 'offered') AND deleted_at IS NULL" json:"trip_id"`
+	UserID         uint               `gorm:"not null;index;uniqueIndex:idx_waitlist_active_user" json:"user_id"`
+	Email          string             `gorm:"not null" json:"email"`
+	Seats          int                `gorm:"not null;check:seats>0" json:"seats"`
+	FareClass      trip.FareClassName `gorm:"not null;default:economy" json:"fare_class"`
+	Status         Status             `gorm:"not null;index" json:"status"`
+	Token          string             `gorm:"index" json:"-"`
+	OfferExpiresAt *time.Time         `json:"offer_expires_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"-"`
+	DeletedAt      gorm.DeletedAt     `gorm:"index" json:"-"`
+}
+
+func (e *Entry) IsInvalidSeats() bool {
+	return e.Seats <= 0 || e.Seats > MaxSeats
+}
+
+func (e *Entry) IsEmailInvalid() bool {
+	_, err := mail.ParseAddress(e.Email)
+	return err != nil
+}
+
+func (e *Entry) IsActive() bool {
+	return e.Status == Waiting || e.Status == Offered
+}
+
+func (e *Entry) IsOfferExpired(now time.Time) bool {
+	return e.Status == Offered && e.OfferExpiresAt != nil && now.After(*e.OfferExpiresAt)
+}
@@ -0,0 +1,180 @@
+package waitlist
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var ErrEntryNotFound = errors.New("there is no waitlist entry with that id")
+
+type Repository interface {
+	Create(ctx context.Context, entry *Entry) error
+	Update(ctx context.Context, entry *Entry) error
+	FindByID(ctx context.Context, id uint) (*Entry, error)
+	FindByToken(ctx context.Context, token string) (*Entry, error)
+	FindByUser(ctx context.Context, userID uint) ([]Entry, error)
+	FindActive(ctx context.Context, tripID int) ([]Entry, error)
+	FindActiveByUser(ctx context.Context, tripID int, userID uint) (*Entry, error)
+	FindExpiredOffers(ctx context.Context, now time.Time) ([]Entry, error)
+	// Offer offers the free seats of the trip to the next waiting entries and
+	// returns the offered entries. The trip is locked meanwhile, so the same
+	// seats are not offered twice.
+	Offer(ctx context.Context, tripID int, now time.Time) ([]*Entry, error)
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+func (r *defaultRepository) Create(ctx context.Context, entry *Entry) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Create(entry).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrAlreadyWaiting
+		}
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) Update(ctx context.Context, entry *Entry) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).
+		Select("status", "token", "offer_expires_at").
+		Updates(entry).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) FindByID(ctx context.Context, id uint) (*Entry, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *defaultRepository) FindByToken(ctx context.Context, token string) (*Entry, error) {
+	return r.first(ctx, "token = ?", token)
+}
+
+func (r *defaultRepository) FindActiveByUser(ctx context.Context, tripID int, userID uint) (*Entry, error) {
+	return r.first(ctx, "trip_id = ? AND user_id = ? AND status IN ?", tripID, userID, []Status{Waiting, Offered})
+}
+
+func (r *defaultRepository) FindByUser(ctx context.Context, userID uint) ([]Entry, error) {
+	return r.find(ctx, "user_id = ?", userID)
+}
+
+// FindActive returns waiting and offered entries of the trip in FIFO order.
+func (r *defaultRepository) FindActive(ctx context.Context, tripID int) ([]Entry, error) {
+	return r.find(ctx, "trip_id = ? AND status IN ?", tripID, []Status{Waiting, Offered})
+}
+
+func (r *defaultRepository) FindExpiredOffers(ctx context.Context, now time.Time) ([]Entry, error) {
+	return r.find(ctx, "status = ? AND offer_expires_at < ?", Offered, now)
+}
+
+func (r *defaultRepository) Offer(ctx context.Context, tripID int, now time.Time) ([]*Entry, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var offers []*Entry
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		// Orders of the trip lock it too, so the free seats stay free until the offers are written.
+		var locked trip.Trip
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, tripID).Error; err != nil {
+			return err
+		}
+
+		var fareClasses []trip.FareClass
+		if err := tx.Where("trip_id = ?", tripID).Find(&fareClasses).Error; err != nil {
+			return err
+		}
+
+		var entries []Entry
+		if err := tx.Where("trip_id = ? AND status IN ?", tripID, []Status{Waiting, Offered}).
+			Order("created_at, id").Find(&entries).Error; err != nil {
+			return err
+		}
+
+		free := make(map[trip.FareClassName]int, len(fareClasses))
+		for i := range fareClasses {
+			free[fareClasses[i].Name] = int(fareClasses[i].AvailableSeat)
+		}
+
+		offers = nextOffers(entries, free, now)
+
+		for _, entry := range offers {
+			token, err := newToken()
+			if err != nil {
+				return err
+			}
+
+			expiresAt := now.Add(ClaimWindow)
+			entry.Status = Offered
+			entry.Token = token
+			entry.OfferExpiresAt = &expiresAt
+
+			if err = tx.Select("status", "token", "offer_expires_at").Updates(entry).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return offers, nil
+}
+
+func (r *defaultRepository) first(ctx context.Context, query string, args ...interface{}) (*Entry, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var entry Entry
+
+	if err := r.database.WithContext(timeoutCtx).Where(query, args...).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (r *defaultRepository) find(ctx context.Context, query string, args ...interface{}) ([]Entry, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var entries []Entry
+
+	if err := r.database.WithContext(timeoutCtx).Where(query, args...).Order("created_at, id").Find(&entries).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,296 @@
+package waitlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/labstack/gommon/log"
+	"time"
+)
+
+var (
+	ErrTripNotExist      = errors.New("this trip does not exist")
+	ErrFareClassNotFound = errors.New("this fare class is not sold on the trip")
+	ErrSeatsAvailable    = errors.New("there are available seats on this trip")
+	ErrAlreadyWaiting    = errors.New("user is already on the waitlist of this trip")
+	ErrEntryNotExist     = errors.New("this waitlist entry does not exist")
+	ErrInvalidClaim      = errors.New("claim link is invalid or expired")
+)
+
+type Service interface {
+	Join(ctx context.Context, entry *Entry) error
+	Leave(ctx context.Context, id uint, userID uint) error
+	List(ctx context.Context, userID uint) ([]Entry, error)
+	// HeldSeats returns seats of the trip held for offered entries per fare class.
+	HeldSeats(ctx context.Context, tripID int) (map[trip.FareClassName]int, error)
+	// Claim returns the offer behind the token, which should belong to the user and trip.
+	Claim(ctx context.Context, token string, tripID int, userID uint) (*Entry, error)
+	// FindOffer returns the offer behind the token the user has been emailed.
+	FindOffer(ctx context.Context, token string, userID uint) (*Entry, error)
+	Complete(ctx context.Context, entry *Entry) error
+	// SeatsReleased offers released seats of the trip to the queue in FIFO order.
+	SeatsReleased(ctx context.Context, tripID int) error
+	ExpireOffers(ctx context.Context) error
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type defaultService struct {
+	repo                Repository
+	tripRepo            trip.Repository
+	notificationService notification.Service
+	baseURL             string
+}
+
+func NewService(repo Repository, tripRepo trip.Repository, notificationService notification.Service, baseURL string) Service {
+	return &defaultService{repo: repo, tripRepo: tripRepo, notificationService: notificationService, baseURL: baseURL}
+}
+
+func (s *defaultService) Join(ctx context.Context, entry *Entry) error {
+	requestedTrip, err := s.tripRepo.FindByTripID(ctx, entry.TripID)
+	if err != nil {
+		if errors.Is(err, trip.ErrTripNotFound) {
+			return ErrTripNotExist
+		}
+		return err
+	}
+
+	fareClass, err := requestedTrip.FareClass(entry.FareClass)
+	if err != nil {
+		return ErrFareClassNotFound
+	}
+	entry.FareClass = fareClass.Name
+
+	held, err := s.HeldSeats(ctx, entry.TripID)
+	if err != nil {
+		return err
+	}
+
+	if int(fareClass.AvailableSeat)-held[fareClass.Name] >= entry.Seats {
+		return ErrSeatsAvailable
+	}
+
+	if _, err = s.repo.FindActiveByUser(ctx, entry.TripID, entry.UserID); err == nil {
+		return ErrAlreadyWaiting
+	} else if !errors.Is(err, ErrEntryNotFound) {
+		return err
+	}
+
+	entry.Status = Waiting
+
+	return s.repo.Create(ctx, entry)
+}
+
+func (s *defaultService) Leave(ctx context.Context, id uint, userID uint) error {
+	entry, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			return ErrEntryNotExist
+		}
+		return err
+	}
+
+	if entry.UserID != userID || !entry.IsActive() {
+		return ErrEntryNotExist
+	}
+
+	wasOffered := entry.Status == Offered
+
+	entry.Status = Left
+	entry.Token = ""
+	if err = s.repo.Update(ctx, entry); err != nil {
+		return err
+	}
+
+	if wasOffered {
+		return s.SeatsReleased(ctx, entry.TripID)
+	}
+
+	return nil
+}
+
+func (s *defaultService) List(ctx context.Context, userID uint) ([]Entry, error) {
+	return s.repo.FindByUser(ctx, userID)
+}
+
+func (s *defaultService) HeldSeats(ctx context.Context, tripID int) (map[trip.FareClassName]int, error) {
+	entries, err := s.repo.FindActive(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[trip.FareClassName]int)
+	now := time.Now()
+
+	for i := range entries {
+		if entries[i].Status == Offered && !entries[i].IsOfferExpired(now) {
+			held[entries[i].FareClass] += entries[i].Seats
+		}
+	}
+
+	return held, nil
+}
+
+func (s *defaultService) Claim(ctx context.Context, token string, tripID int, userID uint) (*Entry, error) {
+	entry, err := s.FindOffer(ctx, token, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.TripID != tripID {
+		return nil, ErrInvalidClaim
+	}
+
+	return entry, nil
+}
+
+func (s *defaultService) FindOffer(ctx context.Context, token string, userID uint) (*Entry, error) {
+	entry, err := s.repo.FindByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			return nil, ErrInvalidClaim
+		}
+		return nil, err
+	}
+
+	if entry.Status != Offered || entry.IsOfferExpired(time.Now()) || entry.UserID != userID {
+		return nil, ErrInvalidClaim
+	}
+
+	return entry, nil
+}
+
+func (s *defaultService) Complete(ctx context.Context, entry *Entry) error {
+	entry.Status = Claimed
+	entry.Token = ""
+
+	return s.repo.Update(ctx, entry)
+}
+
+func (s *defaultService) SeatsReleased(ctx context.Context, tripID int) error {
+	requestedTrip, err := s.tripRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		if errors.Is(err, trip.ErrTripNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if requestedTrip.IsInPast(time.Now()) {
+		return nil
+	}
+
+	offers, err := s.repo.Offer(ctx, tripID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range offers {
+		if err = s.notifyOffer(ctx, entry, requestedTrip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nextOffers picks the waiting entries the free seats of each fare class can
+// be offered to, after the seats held for current offers.
+func nextOffers(entries []Entry, free map[trip.FareClassName]int, now time.Time) []*Entry {
+	for i := range entries {
+		if entries[i].Status == Offered && !entries[i].IsOfferExpired(now) {
+			free[entries[i].FareClass] -= entries[i].Seats
+		}
+	}
+
+	var offers []*Entry
+	blocked := make(map[trip.FareClassName]bool)
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Status != Waiting || blocked[entry.FareClass] {
+			continue
+		}
+
+		// Later entries of the class wait until the first one fits, so the queue stays FIFO.
+		if free[entry.FareClass] < entry.Seats {
+			blocked[entry.FareClass] = true
+			continue
+		}
+
+		offers = append(offers, entry)
+		free[entry.FareClass] -= entry.Seats
+	}
+
+	return offers
+}
+
+func (s *defaultService) ExpireOffers(ctx context.Context) error {
+	entries, err := s.repo.FindExpiredOffers(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	trips := make(map[int]bool)
+	for i := range entries {
+		entries[i].Status = Expired
+		entries[i].Token = ""
+		if err = s.repo.Update(ctx, &entries[i]); err != nil {
+			return err
+		}
+		trips[entries[i].TripID] = true
+	}
+
+	for tripID := range trips {
+		if err = s.SeatsReleased(ctx, tripID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run expires offers which have not been claimed in time until ctx is done.
+func (s *defaultService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ExpireOffers(ctx); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// notifyOffer emails the claim link of the offer to the user of the entry.
+func (s *defaultService) notifyOffer(ctx context.Context, entry *Entry, requestedTrip *trip.Trip) error {
+	param := notification.Param{
+		Channel: notification.Email,
+		To:      entry.Email,
+		From:    "ticket@company.com",
+		Title:   "Seats Available",
+		Description: fmt.Sprintf(`Good news! %d %s seat/s of your waitlisted trip %s-%s on %s are held for you until %s.
+See your offer here: %s/waitlist/claim/%s`,
+			entry.Seats, entry.FareClass, requestedTrip.From, requestedTrip.To, requestedTrip.DepartureAt,
+			entry.OfferExpiresAt.Format(time.RFC1123), s.baseURL, entry.Token),
+		LogMsg: fmt.Sprintf("Waitlist offer %d for trip %d has been sent to %s", entry.ID, requestedTrip.ID, entry.Email),
+	}
+
+	return s.notificationService.Send(ctx, param)
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,158 @@
+package waitlist
+
+import (
+	"context"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeRepository struct {
+	Repository
+	entries []Entry
+}
+
+func (r *fakeRepository) FindActive(ctx context.Context, tripID int) ([]Entry, error) {
+	var entries []Entry
+	for _, entry := range r.entries {
+		if entry.TripID == tripID && entry.IsActive() {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *fakeRepository) FindByToken(ctx context.Context, token string) (*Entry, error) {
+	for i := range r.entries {
+		if r.entries[i].Token == token {
+			return &r.entries[i], nil
+		}
+	}
+	return nil, ErrEntryNotFound
+}
+
+func TestNextOffers(t *testing.T) {
+	now := time.Now()
+	later := now.Add(ClaimWindow)
+	earlier := now.Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		entries  []Entry
+		free     map[trip.FareClassName]int
+		expected []uint
+	}{
+		{
+			name:     "first in the queue",
+			entries:  []Entry{{ID: 1, Status: Waiting, Seats: 2, FareClass: trip.Economy}, {ID: 2, Status: Waiting, Seats: 1, FareClass: trip.Economy}},
+			free:     map[trip.FareClassName]int{trip.Economy: 2},
+			expected: []uint{1},
+		},
+		{
+			name:    "later entries wait for the first one",
+			entries: []Entry{{ID: 1, Status: Waiting, Seats: 3, FareClass: trip.Economy}, {ID: 2, Status: Waiting, Seats: 1, FareClass: trip.Economy}},
+			free:    map[trip.FareClassName]int{trip.Economy: 2},
+		},
+		{
+			name: "seats of a current offer are held",
+			entries: []Entry{
+				{ID: 1, Status: Offered, Seats: 2, FareClass: trip.Economy, OfferExpiresAt: &later},
+				{ID: 2, Status: Waiting, Seats: 1, FareClass: trip.Economy},
+			},
+			free: map[trip.FareClassName]int{trip.Economy: 2},
+		},
+		{
+			name: "seats of an expired offer are free",
+			entries: []Entry{
+				{ID: 1, Status: Offered, Seats: 2, FareClass: trip.Economy, OfferExpiresAt: &earlier},
+				{ID: 2, Status: Waiting, Seats: 1, FareClass: trip.Economy},
+			},
+			free:     map[trip.FareClassName]int{trip.Economy: 2},
+			expected: []uint{2},
+		},
+		{
+			name: "queues of fare classes are separate",
+			entries: []Entry{
+				{ID: 1, Status: Waiting, Seats: 2, FareClass: trip.Business},
+				{ID: 2, Status: Waiting, Seats: 1, FareClass: trip.Economy},
+			},
+			free:     map[trip.FareClassName]int{trip.Economy: 1, trip.Business: 1},
+			expected: []uint{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint
+			for _, entry := range nextOffers(tt.entries, tt.free, now) {
+				got = append(got, entry.ID)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("nextOffers() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestService_HeldSeats(t *testing.T) {
+	later := time.Now().Add(ClaimWindow)
+	earlier := time.Now().Add(-time.Minute)
+
+	service := NewService(&fakeRepository{entries: []Entry{
+		{ID: 1, TripID: 1, Status: Offered, Seats: 2, FareClass: trip.Economy, OfferExpiresAt: &later},
+		{ID: 2, TripID: 1, Status: Offered, Seats: 1, FareClass: trip.Business, OfferExpiresAt: &later},
+		{ID: 3, TripID: 1, Status: Offered, Seats: 4, FareClass: trip.Economy, OfferExpiresAt: &earlier},
+		{ID: 4, TripID: 1, Status: Waiting, Seats: 1, FareClass: trip.Economy},
+		{ID: 5, TripID: 2, Status: Offered, Seats: 1, FareClass: trip.Economy, OfferExpiresAt: &later},
+	}}, nil, nil, "")
+
+	held, err := service.HeldSeats(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[trip.FareClassName]int{trip.Economy: 2, trip.Business: 1}
+	if !reflect.DeepEqual(held, expected) {
+		t.Errorf("HeldSeats() = %v, want %v", held, expected)
+	}
+}
+
+func TestService_Claim(t *testing.T) {
+	later := time.Now().Add(ClaimWindow)
+	earlier := time.Now().Add(-time.Minute)
+
+	service := NewService(&fakeRepository{entries: []Entry{
+		{ID: 1, TripID: 1, UserID: 7, Status: Offered, Token: "offer", OfferExpiresAt: &later},
+		{ID: 2, TripID: 1, UserID: 7, Status: Offered, Token: "expired", OfferExpiresAt: &earlier},
+		{ID: 3, TripID: 1, UserID: 7, Status: Claimed, Token: "claimed"},
+	}}, nil, nil, "")
+
+	tests := []struct {
+		name    string
+		token   string
+		tripID  int
+		userID  uint
+		wantErr bool
+	}{
+		{name: "valid offer", token: "offer", tripID: 1, userID: 7},
+		{name: "another trip", token: "offer", tripID: 2, userID: 7, wantErr: true},
+		{name: "another user", token: "offer", tripID: 1, userID: 8, wantErr: true},
+		{name: "expired offer", token: "expired", tripID: 1, userID: 7, wantErr: true},
+		{name: "claimed offer", token: "claimed", tripID: 1, userID: 7, wantErr: true},
+		{name: "unknown token", token: "unknown", tripID: 1, userID: 7, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := service.Claim(context.Background(), tt.token, tt.tripID, tt.userID)
+			if tt.wantErr {
+				if err != ErrInvalidClaim {
+					t.Errorf("Claim() error = %v, want %v", err, ErrInvalidClaim)
+				}
+				return
+			}
+			if err != nil || entry.ID != 1 {
+				t.Errorf("Claim() = %v, %v, want entry 1", entry, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+var (
+	WarnInvalidCompensation = "Please enter a user and a positive amount"
+	WarnSystemFailure       = "There is something wrong. Please try again later"
+	SuccessCompensated      = "Compensation was successfully credited"
+)
+
+// Grant is a compensation credited by an admin to the wallet of a user.
+type Grant struct {
+	UserID    uint    `json:"user_id"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+type handler struct {
+	service Service
+}
+
+func NewHandler(routes *auth.Routes, service Service) *handler {
+	h := handler{service: service}
+
+	routes.GET("/wallet", h.Balance, auth.RequirePermission(auth.AccountManage))
+	routes.GET("/wallet/history", h.History, auth.RequirePermission(auth.AccountManage))
+	routes.POST("/wallet/compensations", h.Compensate, auth.RequirePermission(auth.WalletCompensate))
+
+	return &h
+}
+
+func (h *handler) Balance(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	wallet, err := h.service.Balance(c.Request().Context(), claim.UserID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, wallet)
+}
+
+func (h *handler) History(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	movements, err := h.service.History(c.Request().Context(), claim.UserID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, movements)
+}
+
+func (h *handler) Compensate(c echo.Context) error {
+	var grant Grant
+	if err := c.Bind(&grant); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	if grant.UserID == 0 || IsInvalidAmount(grant.Amount) {
+		return c.String(http.StatusBadRequest, WarnInvalidCompensation)
+	}
+
+	if err := h.service.Credit(c.Request().Context(), grant.UserID, grant.Amount, Compensation, grant.Reference); err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.String(http.StatusCreated, SuccessCompensated)
+}
@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type Reason string
+
+const (
+	Refund       Reason = "refund"
+	NoShowCredit Reason = "no_show_credit"
+	Compensation Reason = "compensation"
+	Purchase     Reason = "purchase"
+	Reversal     Reason = "reversal"
+)
+
+// Account names a side of the ledger. Every user has a wallet account, money
+// given to or taken from users is booked against a system account.
+type Account string
+
+const (
+	SalesAccount         Account = "system:sales"
+	RefundsAccount       Account = "system:refunds"
+	CompensationsAccount Account = "system:compensations"
+)
+
+// reasonAccounts tells which system account is the other side of a user
+// entry booked for each reason.
+var reasonAccounts = map[Reason]Account{
+	Refund:       RefundsAccount,
+	NoShowCredit: RefundsAccount,
+	Compensation: CompensationsAccount,
+	Purchase:     SalesAccount,
+	Reversal:     SalesAccount,
+}
+
+func UserAccount(userID uint) Account {
+	return Account(fmt.Sprintf("user:%d", userID))
+}
+
+// Wallet keeps the balance of a user in step with the ledger, so it can be
+// checked and changed atomically.
+type Wallet struct {
+	UserID    uint      `gorm:"primarykey" json:"user_id"`
+	Balance   float64   `gorm:"not null;default:0;check:balance>=0" json:"balance"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Transaction is a movement of money, booked as entries which sum up to zero.
+// A no-show credit is booked once per reference, so it can be retried.
+type Transaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Reason    Reason    `gorm:"not null;uniqueIndex:idx_wallet_transaction_credit,where:reason = 'no_show_credit'" json:"reason"`
+	Reference string    `gorm:"uniqueIndex:idx_wallet_transaction_credit" json:"reference,omitempty"`
+	Entries   []Entry   `json:"entries"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Entry credits its account when Amount is positive and debits it when negative.
+type Entry struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	TransactionID uint      `gorm:"not null;index" json:"transaction_id"`
+	Account       Account   `gorm:"not null;index" json:"account"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Movement is an entry of a user wallet with the reason it was booked for.
+type Movement struct {
+	TransactionID uint      `json:"transaction_id"`
+	Reason        Reason    `json:"reason"`
+	Reference     string    `json:"reference,omitempty"`
+	Amount        float64   `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (Transaction) TableName() string {
+	return "wallet_transactions"
+}
+
+func (Entry) TableName() string {
+	return "wallet_entries"
+}
+
+func (r Reason) IsValid() bool {
+	_, ok := reasonAccounts[r]
+	return ok
+}
+
+// newTransaction books amount on the wallet of the user, a credit when
+// positive and a debit when negative, against the system account of the reason.
+func newTransaction(userID uint, amount float64, reason Reason, reference string) Transaction {
+	return Transaction{
+		Reason:    reason,
+		Reference: reference,
+		Entries: []Entry{
+			{Account: UserAccount(userID), Amount: amount},
+			{Account: reasonAccounts[reason], Amount: -amount},
+		},
+	}
+}
+
+// IsBalanced reports whether the entries of the transaction sum up to zero.
+func (t *Transaction) IsBalanced() bool {
+	var sum float64
+	for _, entry := range t.Entries {
+		sum += entry.Amount
+	}
+	return len(t.Entries) >= 2 && math.Abs(sum) < 1e-9
+}
+
+func IsInvalidAmount(amount float64) bool {
+	return amount <= 0 || math.IsNaN(amount) || math.IsInf(amount, 0)
+}
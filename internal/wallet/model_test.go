@@ -0,0 +1,43 @@
+package wallet
+
+import (
+	"testing"
+)
+
+func TestNewTransaction(t *testing.T) {
+	tests := []struct {
+		name          string
+		amount        float64
+		reason        Reason
+		systemAccount Account
+	}{
+		{name: "refund credit", amount: 120.5, reason: Refund, systemAccount: RefundsAccount},
+		{name: "compensation", amount: 50, reason: Compensation, systemAccount: CompensationsAccount},
+		{name: "purchase debit", amount: -80, reason: Purchase, systemAccount: SalesAccount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transaction := newTransaction(7, tt.amount, tt.reason, "order:1")
+
+			if !transaction.IsBalanced() {
+				t.Fatalf("IsBalanced() = false, entries %+v", transaction.Entries)
+			}
+
+			user, system := transaction.Entries[0], transaction.Entries[1]
+			if user.Account != UserAccount(7) || user.Amount != tt.amount {
+				t.Errorf("user entry = %+v, want %v on %v", user, tt.amount, UserAccount(7))
+			}
+			if system.Account != tt.systemAccount || system.Amount != -tt.amount {
+				t.Errorf("system entry = %+v, want %v on %v", system, -tt.amount, tt.systemAccount)
+			}
+		})
+	}
+}
+
+func TestTransaction_IsBalanced(t *testing.T) {
+	transaction := Transaction{Entries: []Entry{{Account: UserAccount(1), Amount: 10}, {Account: SalesAccount, Amount: -9}}}
+
+	if transaction.IsBalanced() {
+		t.Errorf("IsBalanced() = true, want false")
+	}
+}
@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("wallet balance is not enough")
+	ErrAlreadyRecorded     = errors.New("transaction has already been recorded for this reference")
+)
+
+type Repository interface {
+	Record(ctx context.Context, userID uint, transaction *Transaction) error
+	FindWallet(ctx context.Context, userID uint) (*Wallet, error)
+	FindMovements(ctx context.Context, userID uint) ([]Movement, error)
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+// Record books the transaction and changes the balance of the user wallet by
+// its entry in a single transaction. A debit larger than the balance fails
+// with ErrInsufficientBalance, booking a transaction again with
+// ErrAlreadyRecorded.
+func (r *defaultRepository) Record(ctx context.Context, userID uint, transaction *Transaction) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var amount float64
+	for _, entry := range transaction.Entries {
+		if entry.Account == UserAccount(userID) {
+			amount += entry.Amount
+		}
+	}
+
+	err := r.database.WithContext(timeoutCtx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Wallet{UserID: userID}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&Wallet{}).
+			Where("user_id = ? AND balance + ? >= 0", userID, amount).
+			Update("balance", gorm.Expr("balance + ?", amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientBalance
+		}
+
+		if err := tx.Create(transaction).Error; err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrAlreadyRecorded
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil && err != ErrInsufficientBalance && err != ErrAlreadyRecorded {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// FindWallet returns an empty wallet for users who have never been credited.
+func (r *defaultRepository) FindWallet(ctx context.Context, userID uint) (*Wallet, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	wallet := Wallet{UserID: userID}
+
+	if err := r.database.WithContext(timeoutCtx).First(&wallet, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Wallet{UserID: userID}, nil
+		}
+		log.Error(err)
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+func (r *defaultRepository) FindMovements(ctx context.Context, userID uint) ([]Movement, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var movements []Movement
+
+	if err := r.database.WithContext(timeoutCtx).Table("wallet_entries").
+		Select("wallet_entries.transaction_id, wallet_transactions.reason, wallet_transactions.reference, wallet_entries.amount, wallet_entries.created_at").
+		Joins("JOIN wallet_transactions ON wallet_transactions.id = wallet_entries.transaction_id").
+		Where("wallet_entries.account = ?", UserAccount(userID)).
+		Order("wallet_entries.id DESC").
+		Scan(&movements).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return movements, nil
+}
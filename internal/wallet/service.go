@@ -0,0 +1,67 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrInvalidAmount = errors.New("amount should be positive")
+	ErrInvalidReason = errors.New("reason is invalid")
+)
+
+type Service interface {
+	// Credit adds amount to the wallet of the user, e.g. for a refund.
+	Credit(ctx context.Context, userID uint, amount float64, reason Reason, reference string) error
+	// Debit takes amount from the wallet of the user, e.g. to pay a purchase.
+	Debit(ctx context.Context, userID uint, amount float64, reason Reason, reference string) error
+	Balance(ctx context.Context, userID uint) (*Wallet, error)
+	History(ctx context.Context, userID uint) ([]Movement, error)
+}
+
+type defaultService struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &defaultService{repo: repo}
+}
+
+func (s *defaultService) Credit(ctx context.Context, userID uint, amount float64, reason Reason, reference string) error {
+	if IsInvalidAmount(amount) {
+		return ErrInvalidAmount
+	}
+	return s.record(ctx, userID, amount, reason, reference)
+}
+
+func (s *defaultService) Debit(ctx context.Context, userID uint, amount float64, reason Reason, reference string) error {
+	if IsInvalidAmount(amount) {
+		return ErrInvalidAmount
+	}
+	return s.record(ctx, userID, -amount, reason, reference)
+}
+
+func (s *defaultService) record(ctx context.Context, userID uint, amount float64, reason Reason, reference string) error {
+	if !reason.IsValid() {
+		return ErrInvalidReason
+	}
+
+	transaction := newTransaction(userID, amount, reason, reference)
+	if !transaction.IsBalanced() {
+		return ErrInvalidAmount
+	}
+
+	if err := s.repo.Record(ctx, userID, &transaction); err != nil && !errors.Is(err, ErrAlreadyRecorded) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *defaultService) Balance(ctx context.Context, userID uint) (*Wallet, error) {
+	return s.repo.FindWallet(ctx, userID)
+}
+
+func (s *defaultService) History(ctx context.Context, userID uint) ([]Movement, error) {
+	return s.repo.FindMovements(ctx, userID)
+}
@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+type fakeRepository struct {
+	Repository
+	balances map[uint]float64
+	recorded map[string]bool
+}
+
+func (r *fakeRepository) Record(ctx context.Context, userID uint, transaction *Transaction) error {
+	key := string(transaction.Reason) + transaction.Reference
+	if transaction.Reason == NoShowCredit && r.recorded[key] {
+		return ErrAlreadyRecorded
+	}
+
+	amount := transaction.Entries[0].Amount
+	if r.balances[userID]+amount < 0 {
+		return ErrInsufficientBalance
+	}
+	r.balances[userID] += amount
+	r.recorded[key] = true
+	return nil
+}
+
+func TestService_Debit(t *testing.T) {
+	tests := []struct {
+		name            string
+		amount          float64
+		reason          Reason
+		expectedErr     error
+		expectedBalance float64
+	}{
+		{name: "purchase", amount: 30, reason: Purchase, expectedBalance: 20},
+		{name: "more than the balance", amount: 60, reason: Purchase, expectedErr: ErrInsufficientBalance, expectedBalance: 50},
+		{name: "negative amount", amount: -30, reason: Purchase, expectedErr: ErrInvalidAmount, expectedBalance: 50},
+		{name: "not a number", amount: math.NaN(), reason: Purchase, expectedErr: ErrInvalidAmount, expectedBalance: 50},
+		{name: "infinite amount", amount: math.Inf(1), reason: Purchase, expectedErr: ErrInvalidAmount, expectedBalance: 50},
+		{name: "unknown reason", amount: 30, reason: "gift", expectedErr: ErrInvalidReason, expectedBalance: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{balances: map[uint]float64{7: 50}, recorded: map[string]bool{}}
+			service := NewService(repo)
+
+			if err := service.Debit(context.Background(), 7, tt.amount, tt.reason, "order:1"); err != tt.expectedErr {
+				t.Errorf("Debit() error = %v, want %v", err, tt.expectedErr)
+			}
+			if repo.balances[7] != tt.expectedBalance {
+				t.Errorf("balance = %v, want %v", repo.balances[7], tt.expectedBalance)
+			}
+		})
+	}
+}
+
+func TestService_Credit_Recorded(t *testing.T) {
+	repo := &fakeRepository{balances: map[uint]float64{}, recorded: map[string]bool{}}
+	service := NewService(repo)
+
+	for i := 0; i < 2; i++ {
+		if err := service.Credit(context.Background(), 7, 20, NoShowCredit, "ticket:1"); err != nil {
+			t.Fatalf("Credit() error = %v", err)
+		}
+	}
+
+	if repo.balances[7] != 20 {
+		t.Errorf("balance = %v, want a retried credit to be booked once", repo.balances[7])
+	}
+}
@@ -0,0 +1,94 @@
+package watch
+
+import (
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/auth"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+var (
+	WarnEmptyRoute       = "Please enter at least departure or destination place"
+	WarnInvalidEmail     = "Please enter valid email address"
+	WarnInvalidPrice     = "Please enter valid price"
+	WarnInvalidID        = "Please enter valid ID"
+	WarnWatchNotExist    = "This watch does not exist"
+	WarnExceedWatchLimit = "You have reached the maximum number of watches"
+	WarnSystemFailure    = "There is something wrong. Please try again later"
+)
+
+type handler struct {
+	service Service
+}
+
+func NewHandler(routes *auth.Routes, service Service) *handler {
+	h := handler{service: service}
+
+	routes.POST("/watches", h.Create, auth.RequirePermission(auth.AccountManage))
+	routes.GET("/watches", h.List, auth.RequirePermission(auth.AccountManage))
+	routes.DELETE("/watches/:id", h.Delete, auth.RequirePermission(auth.AccountManage))
+
+	return &h
+}
+
+func (h *handler) Create(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	watch := new(Watch)
+	if err := c.Bind(watch); err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	watch.ID = 0
+	watch.UserID = claim.UserID
+
+	if watch.CheckFieldsEmpty() {
+		return c.String(http.StatusBadRequest, WarnEmptyRoute)
+	}
+
+	if watch.IsEmailInvalid() {
+		return c.String(http.StatusBadRequest, WarnInvalidEmail)
+	}
+
+	if watch.IsInvalidPrice() {
+		return c.String(http.StatusBadRequest, WarnInvalidPrice)
+	}
+
+	if err := h.service.Create(c.Request().Context(), watch); err != nil {
+		if errors.Is(err, ErrExceedWatchLimit) {
+			return c.String(http.StatusBadRequest, WarnExceedWatchLimit)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusCreated, watch)
+}
+
+func (h *handler) List(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	watches, err := h.service.List(c.Request().Context(), claim.UserID)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.JSON(http.StatusOK, watches)
+}
+
+func (h *handler) Delete(c echo.Context) error {
+	claim := c.Get("claim").(auth.Claims)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return c.String(http.StatusBadRequest, WarnInvalidID)
+	}
+
+	if err = h.service.Delete(c.Request().Context(), uint(id), claim.UserID); err != nil {
+		if errors.Is(err, ErrWatchNotExist) {
+			return c.String(http.StatusNotFound, WarnWatchNotExist)
+		}
+		return c.String(http.StatusInternalServerError, WarnSystemFailure)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
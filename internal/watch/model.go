@@ -0,0 +1,87 @@
+package watch
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"gorm.io/gorm"
+	"net/mail"
+	"time"
+)
+
+type AlertKind string
+
+const (
+	NewTrip   AlertKind = "new_trip"
+	PriceDrop AlertKind = "price_drop"
+
+	MaxWatchesPerUser = 20
+)
+
+// Watch is a saved search of a user. Empty fields match any trip and a zero
+// MaxPrice matches any price.
+type Watch struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	UserID    uint           `gorm:"not null;index" json:"user_id"`
+	Email     string         `gorm:"not null" json:"email"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Vehicle   trip.Vehicle   `json:"vehicle"`
+	Date      *time.Time     `json:"date,omitempty"`
+	MaxPrice  float64        `gorm:"not null;default:0" json:"max_price"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Alert records a notification sent for a watch, so the same trip is not
+// announced twice unless its price drops further.
+type Alert struct {
+	ID        uint      `gorm:"primarykey"`
+	WatchID   uint      `gorm:"not null;uniqueIndex:idx_alert_member"`
+	TripID    int       `gorm:"not null;uniqueIndex:idx_alert_member"`
+	Price     float64   `gorm:"not null;uniqueIndex:idx_alert_member"`
+	Kind      AlertKind `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (w *Watch) CheckFieldsEmpty() bool {
+	return w.From == "" && w.To == ""
+}
+
+func (w *Watch) IsEmailInvalid() bool {
+	_, err := mail.ParseAddress(w.Email)
+	return err != nil
+}
+
+func (w *Watch) IsInvalidPrice() bool {
+	return w.MaxPrice < 0
+}
+
+// Matches reports whether the trip meets the saved search. The date matches
+// the calendar day in the time zone the date was given in.
+func (w *Watch) Matches(t *trip.Trip) bool {
+	if w.From != "" && w.From != t.From {
+		return false
+	}
+
+	if w.To != "" && w.To != t.To {
+		return false
+	}
+
+	if w.Vehicle != "" && w.Vehicle != t.Vehicle {
+		return false
+	}
+
+	if w.Date != nil {
+		year, month, day := w.Date.Date()
+		tripYear, tripMonth, tripDay := t.Date.In(w.Date.Location()).Date()
+		if year != tripYear || month != tripMonth || day != tripDay {
+			return false
+		}
+	}
+
+	if w.MaxPrice > 0 && t.Price > w.MaxPrice {
+		return false
+	}
+
+	return t.AvailableSeat > 0
+}
@@ -0,0 +1,36 @@
+package watch
+
+import (
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"testing"
+	"time"
+)
+
+func TestWatch_Matches(t *testing.T) {
+	istanbul := time.FixedZone("Europe/Istanbul", 3*60*60)
+	day := time.Date(2023, 3, 1, 0, 0, 0, 0, istanbul)
+
+	// Departs at 01:30 in Istanbul, which is still the previous day in UTC.
+	earlyTrip := &trip.Trip{From: "Istanbul", To: "Izmir", Vehicle: trip.VehicleBus, Date: time.Date(2023, 2, 28, 22, 30, 0, 0, time.UTC), Price: 300, AvailableSeat: 10}
+
+	tests := []struct {
+		name     string
+		watch    Watch
+		trip     *trip.Trip
+		expected bool
+	}{
+		{name: "route matches", watch: Watch{From: "Istanbul", To: "Izmir"}, trip: earlyTrip, expected: true},
+		{name: "destination differs", watch: Watch{From: "Istanbul", To: "Ankara"}, trip: earlyTrip, expected: false},
+		{name: "date in watch time zone", watch: Watch{From: "Istanbul", Date: &day}, trip: earlyTrip, expected: true},
+		{name: "price above threshold", watch: Watch{To: "Izmir", MaxPrice: 250}, trip: earlyTrip, expected: false},
+		{name: "price below threshold", watch: Watch{To: "Izmir", MaxPrice: 350}, trip: earlyTrip, expected: true},
+		{name: "vehicle differs", watch: Watch{To: "Izmir", Vehicle: trip.VehicleFlight}, trip: earlyTrip, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.watch.Matches(tt.trip); got != tt.expected {
+				t.Errorf("Matches() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
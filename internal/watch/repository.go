@@ -0,0 +1,136 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/labstack/gommon/log"
+	"gorm.io/gorm"
+	"time"
+)
+
+var ErrWatchNotFound = errors.New("there is no watch with that id")
+
+type Repository interface {
+	Create(ctx context.Context, watch *Watch) error
+	Delete(ctx context.Context, id uint, userID uint) error
+	FindByUser(ctx context.Context, userID uint) ([]Watch, error)
+	CountByUser(ctx context.Context, userID uint) (int, error)
+	// FindCandidates narrows the watches down to the ones which may match the trip.
+	FindCandidates(ctx context.Context, t *trip.Trip) ([]Watch, error)
+	LowestAlertPrice(ctx context.Context, watchID uint, tripID int) (float64, bool, error)
+	CreateAlert(ctx context.Context, alert *Alert) error
+}
+
+type defaultRepository struct {
+	database *gorm.DB
+}
+
+func NewRepository(database *gorm.DB) Repository {
+	return &defaultRepository{database: database}
+}
+
+func (r *defaultRepository) Create(ctx context.Context, watch *Watch) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Create(watch).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) Delete(ctx context.Context, id uint, userID uint) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	result := r.database.WithContext(timeoutCtx).Where("user_id = ?", userID).Delete(&Watch{}, id)
+	if result.Error != nil {
+		log.Error(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrWatchNotFound
+	}
+
+	return nil
+}
+
+func (r *defaultRepository) FindByUser(ctx context.Context, userID uint) ([]Watch, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var watches []Watch
+
+	if err := r.database.WithContext(timeoutCtx).Where("user_id = ?", userID).Order("id").Find(&watches).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return watches, nil
+}
+
+func (r *defaultRepository) CountByUser(ctx context.Context, userID uint) (int, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var count int64
+
+	if err := r.database.WithContext(timeoutCtx).Model(&Watch{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		log.Error(err)
+		return -1, err
+	}
+
+	return int(count), nil
+}
+
+func (r *defaultRepository) FindCandidates(ctx context.Context, t *trip.Trip) ([]Watch, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var watches []Watch
+
+	if err := r.database.WithContext(timeoutCtx).
+		Where(`("from" = '' OR "from" = ?) AND ("to" = '' OR "to" = ?)`, t.From, t.To).
+		Where("(max_price = 0 OR max_price >= ?)", t.Price).
+		Find(&watches).Error; err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return watches, nil
+}
+
+// LowestAlertPrice returns the lowest price already announced for the trip.
+func (r *defaultRepository) LowestAlertPrice(ctx context.Context, watchID uint, tripID int) (float64, bool, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var alert Alert
+
+	err := r.database.WithContext(timeoutCtx).Where("watch_id = ? AND trip_id = ?", watchID, tripID).Order("price").First(&alert).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
+		log.Error(err)
+		return 0, false, err
+	}
+
+	return alert.Price, true, nil
+}
+
+func (r *defaultRepository) CreateAlert(ctx context.Context, alert *Alert) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := r.database.WithContext(timeoutCtx).Create(alert).Error; err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"github.com/labstack/gommon/log"
+)
+
+var (
+	ErrWatchNotExist    = errors.New("this watch does not exist")
+	ErrExceedWatchLimit = fmt.Errorf("a user cannot have more than %d watches", MaxWatchesPerUser)
+)
+
+// Service stores saved searches and, as a trip.Listener, tells their owners
+// about new trips and price drops.
+type Service interface {
+	trip.Listener
+	Create(ctx context.Context, watch *Watch) error
+	Delete(ctx context.Context, id uint, userID uint) error
+	List(ctx context.Context, userID uint) ([]Watch, error)
+}
+
+type defaultService struct {
+	repo                Repository
+	notificationService notification.Service
+}
+
+func NewService(repo Repository, notificationService notification.Service) Service {
+	return &defaultService{repo: repo, notificationService: notificationService}
+}
+
+func (s *defaultService) Create(ctx context.Context, watch *Watch) error {
+	count, err := s.repo.CountByUser(ctx, watch.UserID)
+	if err != nil {
+		return err
+	}
+
+	if count >= MaxWatchesPerUser {
+		return ErrExceedWatchLimit
+	}
+
+	return s.repo.Create(ctx, watch)
+}
+
+func (s *defaultService) Delete(ctx context.Context, id uint, userID uint) error {
+	if err := s.repo.Delete(ctx, id, userID); err != nil {
+		if errors.Is(err, ErrWatchNotFound) {
+			return ErrWatchNotExist
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *defaultService) List(ctx context.Context, userID uint) ([]Watch, error) {
+	return s.repo.FindByUser(ctx, userID)
+}
+
+func (s *defaultService) TripCreated(ctx context.Context, t *trip.Trip) {
+	if err := s.evaluate(ctx, t, NewTrip); err != nil {
+		log.Error(err)
+	}
+}
+
+func (s *defaultService) TripPriceChanged(ctx context.Context, t *trip.Trip) {
+	if err := s.evaluate(ctx, t, PriceDrop); err != nil {
+		log.Error(err)
+	}
+}
+
+// evaluate notifies the owners of matching watches, unless they have already
+// been told about the trip at the same or a lower price. An alert is only
+// recorded once it has been sent, so a failed email is sent again the next
+// time the trip is evaluated.
+func (s *defaultService) evaluate(ctx context.Context, t *trip.Trip, kind AlertKind) error {
+	watches, err := s.repo.FindCandidates(ctx, t)
+	if err != nil {
+		return err
+	}
+
+	for i := range watches {
+		watch := watches[i]
+		if !watch.Matches(t) {
+			continue
+		}
+
+		lowest, alerted, err := s.repo.LowestAlertPrice(ctx, watch.ID, t.ID)
+		if err != nil {
+			return err
+		}
+
+		if alerted && t.Price >= lowest {
+			continue
+		}
+
+		if err = s.notificationService.Send(ctx, alertParam(&watch, t, kind)); err != nil {
+			log.Error(err)
+			continue
+		}
+
+		if err = s.repo.CreateAlert(ctx, &Alert{WatchID: watch.ID, TripID: t.ID, Price: t.Price, Kind: kind}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func alertParam(watch *Watch, t *trip.Trip, kind AlertKind) notification.Param {
+	title := "New Trip For Your Search"
+	if kind == PriceDrop {
+		title = "Price Drop For Your Search"
+	}
+
+	return notification.Param{
+		Channel: notification.Email,
+		To:      watch.Email,
+		From:    "ticket@company.com",
+		Title:   title,
+		Description: fmt.Sprintf(`A trip meeting your saved search is available:
+FromTo: %s-%s
+Departure: %s
+Vehicle: %s
+Price: from %.2f
+Available Seats: %d`, t.From, t.To, t.DepartureAt, t.Vehicle, t.Price, t.AvailableSeat),
+		LogMsg: fmt.Sprintf("Watch %d has been alerted about trip %d at %.2f (%s)", watch.ID, t.ID, t.Price, kind),
+	}
+}
@@ -0,0 +1,73 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
+	"github.com/dilaragorum/online-ticket-project-go/internal/trip"
+	"testing"
+)
+
+type fakeRepository struct {
+	Repository
+	watches []Watch
+	alerts  []Alert
+}
+
+func (r *fakeRepository) FindCandidates(ctx context.Context, t *trip.Trip) ([]Watch, error) {
+	return r.watches, nil
+}
+
+func (r *fakeRepository) LowestAlertPrice(ctx context.Context, watchID uint, tripID int) (float64, bool, error) {
+	lowest, alerted := 0.0, false
+	for _, alert := range r.alerts {
+		if alert.WatchID == watchID && alert.TripID == tripID && (!alerted || alert.Price < lowest) {
+			lowest, alerted = alert.Price, true
+		}
+	}
+	return lowest, alerted, nil
+}
+
+func (r *fakeRepository) CreateAlert(ctx context.Context, alert *Alert) error {
+	r.alerts = append(r.alerts, *alert)
+	return nil
+}
+
+type fakeNotifier struct {
+	failTo string
+	sent   []string
+}
+
+func (n *fakeNotifier) Send(ctx context.Context, param notification.Param) error {
+	if param.To == n.failTo {
+		return errors.New("smtp is down")
+	}
+	n.sent = append(n.sent, param.To)
+	return nil
+}
+
+func TestService_TripPriceChanged(t *testing.T) {
+	repo := &fakeRepository{
+		watches: []Watch{{ID: 1, To: "Izmir", Email: "down@mail.com"}, {ID: 2, To: "Izmir", Email: "up@mail.com"}},
+		alerts:  []Alert{{WatchID: 2, TripID: 1, Price: 300}},
+	}
+	notifier := &fakeNotifier{failTo: "down@mail.com"}
+	service := NewService(repo, notifier)
+
+	service.TripPriceChanged(context.Background(), &trip.Trip{ID: 1, To: "Izmir", Price: 250, AvailableSeat: 10})
+
+	if len(notifier.sent) != 1 || notifier.sent[0] != "up@mail.com" {
+		t.Fatalf("sent = %v, want [up@mail.com]", notifier.sent)
+	}
+	if len(repo.alerts) != 2 || repo.alerts[1].WatchID != 2 || repo.alerts[1].Price != 250 {
+		t.Fatalf("alerts = %v, want only the sent alert recorded", repo.alerts)
+	}
+
+	// The failed email is sent once it works again, the sent one is not repeated.
+	notifier.failTo = ""
+	service.TripPriceChanged(context.Background(), &trip.Trip{ID: 1, To: "Izmir", Price: 250, AvailableSeat: 10})
+
+	if len(notifier.sent) != 2 || notifier.sent[1] != "down@mail.com" {
+		t.Errorf("sent = %v, want [up@mail.com down@mail.com]", notifier.sent)
+	}
+}
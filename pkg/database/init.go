@@ -2,10 +2,18 @@ package database
 
 import (
 	"fmt"
+	"github.com/dilaragorum/online-ticket-project-go/internal/fleet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/loyalty"
 	"github.com/dilaragorum/online-ticket-project-go/internal/notification"
+	"github.com/dilaragorum/online-ticket-project-go/internal/role"
+	"github.com/dilaragorum/online-ticket-project-go/internal/session"
 	"github.com/dilaragorum/online-ticket-project-go/internal/ticket"
+	"github.com/dilaragorum/online-ticket-project-go/internal/traveller"
 	model "github.com/dilaragorum/online-ticket-project-go/internal/trip"
 	"github.com/dilaragorum/online-ticket-project-go/internal/user"
+	"github.com/dilaragorum/online-ticket-project-go/internal/waitlist"
+	"github.com/dilaragorum/online-ticket-project-go/internal/wallet"
+	"github.com/dilaragorum/online-ticket-project-go/internal/watch"
 	"github.com/spf13/viper"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -32,7 +40,23 @@ func Setup() (*gorm.DB, error) {
 }
 
 func Migrate() {
-	if err := db.AutoMigrate(&user.User{}, &notification.Log{}, &model.Trip{}, &ticket.Ticket{}); err != nil {
+	if err := user.MigrateEmailVerified(db); err != nil {
+		panic(err)
+	}
+
+	if err := db.AutoMigrate(&user.User{}, &user.Invitation{}, &user.PasswordReset{}, &role.Role{}, &role.RolePermission{}, &role.SeededPermission{}, &role.UserRole{}, &session.Session{}, &session.RefreshToken{}, &notification.Log{}, &fleet.Vehicle{}, &model.Station{}, &model.Trip{}, &model.FareClass{}, &model.Change{}, &ticket.Order{}, &ticket.Ticket{}, &ticket.TicketVersion{}, &ticket.TicketTransition{}, &loyalty.Account{}, &loyalty.Movement{}, &loyalty.Rule{}, &traveller.Traveller{}, &waitlist.Entry{}, &wallet.Wallet{}, &wallet.Transaction{}, &wallet.Entry{}, &watch.Watch{}, &watch.Alert{}); err != nil {
+		panic(err)
+	}
+
+	if err := model.MigrateDurations(db); err != nil {
+		panic(err)
+	}
+
+	if err := user.MigrateUserTypes(db); err != nil {
+		panic(err)
+	}
+
+	if err := fleet.MigrateRegistration(db); err != nil {
 		panic(err)
 	}
 }